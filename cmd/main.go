@@ -4,12 +4,15 @@ import (
 	"crypto/tls"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -19,6 +22,7 @@ import (
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	"github.com/golem-base/spoditor/internal/annotation"
 	webhookv1 "github.com/golem-base/spoditor/internal/webhook/v1"
 	// +kubebuilder:scaffold:imports
 )
@@ -42,6 +46,19 @@ func main() {
 	var enableHTTP2 bool
 	var tlsOpts []func(*tls.Config)
 	var webhookCertDir string
+	var enabledHandlers string
+	var handlersConfigMap string
+	var maxConcurrentMutations int
+	var mutationQueueTimeout time.Duration
+	var annotationPrefix string
+	var qualifierSeparator string
+	var dryRun bool
+	var maxOrdinal int
+	var statefulSetAnnotations bool
+	var allowedImages string
+	var defaultQualifier string
+	var logRateLimit int
+	var logRateWindow time.Duration
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -55,6 +72,40 @@ func main() {
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	flag.StringVar(&webhookCertDir, "webhook-cert-path", "",
 		"Path to the directory containing the webhook server certificate and key.")
+	flag.StringVar(&enabledHandlers, "enabled-handlers", "",
+		"Comma-separated list of annotation handlers to enable, e.g. mount-volume,host-port. "+
+			"All built-in handlers are enabled when empty.")
+	flag.StringVar(&handlersConfigMap, "handlers-configmap", "",
+		"ConfigMap as namespace/name whose \"handlers\" key lists the annotation handlers to enable, in the order "+
+			"they are applied. It replaces --enabled-handlers when it exists.")
+	flag.IntVar(&maxConcurrentMutations, "max-concurrent-mutations", 0,
+		"Maximum number of pods mutated at once. Pods over the limit are queued, and turned away with a "+
+			"retryable error when the queue timeout passes. Unlimited when 0.")
+	flag.DurationVar(&mutationQueueTimeout, "mutation-queue-timeout", 5*time.Second,
+		"How long a pod waits for a free mutation slot when --max-concurrent-mutations is set.")
+	flag.StringVar(&annotationPrefix, "annotation-prefix", annotation.Prefix,
+		"Prefix of the annotations this instance responds to, e.g. acme.example.com/. "+
+			"Lets several instances run side by side without acting on each other's annotations.")
+	flag.StringVar(&qualifierSeparator, "qualifier-separator", annotation.Separator,
+		"Separator between the feature name and the qualifier of an annotation key, split at its last occurrence. "+
+			"Use e.g. . when feature names contain underscores.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"If set, pods are admitted unchanged and the mutations spoditor would make are logged as JSON patches.")
+	flag.IntVar(&maxOrdinal, "max-ordinal", 0,
+		"Largest pod ordinal spoditor mutates. Pods with a larger ordinal are admitted unchanged. Unlimited when 0.")
+	flag.BoolVar(&statefulSetAnnotations, "statefulset-annotations", false,
+		"If set, spoditor annotations on a StatefulSet itself apply to its pods too, unless the pod template overrides them.")
+	flag.StringVar(&allowedImages, "allowed-images", "",
+		"Comma-separated list of image patterns, e.g. registry.example.com/*. Only pods whose first container "+
+			"runs a matching image are mutated, * matching any characters. All pods are eligible when empty.")
+	flag.StringVar(&defaultQualifier, "default-qualifier", "",
+		"Qualifier of unqualified annotations, e.g. 0-2 for them to apply to the first three pods only. "+
+			"Unqualified annotations apply to all pods when empty.")
+	flag.IntVar(&logRateLimit, "log-rate-limit", 0,
+		"Maximum number of identical info messages each logger, e.g. each handler, writes per --log-rate-window. "+
+			"Unlimited when 0.")
+	flag.DurationVar(&logRateWindow, "log-rate-window", 10*time.Second,
+		"Window over which --log-rate-limit applies.")
 
 	opts := zap.Options{
 		Development: true,
@@ -62,7 +113,7 @@ func main() {
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	ctrl.SetLogger(annotation.RateLimitLogs(zap.New(zap.UseFlagOptions(&opts)), logRateLimit, logRateWindow))
 
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
@@ -113,10 +164,58 @@ func main() {
 	}
 
 	// Set up the webhook (only if enabled)
-	if err = webhookv1.SetupPodWebhookWithManager(mgr); err != nil {
+	var handlers []string
+	if enabledHandlers != "" {
+		handlers = strings.Split(enabledHandlers, ",")
+	}
+	var handlersKey types.NamespacedName
+	if handlersConfigMap != "" {
+		namespace, name, ok := strings.Cut(handlersConfigMap, "/")
+		if !ok || namespace == "" || name == "" {
+			setupLog.Error(nil, "handler ConfigMap must be given as namespace/name", "handlersConfigMap", handlersConfigMap)
+			os.Exit(1)
+		}
+		handlersKey = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+	if defaultQualifier != "" {
+		if err := annotation.ValidateQualifier(defaultQualifier); err != nil {
+			setupLog.Error(err, "invalid default qualifier", "defaultQualifier", defaultQualifier)
+			os.Exit(1)
+		}
+	}
+	var images []string
+	if allowedImages != "" {
+		images = strings.Split(allowedImages, ",")
+	}
+	if !strings.HasSuffix(annotationPrefix, "/") {
+		annotationPrefix += "/"
+	}
+	if qualifierSeparator == "" {
+		setupLog.Error(nil, "qualifier separator must not be empty")
+		os.Exit(1)
+	}
+	// Errors name annotations by their key under the global collector
+	annotation.Collector = &annotation.PrefixedCollector{Prefix: annotationPrefix, Separator: qualifierSeparator}
+	mutatorOpts := webhookv1.PodMutatorOptions{
+		Enabled:                handlers,
+		HandlersConfigMap:      handlersKey,
+		Collector:              annotation.Collector,
+		MaxConcurrent:          maxConcurrentMutations,
+		QueueTimeout:           mutationQueueTimeout,
+		DryRun:                 dryRun,
+		MaxOrdinal:             maxOrdinal,
+		StatefulSetAnnotations: statefulSetAnnotations,
+		AllowedImages:          images,
+		DefaultQualifier:       defaultQualifier,
+	}
+	if err = webhookv1.SetupPodWebhookWithManager(mgr, mutatorOpts); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "Pod")
 		os.Exit(1)
 	}
+	if err = webhookv1.SetupStatefulSetWebhookWithManager(mgr, mutatorOpts); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "StatefulSet")
+		os.Exit(1)
+	}
 
 	// +kubebuilder:scaffold:builder
 
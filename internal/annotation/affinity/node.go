@@ -0,0 +1,151 @@
+package affinity
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// OrdinalNodeAffinity is the annotation key naming the node label matched against the pod ordinal
+	OrdinalNodeAffinity = "ordinal-node-affinity"
+)
+
+var log = logf.Log.WithName("ordinal_node_affinity")
+
+// nodeSchema is the JSON Schema of the annotation value
+//
+//go:embed node.schema.json
+var nodeSchema []byte
+
+// nodeAffinityConfig holds the node label key with its pod qualifier
+type nodeAffinityConfig struct {
+	qualifier string // Which pods this applies to
+	labelKey  string // Node label whose value must equal the pod ordinal
+}
+
+// Ensure NodeAffinityHandler implements Handler interface
+var _ annotation.Handler = (*NodeAffinityHandler)(nil)
+
+// NodeAffinityHandler pins pods to nodes whose label value equals the pod ordinal
+type NodeAffinityHandler struct{}
+
+// Mutate adds a required node selector requirement matching the configured label to the ordinal
+func (h *NodeAffinityHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*nodeAffinityConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*nodeAffinityConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply ANDs the requirement of a single configuration into the node selector
+func (c *nodeAffinityConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	requirement := corev1.NodeSelectorRequirement{
+		Key:      c.labelKey,
+		Operator: corev1.NodeSelectorOpIn,
+		Values:   []string{strconv.Itoa(mc.Ordinal)},
+	}
+
+	if spec.Affinity == nil {
+		spec.Affinity = &corev1.Affinity{}
+	}
+	if spec.Affinity.NodeAffinity == nil {
+		spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := spec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+	selector := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+
+	// Node selector terms are ORed, so the requirement has to be ANDed into
+	// every existing term to keep the pod pinned whichever term matches. Terms
+	// already holding it, e.g. from an earlier admission, are left alone.
+	if len(selector.NodeSelectorTerms) == 0 {
+		l.Info("adding node selector term", "key", c.labelKey)
+		selector.NodeSelectorTerms = []corev1.NodeSelectorTerm{
+			{MatchExpressions: []corev1.NodeSelectorRequirement{requirement}},
+		}
+		return
+	}
+
+	for i := range selector.NodeSelectorTerms {
+		if slices.ContainsFunc(selector.NodeSelectorTerms[i].MatchExpressions, func(r corev1.NodeSelectorRequirement) bool {
+			return equality.Semantic.DeepEqual(r, requirement)
+		}) {
+			l.Info("node selector term already holds the requirement", "term", i, "key", c.labelKey)
+			continue
+		}
+		l.Info("merging requirement into existing node selector term", "term", i, "key", c.labelKey)
+		selector.NodeSelectorTerms[i].MatchExpressions = append(
+			selector.NodeSelectorTerms[i].MatchExpressions,
+			*requirement.DeepCopy())
+	}
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *NodeAffinityHandler) Name() string {
+	return OrdinalNodeAffinity
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *NodeAffinityHandler) Schema() []byte {
+	return nodeSchema
+}
+
+// GetParser returns the parser for ordinal node affinity annotations
+func (h *NodeAffinityHandler) GetParser() annotation.Parser {
+	return nodeAffinityParser
+}
+
+// nodeAffinityParser parses every ordinal node affinity annotation, whatever its
+// qualifier, into a nodeAffinityConfig, returning them in annotation key order
+var nodeAffinityParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*nodeAffinityConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != OrdinalNodeAffinity {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing ordinal node affinity configuration")
+
+		key := strings.TrimSpace(v)
+		if key == "" {
+			return nil, fmt.Errorf("invalid ordinal node affinity configuration: empty node label key")
+		}
+
+		configs = append(configs, &nodeAffinityConfig{
+			qualifier: k.Qualifier,
+			labelKey:  key,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
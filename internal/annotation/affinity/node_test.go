@@ -0,0 +1,279 @@
+package affinity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNodeAffinityHandler_Mutate(t *testing.T) {
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 0,
+				cfg: []*nodeAffinityConfig{{
+					qualifier: "1-2",
+					labelKey:  "shard",
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "fresh affinity",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 2,
+				cfg: []*nodeAffinityConfig{{
+					labelKey: "shard",
+				}},
+			},
+			want: &corev1.PodSpec{
+				Affinity: &corev1.Affinity{
+					NodeAffinity: &corev1.NodeAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+							NodeSelectorTerms: []corev1.NodeSelectorTerm{
+								{
+									MatchExpressions: []corev1.NodeSelectorRequirement{
+										{
+											Key:      "shard",
+											Operator: corev1.NodeSelectorOpIn,
+											Values:   []string{"2"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "merge with existing affinity",
+			args: args{
+				spec: &corev1.PodSpec{
+					Affinity: &corev1.Affinity{
+						NodeAffinity: &corev1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+								NodeSelectorTerms: []corev1.NodeSelectorTerm{
+									{
+										MatchExpressions: []corev1.NodeSelectorRequirement{
+											{
+												Key:      "pool",
+												Operator: corev1.NodeSelectorOpIn,
+												Values:   []string{"fast"},
+											},
+										},
+									},
+								},
+							},
+							PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{
+								{
+									Weight: 10,
+									Preference: corev1.NodeSelectorTerm{
+										MatchExpressions: []corev1.NodeSelectorRequirement{
+											{
+												Key:      "zone",
+												Operator: corev1.NodeSelectorOpExists,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				ordinal: 1,
+				cfg: []*nodeAffinityConfig{{
+					labelKey: "shard",
+				}},
+			},
+			want: &corev1.PodSpec{
+				Affinity: &corev1.Affinity{
+					NodeAffinity: &corev1.NodeAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+							NodeSelectorTerms: []corev1.NodeSelectorTerm{
+								{
+									MatchExpressions: []corev1.NodeSelectorRequirement{
+										{
+											Key:      "pool",
+											Operator: corev1.NodeSelectorOpIn,
+											Values:   []string{"fast"},
+										},
+										{
+											Key:      "shard",
+											Operator: corev1.NodeSelectorOpIn,
+											Values:   []string{"1"},
+										},
+									},
+								},
+							},
+						},
+						PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{
+							{
+								Weight: 10,
+								Preference: corev1.NodeSelectorTerm{
+									MatchExpressions: []corev1.NodeSelectorRequirement{
+										{
+											Key:      "zone",
+											Operator: corev1.NodeSelectorOpExists,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &NodeAffinityHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeAffinityHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: OrdinalNodeAffinity}:  "example.com/seed",
+		{Qualifier: "1-", Name: OrdinalNodeAffinity}: "example.com/replica",
+	}
+
+	for ordinal, want := range map[int]string{0: "example.com/seed", 3: "example.com/replica"} {
+		spec, err := annotationtest.ApplyOrdinal(&NodeAffinityHandler{}, annotations, &corev1.PodSpec{}, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		terms := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		if len(terms) != 1 || len(terms[0].MatchExpressions) != 1 || terms[0].MatchExpressions[0].Key != want {
+			t.Errorf("ApplyOrdinal() ordinal %d terms = %v, want a single requirement on %s", ordinal, terms, want)
+		}
+	}
+}
+
+func TestNodeAffinityHandler_Mutate_Idempotent(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: OrdinalNodeAffinity}: "example.com/ordinal",
+	}
+
+	h := &NodeAffinityHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	zone := corev1.NodeSelectorRequirement{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"}}
+	spec := &corev1.PodSpec{
+		Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{zone}}},
+			},
+		}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: 2}, cfg); err != nil {
+			t.Fatalf("Mutate() pass %d error = %v", i, err)
+		}
+	}
+
+	want := []corev1.NodeSelectorRequirement{
+		zone,
+		{Key: "example.com/ordinal", Operator: corev1.NodeSelectorOpIn, Values: []string{"2"}},
+	}
+	got := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(got) != 1 || !reflect.DeepEqual(got[0].MatchExpressions, want) {
+		t.Errorf("Mutate() node selector terms = %v, want a single term with %v", got, want)
+	}
+}
+
+func Test_nodeAffinityParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       nodeAffinityParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "label key",
+			p:    nodeAffinityParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Qualifier: "1-",
+					Name:      OrdinalNodeAffinity,
+				}: " shard ",
+			}},
+			want: []*nodeAffinityConfig{{
+				qualifier: "1-",
+				labelKey:  "shard",
+			}},
+			wantErr: false,
+		},
+		{
+			name: "empty label key",
+			p:    nodeAffinityParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: OrdinalNodeAffinity,
+				}: "",
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package affinity
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// LeaderAffinity is the annotation key for co-locating followers with the leader pod
+	LeaderAffinity = "leader-affinity"
+	// PodNameLabel is the label the StatefulSet controller sets to the pod name
+	PodNameLabel = "statefulset.kubernetes.io/pod-name"
+	// DefaultTopologyKey is used when the configuration doesn't name a topology key
+	DefaultTopologyKey = "kubernetes.io/hostname"
+)
+
+var leaderLog = logf.Log.WithName("leader_affinity")
+
+// leaderSchema is the JSON Schema of the annotation value
+//
+//go:embed pod.schema.json
+var leaderSchema []byte
+
+// leaderAffinityConfig holds the leader affinity configuration with its pod qualifier
+type leaderAffinityConfig struct {
+	qualifier string                     // Which pods this applies to
+	cfg       *leaderAffinityConfigValue // The actual affinity configuration
+}
+
+// leaderAffinityConfigValue represents the JSON structure of the leader affinity configuration
+type leaderAffinityConfigValue struct {
+	TopologyKey string `json:"topologyKey"`      // Topology domain shared with the leader
+	Weight      int32  `json:"weight,omitempty"` // Makes the affinity preferred with this weight instead of required
+}
+
+// Ensure LeaderAffinityHandler implements Handler interface
+var _ annotation.Handler = (*LeaderAffinityHandler)(nil)
+
+// LeaderAffinityHandler schedules follower pods into the same topology domain as the leader
+type LeaderAffinityHandler struct{}
+
+// Mutate adds pod affinity targeting the leader pod, the one with the start
+// ordinal of the StatefulSet, to every other pod
+func (h *LeaderAffinityHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := leaderLog.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*leaderAffinityConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*leaderAffinityConfig", cfg)
+	}
+
+	// The leader has no one to follow
+	leader := leaderOrdinal(mc)
+	if mc.Ordinal == leader {
+		l.Info("pod is the leader, skipping")
+		return nil
+	}
+
+	if mc.SSName == "" {
+		l.Info("statefulset name unknown, skipping")
+		return nil
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc.SSName, leader, l)
+	}
+
+	return nil
+}
+
+// leaderOrdinal returns the ordinal of the leader pod, the start ordinal of
+// the StatefulSet, 0 unless it sets spec.ordinals
+func leaderOrdinal(mc annotation.MutationContext) int {
+	if mc.StatefulSet == nil || mc.StatefulSet.Spec.Ordinals == nil {
+		return 0
+	}
+	return int(mc.StatefulSet.Spec.Ordinals.Start)
+}
+
+// apply adds the affinity to the leader of a single configuration
+func (c *leaderAffinityConfig) apply(spec *corev1.PodSpec, ssName string, ordinal int, l logr.Logger) {
+	leader := fmt.Sprintf("%s-%d", ssName, ordinal)
+	term := corev1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{PodNameLabel: leader},
+		},
+		TopologyKey: c.cfg.TopologyKey,
+	}
+
+	if spec.Affinity == nil {
+		spec.Affinity = &corev1.Affinity{}
+	}
+	if spec.Affinity.PodAffinity == nil {
+		spec.Affinity.PodAffinity = &corev1.PodAffinity{}
+	}
+	podAffinity := spec.Affinity.PodAffinity
+
+	// Terms the pod already has, e.g. from an earlier admission, aren't added
+	// again, which would add up the weights of preferred ones
+	if c.cfg.Weight > 0 {
+		weighted := corev1.WeightedPodAffinityTerm{Weight: c.cfg.Weight, PodAffinityTerm: term}
+		if slices.ContainsFunc(podAffinity.PreferredDuringSchedulingIgnoredDuringExecution, func(t corev1.WeightedPodAffinityTerm) bool {
+			return equality.Semantic.DeepEqual(t, weighted)
+		}) {
+			l.Info("pod already prefers the leader", "leader", leader)
+			return
+		}
+		l.Info("adding preferred affinity to leader", "leader", leader, "weight", c.cfg.Weight)
+		podAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+			podAffinity.PreferredDuringSchedulingIgnoredDuringExecution, weighted)
+		return
+	}
+
+	if slices.ContainsFunc(podAffinity.RequiredDuringSchedulingIgnoredDuringExecution, func(t corev1.PodAffinityTerm) bool {
+		return equality.Semantic.DeepEqual(t, term)
+	}) {
+		l.Info("pod already requires the leader", "leader", leader)
+		return
+	}
+	l.Info("adding required affinity to leader", "leader", leader)
+	podAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+		podAffinity.RequiredDuringSchedulingIgnoredDuringExecution, term)
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *LeaderAffinityHandler) Name() string {
+	return LeaderAffinity
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *LeaderAffinityHandler) Schema() []byte {
+	return leaderSchema
+}
+
+// GetParser returns the parser for leader affinity annotations
+func (h *LeaderAffinityHandler) GetParser() annotation.Parser {
+	return leaderAffinityParser
+}
+
+// leaderAffinityParser parses every leader affinity annotation, whatever its
+// qualifier, into a leaderAffinityConfig, returning them in annotation key order
+var leaderAffinityParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*leaderAffinityConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != LeaderAffinity {
+			continue
+		}
+		v := annotations[k]
+
+		logger := leaderLog.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing leader affinity configuration")
+
+		config := &leaderAffinityConfigValue{}
+		if err := json.Unmarshal([]byte(v), config); err != nil {
+			logger.Error(err, "failed to parse leader affinity configuration")
+			return nil, fmt.Errorf("invalid leader affinity configuration: %w", err)
+		}
+
+		if config.TopologyKey == "" {
+			config.TopologyKey = DefaultTopologyKey
+		}
+		if config.Weight < 0 || config.Weight > 100 {
+			return nil, fmt.Errorf("invalid leader affinity configuration: weight %d not in range 1-100", config.Weight)
+		}
+
+		configs = append(configs, &leaderAffinityConfig{
+			qualifier: k.Qualifier,
+			cfg:       config,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
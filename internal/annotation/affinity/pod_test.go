@@ -0,0 +1,275 @@
+package affinity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// startingAt returns a StatefulSet numbering its pods from start
+func startingAt(start int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{Ordinals: &appsv1.StatefulSetOrdinals{Start: start}},
+	}
+}
+
+func TestLeaderAffinityHandler_Mutate(t *testing.T) {
+	leaderTerm := corev1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{PodNameLabel: "web-0"},
+		},
+		TopologyKey: DefaultTopologyKey,
+	}
+
+	type args struct {
+		spec *corev1.PodSpec
+		mc   annotation.MutationContext
+		cfg  any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec: nil,
+				mc:   annotation.MutationContext{Ordinal: 1, SSName: "web"},
+				cfg:  nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "leader is left alone",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc:   annotation.MutationContext{Ordinal: 0, SSName: "web"},
+				cfg: []*leaderAffinityConfig{{
+					cfg: &leaderAffinityConfigValue{TopologyKey: DefaultTopologyKey},
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc:   annotation.MutationContext{Ordinal: 3, SSName: "web"},
+				cfg: []*leaderAffinityConfig{{
+					qualifier: "1-2",
+					cfg:       &leaderAffinityConfigValue{TopologyKey: DefaultTopologyKey},
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "do nothing without a StatefulSet name",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc:   annotation.MutationContext{Ordinal: 2},
+				cfg: []*leaderAffinityConfig{{
+					cfg: &leaderAffinityConfigValue{TopologyKey: DefaultTopologyKey},
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "follower requires the leader",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc:   annotation.MutationContext{Ordinal: 2, SSName: "web"},
+				cfg: []*leaderAffinityConfig{{
+					cfg: &leaderAffinityConfigValue{TopologyKey: DefaultTopologyKey},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Affinity: &corev1.Affinity{
+					PodAffinity: &corev1.PodAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{leaderTerm},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "follower prefers the leader",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc:   annotation.MutationContext{Ordinal: 1, SSName: "web"},
+				cfg: []*leaderAffinityConfig{{
+					cfg: &leaderAffinityConfigValue{TopologyKey: DefaultTopologyKey, Weight: 50},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Affinity: &corev1.Affinity{
+					PodAffinity: &corev1.PodAffinity{
+						PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+							{Weight: 50, PodAffinityTerm: leaderTerm},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "leader at the start ordinal is left alone",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc:   annotation.MutationContext{Ordinal: 3, SSName: "web", StatefulSet: startingAt(3)},
+				cfg: []*leaderAffinityConfig{{
+					cfg: &leaderAffinityConfigValue{TopologyKey: DefaultTopologyKey},
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "follower requires the leader at the start ordinal",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc:   annotation.MutationContext{Ordinal: 4, SSName: "web", StatefulSet: startingAt(3)},
+				cfg: []*leaderAffinityConfig{{
+					cfg: &leaderAffinityConfigValue{TopologyKey: DefaultTopologyKey},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Affinity: &corev1.Affinity{
+					PodAffinity: &corev1.PodAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+							LabelSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{PodNameLabel: "web-3"},
+							},
+							TopologyKey: DefaultTopologyKey,
+						}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &LeaderAffinityHandler{}
+			if err := h.Mutate(tt.args.spec, tt.args.mc, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaderAffinityHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "1", Name: LeaderAffinity}:  `{"weight":50}`,
+		{Qualifier: "2-", Name: LeaderAffinity}: `{}`,
+	}
+	h := &LeaderAffinityHandler{}
+
+	spec, err := annotationtest.Apply(h, annotations, &corev1.PodSpec{}, annotation.MutationContext{SSName: "web", Ordinal: 1})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if a := spec.Affinity.PodAffinity; len(a.PreferredDuringSchedulingIgnoredDuringExecution) != 1 || len(a.RequiredDuringSchedulingIgnoredDuringExecution) != 0 {
+		t.Errorf("Apply() ordinal 1 pod affinity = %v, want a single preferred term", a)
+	}
+
+	spec, err = annotationtest.Apply(h, annotations, &corev1.PodSpec{}, annotation.MutationContext{SSName: "web", Ordinal: 3})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if a := spec.Affinity.PodAffinity; len(a.PreferredDuringSchedulingIgnoredDuringExecution) != 0 || len(a.RequiredDuringSchedulingIgnoredDuringExecution) != 1 {
+		t.Errorf("Apply() ordinal 3 pod affinity = %v, want a single required term", a)
+	}
+}
+
+func TestLeaderAffinityHandler_Mutate_Idempotent(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "1", Name: LeaderAffinity}:  `{"weight":50}`,
+		{Qualifier: "2-", Name: LeaderAffinity}: `{}`,
+	}
+
+	h := &LeaderAffinityHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for _, ordinal := range []int{1, 2} {
+		spec := &corev1.PodSpec{}
+		for i := 0; i < 2; i++ {
+			if err := h.Mutate(spec, annotation.MutationContext{Ordinal: ordinal, SSName: "web"}, cfg); err != nil {
+				t.Fatalf("Mutate() pass %d error = %v", i, err)
+			}
+		}
+
+		a := spec.Affinity.PodAffinity
+		if n := len(a.PreferredDuringSchedulingIgnoredDuringExecution) + len(a.RequiredDuringSchedulingIgnoredDuringExecution); n != 1 {
+			t.Errorf("Mutate() ordinal %d pod affinity = %v, want a single term", ordinal, a)
+		}
+	}
+}
+
+func Test_leaderAffinityParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       leaderAffinityParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "default topology key",
+			p:    leaderAffinityParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: LeaderAffinity}: `{}`,
+			}},
+			want: []*leaderAffinityConfig{{
+				cfg: &leaderAffinityConfigValue{TopologyKey: DefaultTopologyKey},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "weight out of range",
+			p:    leaderAffinityParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: LeaderAffinity}: `{"weight":101}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
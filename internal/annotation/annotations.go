@@ -1,10 +1,14 @@
 package annotation
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -17,13 +21,64 @@ const (
 
 var log = logf.Log.WithName("annotations")
 
+// MutationContext describes the StatefulSet pod being mutated
+type MutationContext struct {
+	Ordinal int    // Pod ordinal within the StatefulSet
+	SSName  string // Name of the StatefulSet owning the pod
+	// StatefulSet owning the pod, nil when the webhook couldn't read it
+	StatefulSet *appsv1.StatefulSet
+}
+
 // Handler defines operations for mutating pod specs based on annotations
 type Handler interface {
-	Mutate(spec *corev1.PodSpec, ordinal int, cfg any) error
+	Mutate(spec *corev1.PodSpec, mc MutationContext, cfg any) error
 	GetParser() Parser
 }
 
-// Parser converts annotation maps to configuration objects
+// MetadataHandler is implemented by handlers that also mutate pod metadata.
+// MutateMeta is called in addition to Mutate, with the same parsed configuration.
+type MetadataHandler interface {
+	Handler
+	MutateMeta(meta *metav1.ObjectMeta, mc MutationContext, cfg any) error
+}
+
+// NamedHandler is implemented by handlers that identify themselves by the feature they handle
+type NamedHandler interface {
+	Name() string
+}
+
+// SchemaHandler is implemented by handlers that describe their annotation value
+// with a JSON Schema document, e.g. for client-side validation
+type SchemaHandler interface {
+	Schema() []byte
+}
+
+// PrioritizedHandler is implemented by handlers that need to run before or after
+// others. Handlers run in ascending priority; handlers without one have priority 0
+// and keep their registration order among equals
+type PrioritizedHandler interface {
+	Priority() int
+}
+
+// HandlerPriority returns the priority of a handler, defaulting to 0
+func HandlerPriority(h Handler) int {
+	if p, ok := h.(PrioritizedHandler); ok {
+		return p.Priority()
+	}
+	return 0
+}
+
+// HandlerName returns the name of a handler, falling back to its type for unnamed handlers
+func HandlerName(h Handler) string {
+	if n, ok := h.(NamedHandler); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", h)
+}
+
+// Parser converts annotation maps to configuration objects. Parsers visit the
+// annotations in SortedKeys order, so the same annotations always yield the
+// same configuration regardless of map iteration order.
 type Parser interface {
 	Parse(annotations map[QualifiedName]string) (any, error)
 }
@@ -41,6 +96,25 @@ type QualifiedName struct {
 	Name      string // Feature name
 }
 
+// Key rebuilds the full annotation key the qualified name was collected from
+// under the default prefix
+func (q QualifiedName) Key() string {
+	return defaultCollector.Key(q)
+}
+
+// SortedKeys returns the qualified names of the annotations ordered by their
+// full annotation key, so they can be visited in a stable order
+func SortedKeys(annotations map[QualifiedName]string) []QualifiedName {
+	keys := make([]QualifiedName, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Key() < keys[j].Key()
+	})
+	return keys
+}
+
 // QualifiedAnnotationCollector extracts qualified annotations from k8s objects
 type QualifiedAnnotationCollector interface {
 	Collect(accessor metav1.ObjectMetaAccessor) map[QualifiedName]string
@@ -53,18 +127,87 @@ func (c CollectorFunc) Collect(accessor metav1.ObjectMetaAccessor) map[Qualified
 	return c(accessor)
 }
 
-// Collector is the global annotation collector instance
+var (
+	// ErrMissingFeatureName reports an annotation key with a qualifier but no
+	// feature name, e.g. spoditor.io/_0-2
+	ErrMissingFeatureName = errors.New("annotation key has no feature name before its qualifier")
+	// ErrEmptyQualifier reports an annotation key ending in the separator,
+	// e.g. spoditor.io/mount-volume_
+	ErrEmptyQualifier = errors.New("annotation key has an empty qualifier after its separator")
+)
+
+// KeyedCollector is implemented by collectors that know the full annotation key
+// a qualified name was collected from, e.g. because they use a custom prefix
+type KeyedCollector interface {
+	Key(q QualifiedName) string
+}
+
+// KeyOf returns the full annotation key of q as seen by the collector, falling
+// back to the default prefix for collectors that don't implement KeyedCollector
+func KeyOf(c QualifiedAnnotationCollector, q QualifiedName) string {
+	if kc, ok := c.(KeyedCollector); ok {
+		return kc.Key(q)
+	}
+	return q.Key()
+}
+
+// SeparatorOf returns the separator the collector splits qualifiers off with,
+// falling back to Separator for collectors other than PrefixedCollector
+func SeparatorOf(c QualifiedAnnotationCollector) string {
+	if pc, ok := c.(*PrefixedCollector); ok {
+		return pc.separator()
+	}
+	return Separator
+}
+
+// Collector is the global annotation collector instance. Errors name
+// annotations by their key under it, so a webhook configured with another
+// collector sets it to that one at startup.
 var Collector QualifiedAnnotationCollector = defaultCollector
 
-// defaultCollector implements annotation collection logic
-var defaultCollector = CollectorFunc(func(accessor metav1.ObjectMetaAccessor) map[QualifiedName]string {
+// defaultCollector collects the annotations under the default prefix
+var defaultCollector = &PrefixedCollector{Prefix: Prefix}
+
+// Ensure PrefixedCollector implements KeyedCollector interface
+var _ KeyedCollector = (*PrefixedCollector)(nil)
+
+// PrefixedCollector collects the annotations under a configurable prefix, so
+// several webhooks can each respond to their own annotations
+type PrefixedCollector struct {
+	// Prefix the annotation keys start with, including the trailing slash,
+	// e.g. "acme.example.com/"
+	Prefix string
+	// Separator splits the feature name from the qualifier at its last
+	// occurrence, Separator when empty. A separator that can't occur in
+	// feature names, such as ".", lets feature names contain underscores.
+	Separator string
+}
+
+// separator returns the configured separator or the default one
+func (c *PrefixedCollector) separator() string {
+	if c.Separator == "" {
+		return Separator
+	}
+	return c.Separator
+}
+
+// Key rebuilds the full annotation key of q under the collector's prefix
+func (c *PrefixedCollector) Key(q QualifiedName) string {
+	if q.Qualifier == "" {
+		return c.Prefix + q.Name
+	}
+	return c.Prefix + q.Name + c.separator() + q.Qualifier
+}
+
+// Collect implements QualifiedAnnotationCollector
+func (c *PrefixedCollector) Collect(accessor metav1.ObjectMetaAccessor) map[QualifiedName]string {
 	result := make(map[QualifiedName]string)
 
 	for k, v := range accessor.GetObjectMeta().GetAnnotations() {
 		// Use V(1) for more detailed logging that's not needed in normal operation
 		logger := log.V(1).WithValues("key", k, "value", v)
 
-		if !strings.HasPrefix(k, Prefix) {
+		if !strings.HasPrefix(k, c.Prefix) {
 			// Skip irrelevant annotations silently - only log at high verbosity
 			logger.Info("skipping irrelevant annotation")
 			continue
@@ -73,23 +216,38 @@ var defaultCollector = CollectorFunc(func(accessor metav1.ObjectMetaAccessor) ma
 		// Log finding relevant annotations at regular level, but with less detail
 		log.V(0).Info("found annotation", "key", k)
 
-		name := strings.TrimPrefix(k, Prefix)
-		separatorIndex := strings.LastIndex(name, Separator)
+		name := strings.TrimPrefix(k, c.Prefix)
+		separator := c.separator()
+		separatorIndex := strings.LastIndex(name, separator)
 
 		if separatorIndex == -1 {
 			logger.Info("dynamic argumentation")
 			result[QualifiedName{Name: name}] = v
-		} else {
-			logger.Info("designated argumentation")
-			result[QualifiedName{
-				Qualifier: name[separatorIndex+1:],
-				Name:      name[:separatorIndex],
-			}] = v
+			continue
 		}
+
+		q := QualifiedName{
+			Qualifier: name[separatorIndex+len(separator):],
+			Name:      name[:separatorIndex],
+		}
+
+		// A key missing either side of the separator would otherwise match no
+		// handler, or with an empty qualifier every pod
+		switch {
+		case q.Name == "":
+			log.Error(ErrMissingFeatureName, "ignoring malformed annotation", "key", k)
+			continue
+		case q.Qualifier == "":
+			log.Error(ErrEmptyQualifier, "ignoring malformed annotation", "key", k)
+			continue
+		}
+
+		logger.Info("designated argumentation")
+		result[q] = v
 	}
 
 	return result
-})
+}
 
 // PodQualifier determines if a pod with given ordinal matches a qualifier
 type PodQualifier func(ordinal int, qualifier string) bool
@@ -100,10 +258,49 @@ var (
 	exactNumberRegex = regexp.MustCompile(`^\d+$`)
 	lowerBoundRegex  = regexp.MustCompile(`^\d+-$`)
 	upperBoundRegex  = regexp.MustCompile(`^-\d+$`)
+	// bracketRangeRegex is a range with a bracket on either bound, ( or )
+	// excluding the bound and [ or ] including it like no bracket does
+	bracketRangeRegex = regexp.MustCompile(`^([\[(]?)(\d+)-(\d+)([\])]?)$`)
 )
 
-// CommonPodQualifier is the standard implementation of PodQualifier
-var CommonPodQualifier PodQualifier = func(ordinal int, qualifier string) bool {
+// Keyword qualifiers selecting every other ordinal, matched case-insensitively
+const (
+	EvenQualifier = "even"
+	OddQualifier  = "odd"
+)
+
+// CommonPodQualifier is the standard implementation of PodQualifier. CEL
+// qualifiers referring to the replicas select no pod through it, as it only
+// knows the ordinal; MutationContext.Selects knows them too.
+var CommonPodQualifier PodQualifier = commonPodQualifier
+
+func commonPodQualifier(ordinal int, qualifier string) bool {
+	return matchQualifier(ordinal, nil, qualifier)
+}
+
+// Selects reports whether the qualifier selects the pod being mutated. Unlike
+// CommonPodQualifier, CEL qualifiers may refer to the replicas of the
+// StatefulSet when it is known.
+func (mc MutationContext) Selects(qualifier string) bool {
+	return matchQualifier(mc.Ordinal, mc.replicas(), qualifier)
+}
+
+// replicas returns the desired number of pods of the StatefulSet, nil when
+// the StatefulSet isn't known
+func (mc MutationContext) replicas() *int64 {
+	if mc.StatefulSet == nil {
+		return nil
+	}
+	replicas := int64(1)
+	if mc.StatefulSet.Spec.Replicas != nil {
+		replicas = int64(*mc.StatefulSet.Spec.Replicas)
+	}
+	return &replicas
+}
+
+// matchQualifier reports whether the qualifier selects the ordinal, with the
+// replicas of the StatefulSet for CEL qualifiers when they are known
+func matchQualifier(ordinal int, replicas *int64, qualifier string) bool {
 	logger := log.WithValues("ordinal", ordinal, "qualifier", qualifier)
 
 	// Empty qualifier means apply to all pods
@@ -112,6 +309,37 @@ var CommonPodQualifier PodQualifier = func(ordinal int, qualifier string) bool {
 		return true
 	}
 
+	// Handle CEL expressions: "cel:ordinal % 2 == 0"
+	if expr, ok := strings.CutPrefix(qualifier, CELPrefix); ok {
+		match, err := matchCEL(ordinal, replicas, expr)
+		if err != nil {
+			logger.Error(err, "failed to match CEL qualifier")
+			return false
+		}
+		logger.Info("checked ordinal against CEL expression", "match", match)
+		return match
+	}
+
+	// Handle lists: "0,2,5-", matching when any element matches
+	if strings.Contains(qualifier, ",") {
+		for _, q := range strings.Split(qualifier, ",") {
+			if q = strings.TrimSpace(q); q != "" && matchQualifier(ordinal, replicas, q) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Handle parity keywords: "even", "odd"
+	switch strings.ToLower(qualifier) {
+	case EvenQualifier:
+		logger.Info("checking ordinal is even")
+		return ordinal%2 == 0
+	case OddQualifier:
+		logger.Info("checking ordinal is odd")
+		return ordinal%2 == 1
+	}
+
 	// Handle ranges: "1-5"
 	if rangeRegex.MatchString(qualifier) {
 		bounds := strings.Split(qualifier, "-")
@@ -121,6 +349,20 @@ var CommonPodQualifier PodQualifier = func(ordinal int, qualifier string) bool {
 		return ordinal >= min && ordinal <= max
 	}
 
+	// Handle bracketed ranges: "[0-3)", "(1-5)", "1-5)"
+	if m := bracketRangeRegex.FindStringSubmatch(qualifier); m != nil {
+		min, _ := strconv.Atoi(m[2])
+		max, _ := strconv.Atoi(m[3])
+		if m[1] == "(" {
+			min++
+		}
+		if m[4] == ")" {
+			max--
+		}
+		logger.Info("checking ordinal against bracketed range", "min", min, "max", max)
+		return ordinal >= min && ordinal <= max
+	}
+
 	// Handle exact match: "3"
 	if exactNumberRegex.MatchString(qualifier) {
 		target, _ := strconv.Atoi(qualifier)
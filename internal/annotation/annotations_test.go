@@ -1,9 +1,15 @@
 package annotation
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"slices"
 	"testing"
 
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -36,6 +42,71 @@ func TestCollectorFunc_Collect(t *testing.T) {
 				}: "dummy value",
 			},
 		},
+		{
+			name: "custom prefix",
+			c:    &PrefixedCollector{Prefix: "acme.example.com/"},
+			args: args{accessor: &v1.ObjectMeta{
+				Annotations: map[string]string{
+					"acme.example.com/mount-volume_0": "acme value",
+					"spoditor.io/mount-volume_0":      "dummy value",
+				},
+			}},
+			want: map[QualifiedName]string{
+				{
+					Qualifier: "0",
+					Name:      "mount-volume",
+				}: "acme value",
+			},
+		},
+		{
+			name: "underscore in feature name with default separator",
+			c:    Collector,
+			args: args{accessor: &v1.ObjectMeta{
+				Annotations: map[string]string{
+					"spoditor.io/my_feature": "dummy value",
+				},
+			}},
+			want: map[QualifiedName]string{
+				{
+					Qualifier: "feature",
+					Name:      "my",
+				}: "dummy value",
+			},
+		},
+		{
+			name: "underscore in feature name with alternate separator",
+			c:    &PrefixedCollector{Prefix: Prefix, Separator: "@"},
+			args: args{accessor: &v1.ObjectMeta{
+				Annotations: map[string]string{
+					"spoditor.io/my_feature":     "dummy value",
+					"spoditor.io/my_feature@1-2": "qualified value",
+				},
+			}},
+			want: map[QualifiedName]string{
+				{
+					Name: "my_feature",
+				}: "dummy value",
+				{
+					Qualifier: "1-2",
+					Name:      "my_feature",
+				}: "qualified value",
+			},
+		},
+		{
+			name: "multi-character separator",
+			c:    &PrefixedCollector{Prefix: Prefix, Separator: ".."},
+			args: args{accessor: &v1.ObjectMeta{
+				Annotations: map[string]string{
+					"spoditor.io/my_feature..3-": "dummy value",
+				},
+			}},
+			want: map[QualifiedName]string{
+				{
+					Qualifier: "3-",
+					Name:      "my_feature",
+				}: "dummy value",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -119,6 +190,24 @@ func TestPodQualifier(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "common pod qualifier included in a list",
+			q:    CommonPodQualifier,
+			args: args{
+				ordinal: 4,
+				q:       "0,2,4-",
+			},
+			want: true,
+		},
+		{
+			name: "common pod qualifier excluded from a list",
+			q:    CommonPodQualifier,
+			args: args{
+				ordinal: 1,
+				q:       "0,2,",
+			},
+			want: false,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -128,3 +217,234 @@ func TestPodQualifier(t *testing.T) {
 		})
 	}
 }
+
+func TestCommonPodQualifier_List(t *testing.T) {
+	tests := []struct {
+		qualifier string
+		matching  []int
+	}{
+		{qualifier: "0,2", matching: []int{0, 2}},
+		{qualifier: "0,2,5-", matching: []int{0, 2, 5, 6, 7}},
+		{qualifier: "-1,6", matching: []int{0, 1, 6}},
+		{qualifier: "1-2,4-5", matching: []int{1, 2, 4, 5}},
+		{qualifier: "odd,0", matching: []int{0, 1, 3, 5, 7}},
+		{qualifier: " 3 , 4 ", matching: []int{3, 4}},
+		{qualifier: "0,,2,", matching: []int{0, 2}},
+		{qualifier: "2,2", matching: []int{2}},
+		{qualifier: ",", matching: nil},
+		{qualifier: "0,x", matching: []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.qualifier, func(t *testing.T) {
+			var got []int
+			for ordinal := 0; ordinal < 8; ordinal++ {
+				if CommonPodQualifier(ordinal, tt.qualifier) {
+					got = append(got, ordinal)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.matching) {
+				t.Errorf("CommonPodQualifier() matched %v, want %v", got, tt.matching)
+			}
+		})
+	}
+}
+
+func TestCommonPodQualifier_BracketRanges(t *testing.T) {
+	tests := []struct {
+		qualifier string
+		matching  []int
+	}{
+		{qualifier: "1-5", matching: []int{1, 2, 3, 4, 5}},
+		{qualifier: "[1-5]", matching: []int{1, 2, 3, 4, 5}},
+		{qualifier: "[1-5)", matching: []int{1, 2, 3, 4}},
+		{qualifier: "(1-5]", matching: []int{2, 3, 4, 5}},
+		{qualifier: "(1-5)", matching: []int{2, 3, 4}},
+		{qualifier: "1-5)", matching: []int{1, 2, 3, 4}},
+		{qualifier: "(1-5", matching: []int{2, 3, 4, 5}},
+		{qualifier: "[0-3)", matching: []int{0, 1, 2}},
+		{qualifier: "(2-3)", matching: nil},
+		{qualifier: "[0-2),6", matching: []int{0, 1, 6}},
+		{qualifier: ")1-5(", matching: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.qualifier, func(t *testing.T) {
+			var got []int
+			for ordinal := 0; ordinal < 8; ordinal++ {
+				if CommonPodQualifier(ordinal, tt.qualifier) {
+					got = append(got, ordinal)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.matching) {
+				t.Errorf("CommonPodQualifier() matched %v, want %v", got, tt.matching)
+			}
+		})
+	}
+}
+
+func TestCommonPodQualifier_Parity(t *testing.T) {
+	tests := []struct {
+		qualifier string
+		matching  []int
+	}{
+		{qualifier: "even", matching: []int{0, 2, 4, 6}},
+		{qualifier: "odd", matching: []int{1, 3, 5, 7}},
+		{qualifier: "EVEN", matching: []int{0, 2, 4, 6}},
+		{qualifier: "Odd", matching: []int{1, 3, 5, 7}},
+		{qualifier: "even,7", matching: []int{0, 2, 4, 6, 7}},
+		{qualifier: "odd,4-", matching: []int{1, 3, 4, 5, 6, 7}},
+		{qualifier: "evens", matching: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.qualifier, func(t *testing.T) {
+			var got []int
+			for ordinal := 0; ordinal < 8; ordinal++ {
+				if CommonPodQualifier(ordinal, tt.qualifier) {
+					got = append(got, ordinal)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.matching) {
+				t.Errorf("CommonPodQualifier() matched %v, want %v", got, tt.matching)
+			}
+		})
+	}
+}
+
+func TestQualifiedName_Key(t *testing.T) {
+	tests := []struct {
+		name string
+		q    QualifiedName
+		want string
+	}{
+		{name: "without qualifier", q: QualifiedName{Name: "host-port"}, want: "spoditor.io/host-port"},
+		{name: "with qualifier", q: QualifiedName{Name: "host-port", Qualifier: "1-2"}, want: "spoditor.io/host-port_1-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.q.Key(); got != tt.want {
+				t.Errorf("Key() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortedKeys(t *testing.T) {
+	annotations := map[QualifiedName]string{
+		{Name: "mount-volume", Qualifier: "3-5"}: "",
+		{Name: "host-port"}:                      "",
+		{Name: "mount-volume", Qualifier: "0-2"}: "",
+		{Name: "mount-volume"}:                   "",
+	}
+	want := []QualifiedName{
+		{Name: "host-port"},
+		{Name: "mount-volume"},
+		{Name: "mount-volume", Qualifier: "0-2"},
+		{Name: "mount-volume", Qualifier: "3-5"},
+	}
+	if got := SortedKeys(annotations); !reflect.DeepEqual(got, want) {
+		t.Errorf("SortedKeys() = %v, want %v", got, want)
+	}
+}
+
+func TestKeyOf(t *testing.T) {
+	q := QualifiedName{Name: "host-port", Qualifier: "1-2"}
+	tests := []struct {
+		name string
+		c    QualifiedAnnotationCollector
+		want string
+	}{
+		{name: "default collector", c: Collector, want: "spoditor.io/host-port_1-2"},
+		{name: "custom prefix", c: &PrefixedCollector{Prefix: "acme.example.com/"}, want: "acme.example.com/host-port_1-2"},
+		{name: "custom separator", c: &PrefixedCollector{Prefix: Prefix, Separator: "."}, want: "spoditor.io/host-port.1-2"},
+		{
+			name: "collector without keys",
+			c: CollectorFunc(func(v1.ObjectMetaAccessor) map[QualifiedName]string {
+				return nil
+			}),
+			want: "spoditor.io/host-port_1-2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KeyOf(tt.c, q); got != tt.want {
+				t.Errorf("KeyOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseError_Error(t *testing.T) {
+	err := NewParseError(QualifiedName{Name: "host-port", Qualifier: "1-2"}, "{", errors.New("unexpected end of JSON input"))
+
+	if got, want := err.Error(), "invalid host-port configuration in spoditor.io/host-port_1-2: unexpected end of JSON input"; got != want {
+		t.Errorf("Error() = %v, want %v", got, want)
+	}
+
+	// With a configured collector, the key is named as written
+	defer func(c QualifiedAnnotationCollector) { Collector = c }(Collector)
+	Collector = &PrefixedCollector{Prefix: "acme.example.com/", Separator: "."}
+	if got, want := err.Error(), "invalid host-port configuration in acme.example.com/host-port.1-2: unexpected end of JSON input"; got != want {
+		t.Errorf("Error() = %v, want %v", got, want)
+	}
+}
+
+func TestPrefixedCollector_Collect_Malformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr error
+	}{
+		{name: "qualifier without feature name", key: "spoditor.io/_0-2", wantErr: ErrMissingFeatureName},
+		{name: "trailing separator", key: "spoditor.io/mount-volume_", wantErr: ErrEmptyQualifier},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []error
+			var keys []any
+			original := log
+			log = logr.New(&errorSink{
+				LogSink: funcr.New(func(_, _ string) {}, funcr.Options{}).GetSink(),
+				record: func(err error, kv []any) {
+					errs = append(errs, err)
+					keys = append(keys, kv...)
+				},
+			})
+			defer func() { log = original }()
+
+			got := Collector.Collect(&v1.ObjectMeta{
+				Annotations: map[string]string{
+					tt.key:                     "dropped",
+					"spoditor.io/mount-volume": "kept",
+				},
+			})
+
+			want := map[QualifiedName]string{{Name: "mount-volume"}: "kept"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Collect() = %v, want %v", got, want)
+			}
+			if len(errs) != 1 || !errors.Is(errs[0], tt.wantErr) {
+				t.Errorf("Collect() logged errors %v, want %v", errs, tt.wantErr)
+			}
+			if !slices.Contains(keys, any(tt.key)) {
+				t.Errorf("Collect() logged %v, want key %q", keys, tt.key)
+			}
+		})
+	}
+}
+
+// errorSink records the errors logged through it
+type errorSink struct {
+	logr.LogSink
+	record func(err error, keysAndValues []any)
+}
+
+func (s *errorSink) Error(err error, msg string, keysAndValues ...any) {
+	s.record(err, keysAndValues)
+}
+
+func (s *errorSink) WithValues(keysAndValues ...any) logr.LogSink {
+	return s
+}
+
+func (s *errorSink) WithName(name string) logr.LogSink {
+	return s
+}
@@ -0,0 +1,60 @@
+package annotationtest
+
+import (
+	"fmt"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Apply parses the annotations with the handler's parser and mutates a copy of
+// spec with the resulting configuration, as the webhook would for a pod with
+// the given mutation context. The spec is returned unchanged, as a copy, when
+// the annotations hold no configuration for the handler. Handlers that also
+// mutate pod metadata get an empty ObjectMeta, so errors of their metadata
+// path surface too; use ApplyPod to check the metadata they set.
+func Apply(h annotation.Handler, annotations map[annotation.QualifiedName]string, spec *corev1.PodSpec, mc annotation.MutationContext) (*corev1.PodSpec, error) {
+	pod := &corev1.Pod{}
+	if spec != nil {
+		pod.Spec = *spec
+	}
+
+	result, err := ApplyPod(h, annotations, pod, mc)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Spec, nil
+}
+
+// ApplyOrdinal is Apply for a pod that only needs its ordinal set
+func ApplyOrdinal(h annotation.Handler, annotations map[annotation.QualifiedName]string, spec *corev1.PodSpec, ordinal int) (*corev1.PodSpec, error) {
+	return Apply(h, annotations, spec, annotation.MutationContext{Ordinal: ordinal})
+}
+
+// ApplyPod is Apply for a whole pod: it mutates a copy of the pod spec and,
+// for handlers implementing annotation.MetadataHandler, of the pod metadata,
+// in the order the webhook runs them
+func ApplyPod(h annotation.Handler, annotations map[annotation.QualifiedName]string, pod *corev1.Pod, mc annotation.MutationContext) (*corev1.Pod, error) {
+	result := pod.DeepCopy()
+	if result == nil {
+		result = &corev1.Pod{}
+	}
+
+	config, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+	if config == nil {
+		return result, nil
+	}
+
+	if err := h.Mutate(&result.Spec, mc, config); err != nil {
+		return nil, fmt.Errorf("mutation error: %w", err)
+	}
+	if mh, ok := h.(annotation.MetadataHandler); ok {
+		if err := mh.MutateMeta(&result.ObjectMeta, mc, config); err != nil {
+			return nil, fmt.Errorf("metadata mutation error: %w", err)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package annotationtest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/labels"
+	"github.com/golem-base/spoditor/internal/annotation/ports"
+	"github.com/golem-base/spoditor/internal/annotation/serviceaccount"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestApply(t *testing.T) {
+	spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+
+	tests := []struct {
+		name        string
+		handler     annotation.Handler
+		annotations map[annotation.QualifiedName]string
+		ordinal     int
+		want        *corev1.PodSpec
+		wantErr     bool
+	}{
+		{
+			name:        "no configuration leaves the spec unchanged",
+			handler:     &ports.HostPortHandler{},
+			annotations: map[annotation.QualifiedName]string{},
+			ordinal:     1,
+			want:        spec,
+		},
+		{
+			name:    "host-port",
+			handler: &ports.HostPortHandler{},
+			annotations: map[annotation.QualifiedName]string{
+				{Name: ports.HostPort}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			},
+			ordinal: 1,
+			want: &corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  "web",
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, HostPort: 30001}},
+				Env: []corev1.EnvVar{
+					{Name: ports.PodOrdinal, Value: "1"},
+					{Name: "PORT_http", Value: "30001"},
+				},
+			}}},
+		},
+		{
+			name:    "service-account for an excluded ordinal",
+			handler: &serviceaccount.ServiceAccountHandler{},
+			annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "0", Name: serviceaccount.ServiceAccount}: `{"serviceAccountName":"leader"}`,
+			},
+			ordinal: 1,
+			want:    spec,
+		},
+		{
+			name:    "parse error",
+			handler: &serviceaccount.ServiceAccountHandler{},
+			annotations: map[annotation.QualifiedName]string{
+				{Name: serviceaccount.ServiceAccount}: `{`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyOrdinal(tt.handler, tt.annotations, spec, tt.ordinal)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyOrdinal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) && !tt.wantErr {
+				t.Errorf("ApplyOrdinal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The supplied spec is never mutated
+	if len(spec.Containers[0].Ports) != 0 {
+		t.Errorf("Apply() mutated the supplied spec: %v", spec)
+	}
+}
+
+func TestApplyPod(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}}
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "1-", Name: labels.InjectOrdinalLabel}: "shard",
+	}
+
+	got, err := ApplyPod(&labels.OrdinalLabelHandler{}, annotations, pod, annotation.MutationContext{Ordinal: 2})
+	if err != nil {
+		t.Fatalf("ApplyPod() error = %v", err)
+	}
+	if want := map[string]string{"shard": "2"}; !reflect.DeepEqual(got.Labels, want) {
+		t.Errorf("ApplyPod() labels = %v, want %v", got.Labels, want)
+	}
+
+	// The supplied pod is never mutated
+	if pod.Labels != nil {
+		t.Errorf("ApplyPod() mutated the supplied pod: %v", pod.Labels)
+	}
+}
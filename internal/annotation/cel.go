@@ -0,0 +1,211 @@
+package annotation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"k8s.io/utils/lru"
+)
+
+const (
+	// CELPrefix marks a qualifier as a CEL expression over the pod ordinal
+	// and the replicas of the StatefulSet, e.g. "cel:ordinal % 3 == 0 &&
+	// ordinal < 9" or "cel:ordinal >= replicas - 2"
+	CELPrefix = "cel:"
+
+	// celProgramCacheSize bounds the number of compiled expressions kept
+	celProgramCacheSize = 256
+	// celCostLimit bounds the cost of evaluating an expression, so a
+	// qualifier can't stall admission, e.g. with nested comprehensions
+	celCostLimit = 10000
+)
+
+var (
+	// celEnv is created on first use so plain qualifiers never pay for it
+	celEnv = sync.OnceValues(func() (*cel.Env, error) {
+		return cel.NewEnv(
+			cel.Variable("ordinal", cel.IntType),
+			cel.Variable("replicas", cel.IntType),
+		)
+	})
+
+	// celPrograms caches compiled expressions by source. Compile errors aren't
+	// cached, so invalid qualifiers can't crowd out the valid ones.
+	celPrograms = lru.New(celProgramCacheSize)
+)
+
+// compileCEL compiles a CEL qualifier expression, without its prefix
+func compileCEL(expr string) (cel.Program, error) {
+	if cached, ok := celPrograms.Get(expr); ok {
+		return cached.(cel.Program), nil
+	}
+
+	program, err := doCompileCEL(expr)
+	if err != nil {
+		return nil, err
+	}
+	celPrograms.Add(expr, program)
+	return program, nil
+}
+
+func doCompileCEL(expr string) (cel.Program, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid CEL qualifier %q: %w", expr, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("invalid CEL qualifier %q: must evaluate to a bool, not %s", expr, ast.OutputType())
+	}
+
+	return env.Program(ast, cel.CostLimit(celCostLimit))
+}
+
+// matchCEL evaluates a CEL qualifier expression, without its prefix, for the
+// ordinal. Without the replicas, expressions depending on them fail.
+func matchCEL(ordinal int, replicas *int64, expr string) (bool, error) {
+	program, err := compileCEL(expr)
+	if err != nil {
+		return false, err
+	}
+
+	vars := map[string]any{"ordinal": int64(ordinal)}
+	if replicas != nil {
+		vars["replicas"] = *replicas
+	}
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate CEL qualifier %q: %w", expr, err)
+	}
+
+	match, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("CEL qualifier %q evaluated to %v, not a bool", expr, out.Value())
+	}
+	return match, nil
+}
+
+// ValidateQualifier reports why a qualifier can't select any pod, such as a
+// malformed range or a CEL expression that doesn't compile
+func ValidateQualifier(qualifier string) error {
+	if qualifier == "" {
+		return nil
+	}
+
+	if expr, ok := strings.CutPrefix(qualifier, CELPrefix); ok {
+		_, err := compileCEL(expr)
+		return err
+	}
+
+	for _, q := range strings.Split(qualifier, ",") {
+		q = strings.TrimSpace(q)
+		if q == "" {
+			continue
+		}
+		if keyword := strings.ToLower(q); keyword == EvenQualifier || keyword == OddQualifier {
+			continue
+		}
+		if !rangeRegex.MatchString(q) && !bracketRangeRegex.MatchString(q) && !exactNumberRegex.MatchString(q) &&
+			!lowerBoundRegex.MatchString(q) && !upperBoundRegex.MatchString(q) {
+			return fmt.Errorf("invalid qualifier %q", q)
+		}
+	}
+
+	return nil
+}
+
+// QualifierCEL returns a CEL expression over an int "ordinal" variable that
+// selects the same pods as the qualifier, e.g. "ordinal >= 1 && ordinal <= 5"
+// for "1-5", so policies such as ValidatingAdmissionPolicies can share the
+// webhook's qualifier semantics. The empty qualifier selects every pod and
+// yields "true"; CEL qualifiers yield their expression as it is.
+func QualifierCEL(qualifier string) (string, error) {
+	if qualifier == "" {
+		return "true", nil
+	}
+
+	if expr, ok := strings.CutPrefix(qualifier, CELPrefix); ok {
+		if _, err := compileCEL(expr); err != nil {
+			return "", err
+		}
+		return expr, nil
+	}
+
+	if !strings.Contains(qualifier, ",") {
+		expr, ok := qualifierElementCEL(qualifier)
+		if !ok {
+			return "", fmt.Errorf("invalid qualifier %q", qualifier)
+		}
+		return expr, nil
+	}
+
+	// A list matches when any element matches
+	var exprs []string
+	for _, q := range strings.Split(qualifier, ",") {
+		if q = strings.TrimSpace(q); q == "" {
+			continue
+		}
+		expr, ok := qualifierElementCEL(q)
+		if !ok {
+			return "", fmt.Errorf("invalid qualifier %q", q)
+		}
+		if strings.Contains(expr, "&&") {
+			expr = "(" + expr + ")"
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 0 {
+		return "false", nil
+	}
+	return strings.Join(exprs, " || "), nil
+}
+
+// qualifierElementCEL returns the CEL expression of a single qualifier that
+// isn't a list, reporting whether the qualifier is well-formed
+func qualifierElementCEL(q string) (string, bool) {
+	switch strings.ToLower(q) {
+	case EvenQualifier:
+		return "ordinal % 2 == 0", true
+	case OddQualifier:
+		return "ordinal % 2 == 1", true
+	}
+
+	between := func(min, max int) string {
+		return fmt.Sprintf("ordinal >= %d && ordinal <= %d", min, max)
+	}
+	switch {
+	case rangeRegex.MatchString(q):
+		bounds := strings.Split(q, "-")
+		min, _ := strconv.Atoi(bounds[0])
+		max, _ := strconv.Atoi(bounds[1])
+		return between(min, max), true
+	case bracketRangeRegex.MatchString(q):
+		m := bracketRangeRegex.FindStringSubmatch(q)
+		min, _ := strconv.Atoi(m[2])
+		max, _ := strconv.Atoi(m[3])
+		if m[1] == "(" {
+			min++
+		}
+		if m[4] == ")" {
+			max--
+		}
+		return between(min, max), true
+	case exactNumberRegex.MatchString(q):
+		n, _ := strconv.Atoi(q)
+		return fmt.Sprintf("ordinal == %d", n), true
+	case lowerBoundRegex.MatchString(q):
+		min, _ := strconv.Atoi(strings.TrimSuffix(q, "-"))
+		return fmt.Sprintf("ordinal >= %d", min), true
+	case upperBoundRegex.MatchString(q):
+		max, _ := strconv.Atoi(strings.TrimPrefix(q, "-"))
+		return fmt.Sprintf("ordinal <= %d", max), true
+	}
+	return "", false
+}
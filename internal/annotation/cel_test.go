@@ -0,0 +1,184 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestCommonPodQualifier_CEL(t *testing.T) {
+	tests := []struct {
+		qualifier string
+		matching  []int
+	}{
+		{qualifier: "cel:ordinal % 3 == 0 && ordinal < 9", matching: []int{0, 3, 6}},
+		{qualifier: "cel:ordinal in [1, 4]", matching: []int{1, 4}},
+		{qualifier: "cel:ordinal > 7 || ordinal == 2", matching: []int{2, 8, 9, 10, 11}},
+		{qualifier: "cel:true", matching: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{qualifier: "cel:ordinal +", matching: nil},
+		{qualifier: "cel:ordinal * 2", matching: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.qualifier, func(t *testing.T) {
+			var got []int
+			for ordinal := 0; ordinal < 12; ordinal++ {
+				if CommonPodQualifier(ordinal, tt.qualifier) {
+					got = append(got, ordinal)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.matching) {
+				t.Errorf("CommonPodQualifier() matched %v, want %v", got, tt.matching)
+			}
+		})
+	}
+}
+
+func TestMutationContext_Selects(t *testing.T) {
+	sts := func(replicas *int32) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Replicas: replicas}}
+	}
+	tests := []struct {
+		name        string
+		qualifier   string
+		statefulSet *appsv1.StatefulSet
+		matching    []int
+	}{
+		{name: "last two of five", qualifier: "cel:ordinal >= replicas - 2", statefulSet: sts(ptr.To[int32](5)), matching: []int{3, 4, 5, 6, 7}},
+		{name: "lower half", qualifier: "cel:ordinal < replicas / 2", statefulSet: sts(ptr.To[int32](6)), matching: []int{0, 1, 2}},
+		{name: "replicas defaulting to one", qualifier: "cel:ordinal < replicas", statefulSet: sts(nil), matching: []int{0}},
+		{name: "unknown StatefulSet", qualifier: "cel:ordinal < replicas", matching: nil},
+		{name: "unknown StatefulSet short-circuited", qualifier: "cel:ordinal == 0 || ordinal < replicas", matching: []int{0}},
+		{name: "plain qualifier", qualifier: "2-3", statefulSet: sts(ptr.To[int32](5)), matching: []int{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for ordinal := 0; ordinal < 8; ordinal++ {
+				mc := MutationContext{Ordinal: ordinal, StatefulSet: tt.statefulSet}
+				if mc.Selects(tt.qualifier) {
+					got = append(got, ordinal)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.matching) {
+				t.Errorf("Selects() matched %v, want %v", got, tt.matching)
+			}
+		})
+	}
+}
+
+func TestValidateQualifier(t *testing.T) {
+	tests := []struct {
+		name      string
+		qualifier string
+		wantErr   string
+	}{
+		{name: "empty", qualifier: ""},
+		{name: "range", qualifier: "1-3"},
+		{name: "list", qualifier: "0,2,5-"},
+		{name: "bracketed range", qualifier: "[0-3),5"},
+		{name: "parity keywords", qualifier: "Even,odd"},
+		{name: "unbalanced brackets", qualifier: "[[0-3)", wantErr: `invalid qualifier "[[0-3)"`},
+		{name: "valid CEL", qualifier: "cel:ordinal % 2 == 0"},
+		{name: "malformed range", qualifier: "1-3-5", wantErr: `invalid qualifier "1-3-5"`},
+		{name: "malformed list element", qualifier: "0,x", wantErr: `invalid qualifier "x"`},
+		{name: "CEL syntax error", qualifier: "cel:ordinal %", wantErr: `invalid CEL qualifier "ordinal %"`},
+		{name: "CEL replicas", qualifier: "cel:ordinal >= replicas - 2"},
+		{name: "CEL unknown variable", qualifier: "cel:shards > 3", wantErr: "undeclared reference to 'shards'"},
+		{name: "CEL non-bool result", qualifier: "cel:ordinal + 1", wantErr: "must evaluate to a bool, not int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateQualifier(tt.qualifier)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateQualifier() error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateQualifier() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQualifierCEL(t *testing.T) {
+	tests := []struct {
+		name      string
+		qualifier string
+		want      string
+		wantErr   bool
+	}{
+		{name: "empty", qualifier: "", want: "true"},
+		{name: "range", qualifier: "1-5", want: "ordinal >= 1 && ordinal <= 5"},
+		{name: "exact", qualifier: "3", want: "ordinal == 3"},
+		{name: "lower bound", qualifier: "3-", want: "ordinal >= 3"},
+		{name: "upper bound", qualifier: "-5", want: "ordinal <= 5"},
+		{name: "half-open range", qualifier: "[0-3)", want: "ordinal >= 0 && ordinal <= 2"},
+		{name: "open range", qualifier: "(1-5)", want: "ordinal >= 2 && ordinal <= 4"},
+		{name: "parity", qualifier: "Even", want: "ordinal % 2 == 0"},
+		{name: "list", qualifier: "0, 2-4,odd,7-", want: "ordinal == 0 || (ordinal >= 2 && ordinal <= 4) || ordinal % 2 == 1 || ordinal >= 7"},
+		{name: "CEL", qualifier: "cel:ordinal % 3 == 0", want: "ordinal % 3 == 0"},
+		{name: "malformed range", qualifier: "1-3-5", wantErr: true},
+		{name: "malformed list element", qualifier: "0,x", wantErr: true},
+		{name: "malformed CEL", qualifier: "cel:ordinal %", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := QualifierCEL(tt.qualifier)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("QualifierCEL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("QualifierCEL() = %q, want %q", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// The expression selects the same ordinals as the qualifier
+			for ordinal := 0; ordinal < 12; ordinal++ {
+				match, err := matchCEL(ordinal, nil, got)
+				if err != nil {
+					t.Fatalf("matchCEL() error = %v", err)
+				}
+				if want := CommonPodQualifier(ordinal, tt.qualifier); match != want {
+					t.Errorf("%q matches ordinal %d: %v, qualifier %q: %v", got, ordinal, match, tt.qualifier, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileCEL_Cache(t *testing.T) {
+	celPrograms.Clear()
+
+	if _, err := compileCEL("ordinal %"); err == nil {
+		t.Fatal("compileCEL() of a syntax error succeeded")
+	}
+	if n := celPrograms.Len(); n != 0 {
+		t.Errorf("compileCEL() cached %d failed compilations, want none", n)
+	}
+
+	for i := 0; i < celProgramCacheSize+10; i++ {
+		if _, err := compileCEL(fmt.Sprintf("ordinal == %d", i)); err != nil {
+			t.Fatalf("compileCEL() error = %v", err)
+		}
+	}
+	if n := celPrograms.Len(); n != celProgramCacheSize {
+		t.Errorf("compileCEL() cached %d programs, want at most %d", n, celProgramCacheSize)
+	}
+}
+
+func TestMatchCEL_CostLimit(t *testing.T) {
+	digits := "[0, 1, 2, 3, 4, 5, 6, 7, 8, 9]"
+	expr := fmt.Sprintf("%[1]s.all(a, %[1]s.all(b, %[1]s.all(c, %[1]s.all(d, a + b + c + d + ordinal >= 0))))", digits)
+
+	_, err := matchCEL(0, nil, expr)
+	if err == nil || !strings.Contains(err.Error(), "cost limit") {
+		t.Errorf("matchCEL() error = %v, want the cost limit exceeded", err)
+	}
+}
@@ -0,0 +1,10 @@
+package annotation
+
+// WildcardContainer is the container name matching every container of the pod
+const WildcardContainer = "*"
+
+// MatchesContainer reports whether a container config named name applies to
+// the container called container, either by name or through the wildcard
+func MatchesContainer(name, container string) bool {
+	return name == WildcardContainer || name == container
+}
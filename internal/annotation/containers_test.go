@@ -0,0 +1,22 @@
+package annotation
+
+import "testing"
+
+func TestMatchesContainer(t *testing.T) {
+	tests := []struct {
+		name      string
+		container string
+		want      bool
+	}{
+		{name: "web", container: "web", want: true},
+		{name: "web", container: "api", want: false},
+		{name: "*", container: "web", want: true},
+		{name: "*", container: "api", want: true},
+		{name: "", container: "web", want: false},
+	}
+	for _, tt := range tests {
+		if got := MatchesContainer(tt.name, tt.container); got != tt.want {
+			t.Errorf("MatchesContainer(%q, %q) = %v, want %v", tt.name, tt.container, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package deadline
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// ActiveDeadline is the annotation key for active deadline configuration
+	ActiveDeadline = "active-deadline"
+)
+
+var log = logf.Log.WithName("active_deadline")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed deadline.schema.json
+var schema []byte
+
+// activeDeadlineConfig holds the deadline configuration with its pod qualifier
+type activeDeadlineConfig struct {
+	qualifier string                     // Which pods this applies to
+	cfg       *activeDeadlineConfigValue // The actual deadline configuration
+}
+
+// activeDeadlineConfigValue represents the JSON structure of the deadline
+// configuration, giving each pod base - ordinal*step seconds, floored at min
+type activeDeadlineConfigValue struct {
+	Base int64  `json:"base"`
+	Step int64  `json:"step,omitempty"`
+	Min  *int64 `json:"min,omitempty"`
+}
+
+// seconds computes the deadline for the given ordinal. The floor defaults to
+// one second since the API server rejects non-positive deadlines.
+func (c *activeDeadlineConfigValue) seconds(ordinal int) int64 {
+	floor := int64(1)
+	if c.Min != nil {
+		floor = *c.Min
+	}
+	scale := annotation.OrdinalScale{Base: c.Base, Step: -c.Step, Min: &floor}
+	return scale.Value(ordinal)
+}
+
+// Ensure ActiveDeadlineHandler implements Handler interface
+var _ annotation.Handler = (*ActiveDeadlineHandler)(nil)
+
+// ActiveDeadlineHandler sets spec.activeDeadlineSeconds, shrinking it for
+// higher ordinals
+type ActiveDeadlineHandler struct{}
+
+// Mutate sets the active deadline computed for the pod ordinal
+func (h *ActiveDeadlineHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*activeDeadlineConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*activeDeadlineConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply sets the active deadline of a single configuration
+func (c *activeDeadlineConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	seconds := c.cfg.seconds(mc.Ordinal)
+	l.Info("setting active deadline", "seconds", seconds)
+	spec.ActiveDeadlineSeconds = &seconds
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *ActiveDeadlineHandler) Name() string {
+	return ActiveDeadline
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *ActiveDeadlineHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for active deadline annotations
+func (h *ActiveDeadlineHandler) GetParser() annotation.Parser {
+	return activeDeadlineParser
+}
+
+// activeDeadlineParser parses every active deadline annotation, whatever its
+// qualifier, into an activeDeadlineConfig, returning them in annotation key order
+var activeDeadlineParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*activeDeadlineConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != ActiveDeadline {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing active deadline configuration")
+
+		value := &activeDeadlineConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse active deadline configuration")
+			return nil, fmt.Errorf("invalid active deadline configuration: %w", err)
+		}
+
+		switch {
+		case value.Base <= 0:
+			return nil, fmt.Errorf("invalid active deadline configuration: base must be positive, got %d", value.Base)
+		case value.Step < 0:
+			return nil, fmt.Errorf("invalid active deadline configuration: step must not be negative, got %d", value.Step)
+		case value.Min != nil && *value.Min <= 0:
+			return nil, fmt.Errorf("invalid active deadline configuration: min must be positive, got %d", *value.Min)
+		}
+
+		configs = append(configs, &activeDeadlineConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,184 @@
+package deadline
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestActiveDeadlineHandler_Mutate(t *testing.T) {
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 0,
+				cfg: []*activeDeadlineConfig{{
+					qualifier: "1-2",
+					cfg:       &activeDeadlineConfigValue{Base: 3600},
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "replace existing deadline",
+			args: args{
+				spec:    &corev1.PodSpec{ActiveDeadlineSeconds: ptr.To[int64](60)},
+				ordinal: 2,
+				cfg: []*activeDeadlineConfig{{
+					cfg: &activeDeadlineConfigValue{Base: 3600, Step: 600},
+				}},
+			},
+			want:    &corev1.PodSpec{ActiveDeadlineSeconds: ptr.To[int64](2400)},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ActiveDeadlineHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestActiveDeadlineHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: ActiveDeadline}:  `{"base":600}`,
+		{Qualifier: "1-", Name: ActiveDeadline}: `{"base":3600,"step":600}`,
+	}
+
+	for ordinal, want := range map[int]int64{0: 600, 1: 3000, 3: 1800} {
+		spec, err := annotationtest.ApplyOrdinal(&ActiveDeadlineHandler{}, annotations, &corev1.PodSpec{}, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		if spec.ActiveDeadlineSeconds == nil || *spec.ActiveDeadlineSeconds != want {
+			t.Errorf("ApplyOrdinal() ordinal %d activeDeadlineSeconds = %v, want %d", ordinal, spec.ActiveDeadlineSeconds, want)
+		}
+	}
+}
+
+func TestActiveDeadlineConfigValue_seconds(t *testing.T) {
+	tests := []struct {
+		cfg     activeDeadlineConfigValue
+		ordinal int
+		want    int64
+	}{
+		{cfg: activeDeadlineConfigValue{Base: 3600, Step: 600}, ordinal: 0, want: 3600},
+		{cfg: activeDeadlineConfigValue{Base: 3600, Step: 600}, ordinal: 3, want: 1800},
+		{cfg: activeDeadlineConfigValue{Base: 3600, Step: 600, Min: ptr.To[int64](900)}, ordinal: 4, want: 1200},
+		{cfg: activeDeadlineConfigValue{Base: 3600, Step: 600, Min: ptr.To[int64](900)}, ordinal: 5, want: 900},
+		{cfg: activeDeadlineConfigValue{Base: 3600, Step: 600, Min: ptr.To[int64](900)}, ordinal: 10, want: 900},
+		{cfg: activeDeadlineConfigValue{Base: 3600, Step: 600}, ordinal: 10, want: 1},
+		{cfg: activeDeadlineConfigValue{Base: 3600}, ordinal: 10, want: 3600},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%+v at %d", tt.cfg, tt.ordinal), func(t *testing.T) {
+			if got := tt.cfg.seconds(tt.ordinal); got != tt.want {
+				t.Errorf("seconds() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_activeDeadlineParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       activeDeadlineParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    activeDeadlineParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "1-", Name: ActiveDeadline}: `{"base":3600,"step":600,"min":900}`,
+			}},
+			want: []*activeDeadlineConfig{{
+				qualifier: "1-",
+				cfg:       &activeDeadlineConfigValue{Base: 3600, Step: 600, Min: ptr.To[int64](900)},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "missing base",
+			p:    activeDeadlineParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: ActiveDeadline}: `{"step":600}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "negative step",
+			p:    activeDeadlineParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: ActiveDeadline}: `{"base":3600,"step":-600}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "non-positive min",
+			p:    activeDeadlineParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: ActiveDeadline}: `{"base":3600,"min":0}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
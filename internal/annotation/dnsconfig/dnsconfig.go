@@ -0,0 +1,153 @@
+package dnsconfig
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// DNSConfig is the annotation key for pod DNS configuration
+	DNSConfig = "dns-config"
+)
+
+var log = logf.Log.WithName("dns_config")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed dnsconfig.schema.json
+var schema []byte
+
+// dnsConfig holds the DNS configuration with its pod qualifier
+type dnsConfig struct {
+	qualifier string               // Which pods this applies to
+	cfg       *corev1.PodDNSConfig // The DNS configuration to merge
+}
+
+// Ensure DNSConfigHandler implements Handler interface
+var _ annotation.Handler = (*DNSConfigHandler)(nil)
+
+// DNSConfigHandler merges resolver settings into spec.dnsConfig, e.g. to let
+// peers resolve their siblings through the StatefulSet's headless service
+type DNSConfigHandler struct{}
+
+// Mutate merges the configured DNS settings into the pod's, templating the
+// ordinal and StatefulSet name into nameservers and search domains
+func (h *DNSConfigHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*dnsConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*dnsConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		if err := c.apply(spec, mc, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply merges the DNS settings of a single configuration into the pod's
+func (c *dnsConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) error {
+	if spec.DNSConfig == nil {
+		spec.DNSConfig = &corev1.PodDNSConfig{}
+	}
+
+	template := func(s string) string {
+		return annotation.SubstituteSSName(annotation.SubstituteOrdinal(s, mc.Ordinal), mc.SSName)
+	}
+
+	for _, ns := range c.cfg.Nameservers {
+		ns = template(ns)
+		if !slices.Contains(spec.DNSConfig.Nameservers, ns) {
+			l.Info("adding nameserver", "nameserver", ns)
+			spec.DNSConfig.Nameservers = append(spec.DNSConfig.Nameservers, ns)
+		}
+	}
+
+	for _, search := range c.cfg.Searches {
+		search = template(search)
+		if !slices.Contains(spec.DNSConfig.Searches, search) {
+			l.Info("adding search domain", "search", search)
+			spec.DNSConfig.Searches = append(spec.DNSConfig.Searches, search)
+		}
+	}
+
+	// Options are keyed by name, a configured option replaces the pod's
+	for _, opt := range c.cfg.Options {
+		i := slices.IndexFunc(spec.DNSConfig.Options, func(o corev1.PodDNSConfigOption) bool { return o.Name == opt.Name })
+		if i >= 0 {
+			spec.DNSConfig.Options[i] = *opt.DeepCopy()
+			continue
+		}
+		spec.DNSConfig.Options = append(spec.DNSConfig.Options, *opt.DeepCopy())
+	}
+
+	return nil
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *DNSConfigHandler) Name() string {
+	return DNSConfig
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *DNSConfigHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for DNS configuration annotations
+func (h *DNSConfigHandler) GetParser() annotation.Parser {
+	return dnsConfigParser
+}
+
+// dnsConfigParser parses every DNS configuration annotation, whatever its
+// qualifier, into a dnsConfig, returning them in annotation key order
+var dnsConfigParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*dnsConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != DNSConfig {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing dns configuration")
+
+		value := &corev1.PodDNSConfig{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse dns configuration")
+			return nil, fmt.Errorf("invalid dns configuration: %w", err)
+		}
+
+		for _, opt := range value.Options {
+			if opt.Name == "" {
+				return nil, fmt.Errorf("invalid dns configuration: option without a name")
+			}
+		}
+
+		configs = append(configs, &dnsConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,192 @@
+package dnsconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestDNSConfigHandler_Mutate(t *testing.T) {
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 0,
+				cfg: []*dnsConfig{{
+					qualifier: "1-",
+					cfg:       &corev1.PodDNSConfig{Searches: []string{"{{ssName}}.svc.cluster.local"}},
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "template search domains",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 2,
+				cfg: []*dnsConfig{{
+					cfg: &corev1.PodDNSConfig{Searches: []string{
+						"{{ssName}}.default.svc.cluster.local",
+						"shard-{{ordinal}}.{{ssName}}.internal",
+					}},
+				}},
+			},
+			want: &corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{Searches: []string{
+				"peers.default.svc.cluster.local",
+				"shard-2.peers.internal",
+			}}},
+			wantErr: false,
+		},
+		{
+			name: "merge with existing config",
+			args: args{
+				spec: &corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+					Nameservers: []string{"10.0.0.10"},
+					Searches:    []string{"peers.default.svc.cluster.local"},
+					Options:     []corev1.PodDNSConfigOption{{Name: "ndots", Value: ptr.To("5")}, {Name: "edns0"}},
+				}},
+				ordinal: 1,
+				cfg: []*dnsConfig{{
+					cfg: &corev1.PodDNSConfig{
+						Nameservers: []string{"10.0.{{ordinal}}.53"},
+						Searches:    []string{"{{ssName}}.default.svc.cluster.local"},
+						Options:     []corev1.PodDNSConfigOption{{Name: "ndots", Value: ptr.To("2")}},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+				Nameservers: []string{"10.0.0.10", "10.0.1.53"},
+				Searches:    []string{"peers.default.svc.cluster.local"},
+				Options:     []corev1.PodDNSConfigOption{{Name: "ndots", Value: ptr.To("2")}, {Name: "edns0"}},
+			}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &DNSConfigHandler{}
+			mc := annotation.MutationContext{Ordinal: tt.args.ordinal, SSName: "peers"}
+			if err := h.Mutate(tt.args.spec, mc, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSConfigHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: DNSConfig}:  `{"nameservers":["10.0.0.10"]}`,
+		{Qualifier: "1-", Name: DNSConfig}: `{"searches":["{{ssName}}.default.svc.cluster.local"]}`,
+	}
+	h := &DNSConfigHandler{}
+
+	spec, err := annotationtest.Apply(h, annotations, &corev1.PodSpec{}, annotation.MutationContext{SSName: "db", Ordinal: 0})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := (&corev1.PodDNSConfig{Nameservers: []string{"10.0.0.10"}}); !reflect.DeepEqual(spec.DNSConfig, want) {
+		t.Errorf("Apply() ordinal 0 dnsConfig = %v, want %v", spec.DNSConfig, want)
+	}
+
+	spec, err = annotationtest.Apply(h, annotations, &corev1.PodSpec{}, annotation.MutationContext{SSName: "db", Ordinal: 3})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := (&corev1.PodDNSConfig{Searches: []string{"db.default.svc.cluster.local"}}); !reflect.DeepEqual(spec.DNSConfig, want) {
+		t.Errorf("Apply() ordinal 3 dnsConfig = %v, want %v", spec.DNSConfig, want)
+	}
+}
+
+func Test_dnsConfigParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       dnsConfigParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    dnsConfigParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "1-", Name: DNSConfig}: `{"searches":["{{ssName}}.default.svc.cluster.local"],"options":[{"name":"ndots","value":"2"}]}`,
+			}},
+			want: []*dnsConfig{{
+				qualifier: "1-",
+				cfg: &corev1.PodDNSConfig{
+					Searches: []string{"{{ssName}}.default.svc.cluster.local"},
+					Options:  []corev1.PodDNSConfigOption{{Name: "ndots", Value: ptr.To("2")}},
+				},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "option without a name",
+			p:    dnsConfigParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: DNSConfig}: `{"options":[{"value":"2"}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid json",
+			p:    dnsConfigParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: DNSConfig}: `{"searches":`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package downwardenv
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// DownwardEnv is the annotation key listing the containers that get the
+	// standard downward API env vars
+	DownwardEnv = "downward-env"
+)
+
+var log = logf.Log.WithName("downward_env")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed downwardenv.schema.json
+var schema []byte
+
+// standardEnv is the well-known downward API env block, in injection order
+var standardEnv = []corev1.EnvVar{
+	fieldRefEnv("POD_NAME", "metadata.name"),
+	fieldRefEnv("POD_NAMESPACE", "metadata.namespace"),
+	fieldRefEnv("POD_IP", "status.podIP"),
+	fieldRefEnv("NODE_NAME", "spec.nodeName"),
+}
+
+// fieldRefEnv builds an env var sourced from the given pod field
+func fieldRefEnv(name, fieldPath string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				APIVersion: "v1",
+				FieldPath:  fieldPath,
+			},
+		},
+	}
+}
+
+// downwardEnvConfig holds the target containers with their pod qualifier
+type downwardEnvConfig struct {
+	qualifier  string   // Which pods this applies to
+	containers []string // Names of the containers to inject into
+}
+
+// Ensure DownwardEnvHandler implements Handler interface
+var _ annotation.Handler = (*DownwardEnvHandler)(nil)
+
+// DownwardEnvHandler injects POD_NAME, POD_NAMESPACE, POD_IP and NODE_NAME
+// env vars into the named containers
+type DownwardEnvHandler struct{}
+
+// Mutate adds the standard downward API env vars to each named container,
+// leaving vars the container already defines alone
+func (h *DownwardEnvHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*downwardEnvConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*downwardEnvConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply adds the env vars to the containers of a single configuration
+func (c *downwardEnvConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	for _, name := range c.containers {
+		found := false
+		for i := range spec.Containers {
+			container := &spec.Containers[i]
+			if container.Name != name {
+				continue
+			}
+			found = true
+
+			for _, env := range standardEnv {
+				if hasEnv(container.Env, env.Name) {
+					l.Info("env var already present, skipping", "container", name, "env", env.Name)
+					continue
+				}
+				container.Env = append(container.Env, *env.DeepCopy())
+			}
+		}
+
+		if !found {
+			l.Info("container not found in pod spec", "container", name)
+		}
+	}
+}
+
+// hasEnv reports whether an env var with the given name exists
+func hasEnv(envVars []corev1.EnvVar, name string) bool {
+	for _, e := range envVars {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *DownwardEnvHandler) Name() string {
+	return DownwardEnv
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *DownwardEnvHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for downward env annotations
+func (h *DownwardEnvHandler) GetParser() annotation.Parser {
+	return downwardEnvParser
+}
+
+// downwardEnvParser parses the comma-separated lists of container names of
+// every downward env annotation, whatever its qualifier, into a
+// downwardEnvConfig, returning them in annotation key order
+var downwardEnvParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*downwardEnvConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != DownwardEnv {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing downward env configuration")
+
+		var containers []string
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				containers = append(containers, name)
+			}
+		}
+
+		if len(containers) == 0 {
+			return nil, fmt.Errorf("invalid downward env configuration: no container names given")
+		}
+
+		configs = append(configs, &downwardEnvConfig{
+			qualifier:  k.Qualifier,
+			containers: containers,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,194 @@
+package downwardenv
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestDownwardEnvHandler_Mutate(t *testing.T) {
+	fieldRef := func(name, path string) corev1.EnvVar {
+		return corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: path},
+			},
+		}
+	}
+
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+				ordinal: 0,
+				cfg: []*downwardEnvConfig{{
+					qualifier:  "1-2",
+					containers: []string{"app"},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			wantErr: false,
+		},
+		{
+			name: "inject standard vars into named container only",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "app"},
+					{Name: "other"},
+				}},
+				ordinal: 1,
+				cfg: []*downwardEnvConfig{{
+					containers: []string{"app"},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{
+					Name: "app",
+					Env: []corev1.EnvVar{
+						fieldRef("POD_NAME", "metadata.name"),
+						fieldRef("POD_NAMESPACE", "metadata.namespace"),
+						fieldRef("POD_IP", "status.podIP"),
+						fieldRef("NODE_NAME", "spec.nodeName"),
+					},
+				},
+				{Name: "other"},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "skip vars already present",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{
+					{
+						Name: "app",
+						Env:  []corev1.EnvVar{{Name: "POD_NAME", Value: "custom"}},
+					},
+				}},
+				ordinal: 1,
+				cfg: []*downwardEnvConfig{{
+					containers: []string{"app"},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{
+					Name: "app",
+					Env: []corev1.EnvVar{
+						{Name: "POD_NAME", Value: "custom"},
+						fieldRef("POD_NAMESPACE", "metadata.namespace"),
+						fieldRef("POD_IP", "status.podIP"),
+						fieldRef("NODE_NAME", "spec.nodeName"),
+					},
+				},
+			}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &DownwardEnvHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownwardEnvHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: DownwardEnv}:  "app",
+		{Qualifier: "1-", Name: DownwardEnv}: "sidecar",
+	}
+	pod := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "sidecar"}}}
+
+	for ordinal, want := range map[int]string{0: "app", 3: "sidecar"} {
+		spec, err := annotationtest.ApplyOrdinal(&DownwardEnvHandler{}, annotations, pod, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		for _, c := range spec.Containers {
+			if injected := len(c.Env) > 0; injected != (c.Name == want) {
+				t.Errorf("ApplyOrdinal() ordinal %d container %s env = %v, want env only in %s", ordinal, c.Name, c.Env, want)
+			}
+		}
+	}
+}
+
+func Test_downwardEnvParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       downwardEnvParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "container list",
+			p:    downwardEnvParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "0-1", Name: DownwardEnv}: "app, sidecar",
+			}},
+			want: []*downwardEnvConfig{{
+				qualifier:  "0-1",
+				containers: []string{"app", "sidecar"},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "empty container list",
+			p:    downwardEnvParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: DownwardEnv}: " , ",
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package downwardenv
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/labels"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// OrdinalFile is the annotation key for exposing the ordinal as a file
+	OrdinalFile = "ordinal-file"
+	// OrdinalFileVolume is the name of the downward API volume holding the file
+	OrdinalFileVolume = "spoditor-ordinal"
+	// ordinalFileName is the name of the file within the mount path
+	ordinalFileName = "ordinal"
+	// defaultOrdinalFileMountPath is where the volume is mounted when the
+	// configuration doesn't say
+	defaultOrdinalFileMountPath = "/etc/pod"
+)
+
+// ordinalFileSchema is the JSON Schema of the annotation value
+//
+//go:embed ordinalfile.schema.json
+var ordinalFileSchema []byte
+
+// ordinalFileConfig holds the ordinal file configuration with its pod qualifier
+type ordinalFileConfig struct {
+	qualifier string                  // Which pods this applies to
+	cfg       *ordinalFileConfigValue // The actual ordinal file configuration
+}
+
+// ordinalFileConfigValue represents the JSON structure of the ordinal file
+// configuration
+type ordinalFileConfigValue struct {
+	// Containers names the containers to mount the file into
+	Containers []string `json:"containers"`
+	// MountPath is the directory holding the ordinal file, /etc/pod when empty
+	MountPath string `json:"mountPath,omitempty"`
+	// Label is the pod label carrying the ordinal for the downward API,
+	// labels.DefaultOrdinalLabel when empty
+	Label string `json:"label,omitempty"`
+}
+
+// Ensure OrdinalFileHandler implements MetadataHandler interface
+var _ annotation.MetadataHandler = (*OrdinalFileHandler)(nil)
+
+// OrdinalFileHandler exposes the pod ordinal as a file, e.g. /etc/pod/ordinal,
+// for apps reading their identity from a file. The ordinal isn't a downward
+// API field, so the handler stamps it as a pod label and projects the label
+// into a downward API volume.
+type OrdinalFileHandler struct{}
+
+// Mutate adds the downward API volume and mounts it into the named containers
+func (h *OrdinalFileHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*ordinalFileConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*ordinalFileConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply adds the ordinal file volume of a single configuration and mounts it
+// into its containers
+func (c *ordinalFileConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	if !hasVolume(spec.Volumes, OrdinalFileVolume) {
+		l.Info("adding ordinal file volume", "label", c.cfg.label())
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: OrdinalFileVolume,
+			VolumeSource: corev1.VolumeSource{
+				DownwardAPI: &corev1.DownwardAPIVolumeSource{
+					Items: []corev1.DownwardAPIVolumeFile{{
+						Path: ordinalFileName,
+						FieldRef: &corev1.ObjectFieldSelector{
+							APIVersion: "v1",
+							FieldPath:  fmt.Sprintf("metadata.labels['%s']", c.cfg.label()),
+						},
+					}},
+				},
+			},
+		})
+	}
+
+	for _, name := range c.cfg.Containers {
+		for i := range spec.Containers {
+			container := &spec.Containers[i]
+			if container.Name != name {
+				continue
+			}
+			if hasVolumeMountAt(container.VolumeMounts, OrdinalFileVolume, c.cfg.mountPath()) {
+				l.Info("ordinal file already mounted, skipping", "container", name, "mountPath", c.cfg.mountPath())
+				continue
+			}
+
+			l.Info("mounting ordinal file", "container", name, "mountPath", c.cfg.mountPath())
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      OrdinalFileVolume,
+				MountPath: c.cfg.mountPath(),
+				ReadOnly:  true,
+			})
+		}
+	}
+}
+
+// MutateMeta sets the label the downward API volume reads the ordinal from
+func (h *OrdinalFileHandler) MutateMeta(meta *metav1.ObjectMeta, mc annotation.MutationContext, cfg any) error {
+	// Type assertion for our config
+	configs, ok := cfg.([]*ordinalFileConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*ordinalFileConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			continue
+		}
+		c.applyMeta(meta, mc)
+	}
+	return nil
+}
+
+// applyMeta sets the ordinal label of a single configuration
+func (c *ordinalFileConfig) applyMeta(meta *metav1.ObjectMeta, mc annotation.MutationContext) {
+	if meta.Labels == nil {
+		meta.Labels = make(map[string]string)
+	}
+	meta.Labels[c.cfg.label()] = strconv.Itoa(mc.Ordinal)
+}
+
+// mountPath returns the configured mount path or the default one
+func (c *ordinalFileConfigValue) mountPath() string {
+	if c.MountPath == "" {
+		return defaultOrdinalFileMountPath
+	}
+	return c.MountPath
+}
+
+// label returns the configured label key or the default one
+func (c *ordinalFileConfigValue) label() string {
+	if c.Label == "" {
+		return labels.DefaultOrdinalLabel
+	}
+	return c.Label
+}
+
+// hasVolume reports whether a volume with the given name exists
+func hasVolume(volumes []corev1.Volume, name string) bool {
+	for _, v := range volumes {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasVolumeMountAt reports whether the named volume is mounted at the path
+func hasVolumeMountAt(mounts []corev1.VolumeMount, name, path string) bool {
+	for _, m := range mounts {
+		if m.Name == name && m.MountPath == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *OrdinalFileHandler) Name() string {
+	return OrdinalFile
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *OrdinalFileHandler) Schema() []byte {
+	return ordinalFileSchema
+}
+
+// GetParser returns the parser for ordinal file annotations
+func (h *OrdinalFileHandler) GetParser() annotation.Parser {
+	return ordinalFileParser
+}
+
+// ordinalFileParser parses every ordinal file annotation, whatever its qualifier,
+// into an ordinalFileConfig, returning them in annotation key order. The single
+// ordinal file volume reads a single label, so all annotations must agree on it.
+var ordinalFileParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*ordinalFileConfig
+	var first annotation.QualifiedName
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != OrdinalFile {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing ordinal file configuration")
+
+		value := &ordinalFileConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse ordinal file configuration")
+			return nil, fmt.Errorf("invalid ordinal file configuration: %w", err)
+		}
+		if len(value.Containers) == 0 {
+			return nil, fmt.Errorf("invalid ordinal file configuration: no containers")
+		}
+		if errs := validation.IsQualifiedName(value.label()); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid ordinal file label %q: %s", value.label(), strings.Join(errs, "; "))
+		}
+		if len(configs) == 0 {
+			first = k
+		} else if value.label() != configs[0].cfg.label() {
+			return nil, fmt.Errorf("invalid ordinal file label %q: %s uses label %q",
+				value.label(), annotation.KeyOf(annotation.Collector, first), configs[0].cfg.label())
+		}
+
+		configs = append(configs, &ordinalFileConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
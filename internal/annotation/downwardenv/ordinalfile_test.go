@@ -0,0 +1,277 @@
+package downwardenv
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOrdinalFileHandler_Mutate(t *testing.T) {
+	volume := func(label string) corev1.Volume {
+		return corev1.Volume{
+			Name: OrdinalFileVolume,
+			VolumeSource: corev1.VolumeSource{
+				DownwardAPI: &corev1.DownwardAPIVolumeSource{
+					Items: []corev1.DownwardAPIVolumeFile{{
+						Path: "ordinal",
+						FieldRef: &corev1.ObjectFieldSelector{
+							APIVersion: "v1",
+							FieldPath:  "metadata.labels['" + label + "']",
+						},
+					}},
+				},
+			},
+		}
+	}
+	mount := func(path string) corev1.VolumeMount {
+		return corev1.VolumeMount{Name: OrdinalFileVolume, MountPath: path, ReadOnly: true}
+	}
+
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+				ordinal: 0,
+				cfg: []*ordinalFileConfig{{
+					qualifier: "1-",
+					cfg:       &ordinalFileConfigValue{Containers: []string{"app"}},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			wantErr: false,
+		},
+		{
+			name: "add the volume and mount it at the default path",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "other"}}},
+				ordinal: 2,
+				cfg: []*ordinalFileConfig{{
+					cfg: &ordinalFileConfigValue{Containers: []string{"app"}},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "app", VolumeMounts: []corev1.VolumeMount{mount("/etc/pod")}},
+					{Name: "other"},
+				},
+				Volumes: []corev1.Volume{volume("pod-ordinal")},
+			},
+			wantErr: false,
+		},
+		{
+			name: "custom label and mount path, once",
+			args: args{
+				spec: &corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "app", VolumeMounts: []corev1.VolumeMount{mount("/etc/identity")}},
+						{Name: "other"},
+					},
+					Volumes: []corev1.Volume{volume("example.com/ordinal")},
+				},
+				ordinal: 2,
+				cfg: []*ordinalFileConfig{{
+					cfg: &ordinalFileConfigValue{
+						Containers: []string{"app", "other"},
+						MountPath:  "/etc/identity",
+						Label:      "example.com/ordinal",
+					},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "app", VolumeMounts: []corev1.VolumeMount{mount("/etc/identity")}},
+					{Name: "other", VolumeMounts: []corev1.VolumeMount{mount("/etc/identity")}},
+				},
+				Volumes: []corev1.Volume{volume("example.com/ordinal")},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &OrdinalFileHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrdinalFileHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: OrdinalFile}:  `{"containers":["app"]}`,
+		{Qualifier: "1-", Name: OrdinalFile}: `{"containers":["worker"]}`,
+	}
+	pod := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "worker"}}}
+
+	for ordinal, want := range map[int]string{0: "app", 3: "worker"} {
+		spec, err := annotationtest.ApplyOrdinal(&OrdinalFileHandler{}, annotations, pod, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		for _, c := range spec.Containers {
+			if mounted := len(c.VolumeMounts) > 0; mounted != (c.Name == want) {
+				t.Errorf("ApplyOrdinal() ordinal %d container %s mounts = %v, want only %s mounted", ordinal, c.Name, c.VolumeMounts, want)
+			}
+		}
+	}
+}
+
+func TestOrdinalFileHandler_Mutate_MountPaths(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0-", Name: OrdinalFile}: `{"containers":["app"]}`,
+		{Qualifier: "2", Name: OrdinalFile}:  `{"containers":["app"],"mountPath":"/etc/identity"}`,
+	}
+	pod := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}
+
+	spec, err := annotationtest.ApplyOrdinal(&OrdinalFileHandler{}, annotations, pod, 2)
+	if err != nil {
+		t.Fatalf("ApplyOrdinal() error = %v", err)
+	}
+	if len(spec.Volumes) != 1 {
+		t.Errorf("ApplyOrdinal() volumes = %v, want a single ordinal file volume", spec.Volumes)
+	}
+	var paths []string
+	for _, m := range spec.Containers[0].VolumeMounts {
+		paths = append(paths, m.MountPath)
+	}
+	if want := []string{defaultOrdinalFileMountPath, "/etc/identity"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("ApplyOrdinal() mount paths = %v, want %v", paths, want)
+	}
+}
+
+func TestOrdinalFileHandler_MutateMeta(t *testing.T) {
+	h := &OrdinalFileHandler{}
+	cfg := []*ordinalFileConfig{{
+		qualifier: "1-",
+		cfg:       &ordinalFileConfigValue{Containers: []string{"app"}, Label: "example.com/ordinal"},
+	}}
+
+	meta := &metav1.ObjectMeta{}
+	if err := h.MutateMeta(meta, annotation.MutationContext{Ordinal: 0}, cfg); err != nil {
+		t.Fatalf("MutateMeta() error = %v", err)
+	}
+	if len(meta.Labels) != 0 {
+		t.Errorf("MutateMeta() set labels on an excluded pod: %v", meta.Labels)
+	}
+
+	if err := h.MutateMeta(meta, annotation.MutationContext{Ordinal: 3}, cfg); err != nil {
+		t.Fatalf("MutateMeta() error = %v", err)
+	}
+	if want := map[string]string{"example.com/ordinal": "3"}; !reflect.DeepEqual(meta.Labels, want) {
+		t.Errorf("MutateMeta() labels = %v, want %v", meta.Labels, want)
+	}
+}
+
+func Test_ordinalFileParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       ordinalFileParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    ordinalFileParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "1-", Name: OrdinalFile}: `{"containers":["app"],"mountPath":"/etc/identity"}`,
+			}},
+			want: []*ordinalFileConfig{{
+				qualifier: "1-",
+				cfg:       &ordinalFileConfigValue{Containers: []string{"app"}, MountPath: "/etc/identity"},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "no containers",
+			p:    ordinalFileParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: OrdinalFile}: `{"mountPath":"/etc/identity"}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid label",
+			p:    ordinalFileParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: OrdinalFile}: `{"containers":["app"],"label":"not a label"}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "same label under every qualifier",
+			p:    ordinalFileParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "0", Name: OrdinalFile}:  `{"containers":["app"]}`,
+				{Qualifier: "1-", Name: OrdinalFile}: `{"containers":["app"],"label":"pod-ordinal"}`,
+			}},
+			want: []*ordinalFileConfig{
+				{qualifier: "0", cfg: &ordinalFileConfigValue{Containers: []string{"app"}}},
+				{qualifier: "1-", cfg: &ordinalFileConfigValue{Containers: []string{"app"}, Label: "pod-ordinal"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "conflicting labels",
+			p:    ordinalFileParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "0", Name: OrdinalFile}:  `{"containers":["app"],"label":"example.com/ordinal"}`,
+				{Qualifier: "0-", Name: OrdinalFile}: `{"containers":["app"]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package annotation
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AppendEnvVarIfNotExists adds an environment variable, or updates the value of
+// an existing variable with the same name
+func AppendEnvVarIfNotExists(envVars []corev1.EnvVar, envVar corev1.EnvVar) []corev1.EnvVar {
+	for i, existing := range envVars {
+		if existing.Name == envVar.Name {
+			envVars[i] = envVar
+			return envVars
+		}
+	}
+	// Append if not found
+	return append(envVars, envVar)
+}
@@ -0,0 +1,143 @@
+package env
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// Env is the annotation key for env var injection configuration
+	Env = "env"
+)
+
+var log = logf.Log.WithName("env")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed env.schema.json
+var schema []byte
+
+// envConfig holds the env var injection configuration with its pod qualifier
+type envConfig struct {
+	qualifier string          // Which pods this applies to
+	cfg       *envConfigValue // The actual env configuration
+}
+
+// envConfigValue represents the JSON structure of the env configuration
+type envConfigValue struct {
+	Containers []corev1.Container `json:"containers"` // Containers with the env vars to inject
+}
+
+// Ensure EnvHandler implements Handler interface
+var _ annotation.Handler = (*EnvHandler)(nil)
+
+// EnvHandler injects env vars templated with the pod ordinal and StatefulSet
+// name into containers
+type EnvHandler struct{}
+
+// Mutate merges the configured env vars into each matched container, or every
+// container for the "*" wildcard, replacing the {{ordinal}} and {{ssName}}
+// placeholders in their values
+func (h *EnvHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*envConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*envConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply merges the env vars of a single configuration into the matched containers
+func (c *envConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	for _, containerConfig := range c.cfg.Containers {
+		for i := range spec.Containers {
+			container := &spec.Containers[i]
+			if !annotation.MatchesContainer(containerConfig.Name, container.Name) {
+				continue
+			}
+
+			containerLogger := l.WithValues("container", container.Name)
+			for _, source := range containerConfig.Env {
+				// Create a deep copy to avoid modifying the original
+				envVar := source.DeepCopy()
+				envVar.Value = annotation.SubstituteOrdinal(envVar.Value, mc.Ordinal)
+
+				if strings.Contains(envVar.Value, annotation.SSNamePlaceholder) {
+					if mc.SSName == "" {
+						containerLogger.Info("statefulset name unknown, skipping env var", "env", envVar.Name)
+						continue
+					}
+					envVar.Value = annotation.SubstituteSSName(envVar.Value, mc.SSName)
+				}
+
+				containerLogger.Info("setting env var", "env", envVar.Name, "value", envVar.Value)
+				container.Env = annotation.AppendEnvVarIfNotExists(container.Env, *envVar)
+			}
+		}
+	}
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *EnvHandler) Name() string {
+	return Env
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *EnvHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for env annotations
+func (h *EnvHandler) GetParser() annotation.Parser {
+	return envParser
+}
+
+// envParser parses every env annotation, whatever its qualifier, into an
+// envConfig, returning them in annotation key order
+var envParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*envConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != Env {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing env configuration")
+
+		var value envConfigValue
+		if err := json.Unmarshal([]byte(v), &value); err != nil {
+			logger.Error(err, "failed to parse env configuration")
+			return nil, fmt.Errorf("invalid env configuration: %w", err)
+		}
+
+		configs = append(configs, &envConfig{
+			qualifier: k.Qualifier,
+			cfg:       &value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,217 @@
+package env
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestEnvHandler_Mutate(t *testing.T) {
+	type args struct {
+		spec *corev1.PodSpec
+		mc   annotation.MutationContext
+		cfg  any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec: nil,
+				mc:   annotation.MutationContext{Ordinal: 0, SSName: "db"},
+				cfg:  nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+				mc:   annotation.MutationContext{Ordinal: 0, SSName: "db"},
+				cfg: []*envConfig{{
+					qualifier: "1-2",
+					cfg: &envConfigValue{Containers: []corev1.Container{
+						{Name: "app", Env: []corev1.EnvVar{{Name: "NODE", Value: "node-{{ordinal}}"}}},
+					}},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			wantErr: false,
+		},
+		{
+			name: "template ordinal and statefulset name",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "other"}}},
+				mc:   annotation.MutationContext{Ordinal: 4, SSName: "db"},
+				cfg: []*envConfig{{
+					cfg: &envConfigValue{Containers: []corev1.Container{
+						{Name: "app", Env: []corev1.EnvVar{
+							{Name: "NODE", Value: "node-{{ordinal}}"},
+							{Name: "PEER", Value: "{{ssName}}-0.{{ssName}}"},
+						}},
+					}},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "app", Env: []corev1.EnvVar{
+					{Name: "NODE", Value: "node-4"},
+					{Name: "PEER", Value: "db-0.db"},
+				}},
+				{Name: "other"},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "inject into every container through the wildcard",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "other"}}},
+				mc:   annotation.MutationContext{Ordinal: 2, SSName: "db"},
+				cfg: []*envConfig{{
+					cfg: &envConfigValue{Containers: []corev1.Container{
+						{Name: "*", Env: []corev1.EnvVar{{Name: "NODE", Value: "node-{{ordinal}}"}}},
+					}},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "app", Env: []corev1.EnvVar{{Name: "NODE", Value: "node-2"}}},
+				{Name: "other", Env: []corev1.EnvVar{{Name: "NODE", Value: "node-2"}}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "keep unrelated env vars and update configured ones",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "app", Env: []corev1.EnvVar{
+						{Name: "LEVEL", Value: "debug"},
+						{Name: "NODE", Value: "stale"},
+					}},
+				}},
+				mc: annotation.MutationContext{Ordinal: 4, SSName: "db"},
+				cfg: []*envConfig{{
+					cfg: &envConfigValue{Containers: []corev1.Container{
+						{Name: "app", Env: []corev1.EnvVar{{Name: "NODE", Value: "node-{{ordinal}}"}}},
+					}},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "app", Env: []corev1.EnvVar{
+					{Name: "LEVEL", Value: "debug"},
+					{Name: "NODE", Value: "node-4"},
+				}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "skip statefulset name placeholder without statefulset name",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+				mc:   annotation.MutationContext{Ordinal: 1},
+				cfg: []*envConfig{{
+					cfg: &envConfigValue{Containers: []corev1.Container{
+						{Name: "app", Env: []corev1.EnvVar{
+							{Name: "PEER", Value: "{{ssName}}-0"},
+							{Name: "NODE", Value: "node-{{ordinal}}"},
+						}},
+					}},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "app", Env: []corev1.EnvVar{{Name: "NODE", Value: "node-1"}}},
+			}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &EnvHandler{}
+			if err := h.Mutate(tt.args.spec, tt.args.mc, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: Env}:  `{"containers":[{"name":"app","env":[{"name":"ROLE","value":"seed"}]}]}`,
+		{Qualifier: "1-", Name: Env}: `{"containers":[{"name":"app","env":[{"name":"ROLE","value":"replica-{{ordinal}}"}]}]}`,
+	}
+	pod := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}
+
+	for ordinal, want := range map[int]string{0: "seed", 3: "replica-3"} {
+		spec, err := annotationtest.ApplyOrdinal(&EnvHandler{}, annotations, pod, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		if got := spec.Containers[0].Env; !reflect.DeepEqual(got, []corev1.EnvVar{{Name: "ROLE", Value: want}}) {
+			t.Errorf("ApplyOrdinal() ordinal %d env = %v, want ROLE=%s", ordinal, got, want)
+		}
+	}
+}
+
+func Test_envParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       envParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    envParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "2-", Name: Env}: `{"containers":[{"name":"app","env":[{"name":"NODE","value":"node-{{ordinal}}"}]}]}`,
+			}},
+			want: []*envConfig{{
+				qualifier: "2-",
+				cfg: &envConfigValue{Containers: []corev1.Container{
+					{Name: "app", Env: []corev1.EnvVar{{Name: "NODE", Value: "node-{{ordinal}}"}}},
+				}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "invalid json",
+			p:    envParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Env}: `{"containers":`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package envremove
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// EnvRemove is the annotation key for env var removal configuration
+	EnvRemove = "env-remove"
+)
+
+var log = logf.Log.WithName("env_remove")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed envremove.schema.json
+var schema []byte
+
+// envRemoveConfig holds the env var removal configuration with its pod qualifier
+type envRemoveConfig struct {
+	qualifier string                // Which pods this applies to
+	cfg       *envRemoveConfigValue // The actual env var removal configuration
+}
+
+// envRemoveConfigValue represents the JSON structure of the env var removal
+// configuration
+type envRemoveConfigValue struct {
+	Containers []containerEnvRemoval `json:"containers"`
+}
+
+// containerEnvRemoval names the env vars to remove from a container, or every
+// container for the "*" wildcard
+type containerEnvRemoval struct {
+	Name string   `json:"name"`
+	Env  []string `json:"env"`
+}
+
+// Ensure EnvRemoveHandler implements Handler interface
+var _ annotation.Handler = (*EnvRemoveHandler)(nil)
+
+// EnvRemoveHandler removes env vars by name from containers, e.g. a TERM the
+// pod template inherits that some ordinals must not see
+type EnvRemoveHandler struct{}
+
+// Mutate removes the configured env vars from the matched containers and init
+// containers for every configuration whose qualifier matches the pod ordinal
+func (h *EnvRemoveHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*envRemoveConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*envRemoveConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, l)
+	}
+
+	return nil
+}
+
+// apply removes the env vars of a single configuration
+func (c *envRemoveConfig) apply(spec *corev1.PodSpec, l logr.Logger) {
+	for _, removal := range c.cfg.Containers {
+		for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+			for i := range containers {
+				container := &containers[i]
+				if !annotation.MatchesContainer(removal.Name, container.Name) {
+					continue
+				}
+				container.Env = slices.DeleteFunc(container.Env, func(e corev1.EnvVar) bool {
+					if !slices.Contains(removal.Env, e.Name) {
+						return false
+					}
+					l.Info("removing env var", "container", container.Name, "env", e.Name)
+					return true
+				})
+			}
+		}
+	}
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *EnvRemoveHandler) Name() string {
+	return EnvRemove
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *EnvRemoveHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for env var removal annotations
+func (h *EnvRemoveHandler) GetParser() annotation.Parser {
+	return envRemoveParser
+}
+
+// envRemoveParser parses every env var removal annotation, whatever its
+// qualifier, into an envRemoveConfig, returning them in annotation key order
+var envRemoveParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*envRemoveConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != EnvRemove {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing env var removal configuration")
+
+		value := &envRemoveConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse env var removal configuration")
+			return nil, fmt.Errorf("invalid env var removal configuration: %w", err)
+		}
+
+		for _, c := range value.Containers {
+			if c.Name == "" {
+				return nil, fmt.Errorf("invalid env var removal configuration: container without a name")
+			}
+			if len(c.Env) == 0 {
+				return nil, fmt.Errorf("invalid env var removal configuration: container %q names no env vars", c.Name)
+			}
+		}
+
+		configs = append(configs, &envRemoveConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
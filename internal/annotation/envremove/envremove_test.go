@@ -0,0 +1,187 @@
+package envremove
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestEnvRemoveHandler_Mutate(t *testing.T) {
+	env := func(names ...string) []corev1.EnvVar {
+		vars := make([]corev1.EnvVar, len(names))
+		for i, name := range names {
+			vars[i] = corev1.EnvVar{Name: name, Value: "v"}
+		}
+		return vars
+	}
+
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Env: env("TERM", "LANG")}}},
+				ordinal: 0,
+				cfg: []*envRemoveConfig{{
+					qualifier: "1-",
+					cfg:       &envRemoveConfigValue{Containers: []containerEnvRemoval{{Name: "app", Env: []string{"TERM"}}}},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Env: env("TERM", "LANG")}}},
+			wantErr: false,
+		},
+		{
+			name: "remove named vars keeping the others",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "app", Env: env("TERM", "LANG", "COLORTERM", "HOME")},
+					{Name: "proxy", Env: env("TERM")},
+				}},
+				ordinal: 2,
+				cfg: []*envRemoveConfig{{
+					cfg: &envRemoveConfigValue{Containers: []containerEnvRemoval{{Name: "app", Env: []string{"TERM", "COLORTERM", "MISSING"}}}},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "app", Env: env("LANG", "HOME")},
+				{Name: "proxy", Env: env("TERM")},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "wildcard removes from init containers and containers",
+			args: args{
+				spec: &corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "init", Env: env("TERM", "LANG")}},
+					Containers:     []corev1.Container{{Name: "app", Env: env("TERM")}},
+				},
+				ordinal: 1,
+				cfg: []*envRemoveConfig{{
+					qualifier: "1",
+					cfg:       &envRemoveConfigValue{Containers: []containerEnvRemoval{{Name: annotation.WildcardContainer, Env: []string{"TERM"}}}},
+				}},
+			},
+			want: &corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "init", Env: env("LANG")}},
+				Containers:     []corev1.Container{{Name: "app", Env: []corev1.EnvVar{}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "removals of several qualifiers add up",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Env: env("TERM", "LANG", "HOME")}}},
+				ordinal: 3,
+				cfg: []*envRemoveConfig{
+					{qualifier: "0-3", cfg: &envRemoveConfigValue{Containers: []containerEnvRemoval{{Name: "app", Env: []string{"TERM"}}}}},
+					{qualifier: "3-", cfg: &envRemoveConfigValue{Containers: []containerEnvRemoval{{Name: "app", Env: []string{"HOME"}}}}},
+				},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Env: env("LANG")}}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &EnvRemoveHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func Test_envRemoveParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       envRemoveParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    envRemoveParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "2", Name: EnvRemove}: `{"containers":[{"name":"app","env":["TERM"]}]}`,
+			}},
+			want: []*envRemoveConfig{{
+				qualifier: "2",
+				cfg:       &envRemoveConfigValue{Containers: []containerEnvRemoval{{Name: "app", Env: []string{"TERM"}}}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "container without a name",
+			p:    envRemoveParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: EnvRemove}: `{"containers":[{"env":["TERM"]}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "container without env vars",
+			p:    envRemoveParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: EnvRemove}: `{"containers":[{"name":"app"}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid json",
+			p:    envRemoveParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: EnvRemove}: `{"containers":`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package goruntime
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// GoRuntime is the annotation key for Go runtime configuration
+	GoRuntime = "go-runtime"
+	// GoMaxProcs is the env var set from the CPU limit
+	GoMaxProcs = "GOMAXPROCS"
+	// GoMemLimit is the env var set from the memory limit
+	GoMemLimit = "GOMEMLIMIT"
+	// defaultMemoryLimitPercent leaves headroom for memory the Go runtime
+	// doesn't manage
+	defaultMemoryLimitPercent = 90
+	// priority orders the handler after the resources handler, whose limits
+	// it reads
+	priority = 10
+)
+
+var log = logf.Log.WithName("go_runtime")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed goruntime.schema.json
+var schema []byte
+
+// goRuntimeConfig holds the Go runtime configuration with its pod qualifier
+type goRuntimeConfig struct {
+	qualifier string                // Which pods this applies to
+	cfg       *goRuntimeConfigValue // The actual Go runtime configuration
+}
+
+// goRuntimeConfigValue represents the JSON structure of the Go runtime
+// configuration
+type goRuntimeConfigValue struct {
+	// Containers names the containers running Go programs
+	Containers []string `json:"containers"`
+	// MemoryLimitPercent is the share of the memory limit GOMEMLIMIT is set
+	// to, defaultMemoryLimitPercent when zero
+	MemoryLimitPercent int64 `json:"memoryLimitPercent,omitempty"`
+}
+
+// memoryLimitPercent returns the configured share of the memory limit or the
+// default one
+func (c *goRuntimeConfigValue) memoryLimitPercent() int64 {
+	if c.MemoryLimitPercent == 0 {
+		return defaultMemoryLimitPercent
+	}
+	return c.MemoryLimitPercent
+}
+
+// Ensure GoRuntimeHandler implements PrioritizedHandler interface
+var _ annotation.PrioritizedHandler = (*GoRuntimeHandler)(nil)
+
+// GoRuntimeHandler sets GOMAXPROCS and GOMEMLIMIT on Go containers from their
+// CPU and memory limits, after the resources handler applied them
+type GoRuntimeHandler struct{}
+
+// Mutate sets GOMAXPROCS to the CPU limit rounded up to whole CPUs and
+// GOMEMLIMIT to a share of the memory limit in bytes. Either is left unset
+// for containers without the corresponding limit.
+func (h *GoRuntimeHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*goRuntimeConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*goRuntimeConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply sets the Go runtime env vars of a single configuration
+func (c *goRuntimeConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	for _, name := range c.cfg.Containers {
+		for i := range spec.Containers {
+			container := &spec.Containers[i]
+			if container.Name != name {
+				continue
+			}
+
+			containerLogger := l.WithValues("container", name)
+			if cpu, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
+				procs := max((cpu.MilliValue()+999)/1000, 1)
+				containerLogger.Info("setting GOMAXPROCS", "cpu", cpu.String(), "value", procs)
+				container.Env = annotation.AppendEnvVarIfNotExists(container.Env, corev1.EnvVar{
+					Name:  GoMaxProcs,
+					Value: strconv.FormatInt(procs, 10),
+				})
+			} else {
+				containerLogger.Info("container has no CPU limit, not setting GOMAXPROCS")
+			}
+
+			if memory, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+				limit := memory.Value() * c.cfg.memoryLimitPercent() / 100
+				containerLogger.Info("setting GOMEMLIMIT", "memory", memory.String(), "value", limit)
+				container.Env = annotation.AppendEnvVarIfNotExists(container.Env, corev1.EnvVar{
+					Name:  GoMemLimit,
+					Value: strconv.FormatInt(limit, 10),
+				})
+			} else {
+				containerLogger.Info("container has no memory limit, not setting GOMEMLIMIT")
+			}
+		}
+	}
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *GoRuntimeHandler) Name() string {
+	return GoRuntime
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *GoRuntimeHandler) Schema() []byte {
+	return schema
+}
+
+// Priority orders the handler after the resources handler
+func (h *GoRuntimeHandler) Priority() int {
+	return priority
+}
+
+// GetParser returns the parser for Go runtime annotations
+func (h *GoRuntimeHandler) GetParser() annotation.Parser {
+	return goRuntimeParser
+}
+
+// goRuntimeParser parses every Go runtime annotation, whatever its qualifier, into
+// a goRuntimeConfig, returning them in annotation key order
+var goRuntimeParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*goRuntimeConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != GoRuntime {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing Go runtime configuration")
+
+		value := &goRuntimeConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse Go runtime configuration")
+			return nil, fmt.Errorf("invalid Go runtime configuration: %w", err)
+		}
+		if len(value.Containers) == 0 {
+			return nil, fmt.Errorf("invalid Go runtime configuration: no containers")
+		}
+		if value.MemoryLimitPercent < 0 || value.MemoryLimitPercent > 100 {
+			return nil, fmt.Errorf("invalid Go runtime configuration: memoryLimitPercent %d must be between 1 and 100", value.MemoryLimitPercent)
+		}
+
+		configs = append(configs, &goRuntimeConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,247 @@
+package goruntime
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	"github.com/golem-base/spoditor/internal/annotation/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGoRuntimeHandler_Mutate(t *testing.T) {
+	limited := func(cpu, memory string) corev1.ResourceRequirements {
+		limits := corev1.ResourceList{}
+		if cpu != "" {
+			limits[corev1.ResourceCPU] = resource.MustParse(cpu)
+		}
+		if memory != "" {
+			limits[corev1.ResourceMemory] = resource.MustParse(memory)
+		}
+		return corev1.ResourceRequirements{Limits: limits}
+	}
+
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: limited("2", "1Gi")}}},
+				ordinal: 0,
+				cfg: []*goRuntimeConfig{{
+					qualifier: "1-",
+					cfg:       &goRuntimeConfigValue{Containers: []string{"app"}},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: limited("2", "1Gi")}}},
+			wantErr: false,
+		},
+		{
+			name: "round the CPU limit up and keep headroom below the memory limit",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "app", Resources: limited("1500m", "1Gi")},
+					{Name: "proxy", Resources: limited("1", "1Gi")},
+				}},
+				ordinal: 1,
+				cfg: []*goRuntimeConfig{{
+					cfg: &goRuntimeConfigValue{Containers: []string{"app"}},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{
+					Name:      "app",
+					Resources: limited("1500m", "1Gi"),
+					Env: []corev1.EnvVar{
+						{Name: GoMaxProcs, Value: "2"},
+						{Name: GoMemLimit, Value: "966367641"},
+					},
+				},
+				{Name: "proxy", Resources: limited("1", "1Gi")},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "at least one proc and a custom memory share",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: limited("250m", "100Mi")}}},
+				ordinal: 1,
+				cfg: []*goRuntimeConfig{{
+					cfg: &goRuntimeConfigValue{Containers: []string{"app"}, MemoryLimitPercent: 50},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{{
+				Name:      "app",
+				Resources: limited("250m", "100Mi"),
+				Env: []corev1.EnvVar{
+					{Name: GoMaxProcs, Value: "1"},
+					{Name: GoMemLimit, Value: "52428800"},
+				},
+			}}},
+			wantErr: false,
+		},
+		{
+			name: "skip missing limits",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: limited("", "1Gi")}}},
+				ordinal: 1,
+				cfg: []*goRuntimeConfig{{
+					cfg: &goRuntimeConfigValue{Containers: []string{"app"}, MemoryLimitPercent: 100},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{{
+				Name:      "app",
+				Resources: limited("", "1Gi"),
+				Env:       []corev1.EnvVar{{Name: GoMemLimit, Value: "1073741824"}},
+			}}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &GoRuntimeHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoRuntimeHandler_AfterResources(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: resources.Resources}: `{"limits":{"cpu":"1 + {{ordinal}}","memory":"512Mi + {{ordinal}} * 512Mi"}}`,
+		{Name: GoRuntime}:           `{"containers":["app"],"memoryLimitPercent":100}`,
+	}
+
+	rh, gh := &resources.ResourcesHandler{}, &GoRuntimeHandler{}
+	if annotation.HandlerPriority(gh) <= annotation.HandlerPriority(rh) {
+		t.Fatalf("go-runtime priority %d must order it after resources", annotation.HandlerPriority(gh))
+	}
+
+	spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}
+	spec, err := annotationtest.ApplyOrdinal(rh, annotations, spec, 2)
+	if err != nil {
+		t.Fatalf("resources: %v", err)
+	}
+	spec, err = annotationtest.ApplyOrdinal(gh, annotations, spec, 2)
+	if err != nil {
+		t.Fatalf("go-runtime: %v", err)
+	}
+
+	want := []corev1.EnvVar{
+		{Name: GoMaxProcs, Value: "3"},
+		{Name: GoMemLimit, Value: "1610612736"},
+	}
+	if got := spec.Containers[0].Env; !reflect.DeepEqual(got, want) {
+		t.Errorf("Env = %v, want %v", got, want)
+	}
+}
+
+func TestGoRuntimeHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: GoRuntime}:  `{"containers":["app"],"memoryLimitPercent":50}`,
+		{Qualifier: "1-", Name: GoRuntime}: `{"containers":["app"]}`,
+	}
+	pod := &corev1.PodSpec{Containers: []corev1.Container{{
+		Name: "app",
+		Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("1000"),
+		}},
+	}}}
+
+	for ordinal, want := range map[int]string{0: "500", 3: "900"} {
+		spec, err := annotationtest.ApplyOrdinal(&GoRuntimeHandler{}, annotations, pod, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		if got := spec.Containers[0].Env; !reflect.DeepEqual(got, []corev1.EnvVar{{Name: GoMemLimit, Value: want}}) {
+			t.Errorf("ApplyOrdinal() ordinal %d env = %v, want GOMEMLIMIT=%s", ordinal, got, want)
+		}
+	}
+}
+
+func Test_goRuntimeParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       goRuntimeParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    goRuntimeParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "1-", Name: GoRuntime}: `{"containers":["app"],"memoryLimitPercent":80}`,
+			}},
+			want: []*goRuntimeConfig{{
+				qualifier: "1-",
+				cfg:       &goRuntimeConfigValue{Containers: []string{"app"}, MemoryLimitPercent: 80},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "no containers",
+			p:    goRuntimeParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: GoRuntime}: `{"memoryLimitPercent":80}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "memory share out of range",
+			p:    goRuntimeParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: GoRuntime}: `{"containers":["app"],"memoryLimitPercent":120}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package hostaliases
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// HostAliases is the annotation key for /etc/hosts entries configuration
+	HostAliases = "host-aliases"
+)
+
+var log = logf.Log.WithName("host_aliases")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed hostaliases.schema.json
+var schema []byte
+
+// hostAliasesConfig holds the host aliases with their pod qualifier
+type hostAliasesConfig struct {
+	qualifier string             // Which pods this applies to
+	aliases   []corev1.HostAlias // Host aliases to be added to the pod
+}
+
+// Ensure HostAliasesHandler implements Handler interface
+var _ annotation.Handler = (*HostAliasesHandler)(nil)
+
+// HostAliasesHandler adds /etc/hosts entries to the pod spec based on annotations
+type HostAliasesHandler struct{}
+
+// Mutate adds the configured host aliases, templating the ordinal into hostnames
+// and merging hostnames into any existing entry for the same IP
+func (h *HostAliasesHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*hostAliasesConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*hostAliasesConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply adds the host aliases of a single configuration
+func (c *hostAliasesConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	for _, alias := range c.aliases {
+		// Create a deep copy to avoid modifying the original
+		templated := *alias.DeepCopy()
+		for i, hostname := range templated.Hostnames {
+			templated.Hostnames[i] = annotation.SubstituteOrdinal(hostname, mc.Ordinal)
+		}
+
+		// Merge into an existing entry for the same IP rather than adding another one
+		i := indexOfIP(spec.HostAliases, templated.IP)
+		if i == -1 {
+			l.Info("adding host alias", "ip", templated.IP, "hostnames", templated.Hostnames)
+			spec.HostAliases = append(spec.HostAliases, templated)
+			continue
+		}
+
+		existing := &spec.HostAliases[i]
+		for _, hostname := range templated.Hostnames {
+			if slices.Contains(existing.Hostnames, hostname) {
+				continue
+			}
+			l.Info("merging hostname into existing host alias", "ip", existing.IP, "hostname", hostname)
+			existing.Hostnames = append(existing.Hostnames, hostname)
+		}
+	}
+}
+
+// indexOfIP returns the index of the host alias for the given IP, or -1
+func indexOfIP(aliases []corev1.HostAlias, ip string) int {
+	for i, existing := range aliases {
+		if existing.IP == ip {
+			return i
+		}
+	}
+	return -1
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *HostAliasesHandler) Name() string {
+	return HostAliases
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *HostAliasesHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for host aliases annotations
+func (h *HostAliasesHandler) GetParser() annotation.Parser {
+	return hostAliasesParser
+}
+
+// hostAliasesParser parses every host aliases annotation, whatever its qualifier,
+// into a hostAliasesConfig, returning them in annotation key order
+var hostAliasesParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*hostAliasesConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != HostAliases {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing host aliases configuration")
+
+		var aliases []corev1.HostAlias
+		if err := json.Unmarshal([]byte(v), &aliases); err != nil {
+			logger.Error(err, "failed to parse host aliases configuration")
+			return nil, fmt.Errorf("invalid host aliases configuration: %w", err)
+		}
+
+		if len(aliases) == 0 {
+			logger.Info("configuration has no host aliases, skipping")
+			continue
+		}
+
+		configs = append(configs, &hostAliasesConfig{
+			qualifier: k.Qualifier,
+			aliases:   aliases,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
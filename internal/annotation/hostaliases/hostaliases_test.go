@@ -0,0 +1,236 @@
+package hostaliases
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestHostAliasesHandler_Mutate(t *testing.T) {
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 0,
+				cfg: []*hostAliasesConfig{{
+					qualifier: "1-2",
+					aliases:   []corev1.HostAlias{{IP: "10.0.0.1", Hostnames: []string{"peer"}}},
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "template ordinal into hostnames",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 2,
+				cfg: []*hostAliasesConfig{{
+					aliases: []corev1.HostAlias{
+						{IP: "127.0.0.1", Hostnames: []string{"self-{{ordinal}}", "self-{{ordinal}}.local"}},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{
+				HostAliases: []corev1.HostAlias{
+					{IP: "127.0.0.1", Hostnames: []string{"self-2", "self-2.local"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "merge with existing host aliases",
+			args: args{
+				spec: &corev1.PodSpec{
+					HostAliases: []corev1.HostAlias{
+						{IP: "10.0.0.1", Hostnames: []string{"registry"}},
+						{IP: "127.0.0.1", Hostnames: []string{"self-1"}},
+					},
+				},
+				ordinal: 1,
+				cfg: []*hostAliasesConfig{{
+					aliases: []corev1.HostAlias{
+						{IP: "127.0.0.1", Hostnames: []string{"self-{{ordinal}}"}},
+						{IP: "10.0.0.2", Hostnames: []string{"peer-0"}},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{
+				HostAliases: []corev1.HostAlias{
+					{IP: "10.0.0.1", Hostnames: []string{"registry"}},
+					{IP: "127.0.0.1", Hostnames: []string{"self-1"}},
+					{IP: "10.0.0.2", Hostnames: []string{"peer-0"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "merge hostnames into existing entry with the same ip",
+			args: args{
+				spec: &corev1.PodSpec{
+					HostAliases: []corev1.HostAlias{
+						{IP: "127.0.0.1", Hostnames: []string{"localhost", "self-3"}},
+					},
+				},
+				ordinal: 3,
+				cfg: []*hostAliasesConfig{{
+					aliases: []corev1.HostAlias{
+						{IP: "127.0.0.1", Hostnames: []string{"self-{{ordinal}}", "self-{{ordinal}}.local"}},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{
+				HostAliases: []corev1.HostAlias{
+					{IP: "127.0.0.1", Hostnames: []string{"localhost", "self-3", "self-3.local"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "merge configured entries sharing an ip",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 0,
+				cfg: []*hostAliasesConfig{{
+					aliases: []corev1.HostAlias{
+						{IP: "10.0.0.1", Hostnames: []string{"a"}},
+						{IP: "10.0.0.1", Hostnames: []string{"b", "a"}},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{
+				HostAliases: []corev1.HostAlias{
+					{IP: "10.0.0.1", Hostnames: []string{"a", "b"}},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &HostAliasesHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostAliasesHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: HostAliases}:  `[{"ip":"10.0.0.1","hostnames":["seed"]}]`,
+		{Qualifier: "1-", Name: HostAliases}: `[{"ip":"10.0.0.2","hostnames":["replica-{{ordinal}}"]}]`,
+	}
+
+	for ordinal, want := range map[int]corev1.HostAlias{
+		0: {IP: "10.0.0.1", Hostnames: []string{"seed"}},
+		3: {IP: "10.0.0.2", Hostnames: []string{"replica-3"}},
+	} {
+		spec, err := annotationtest.ApplyOrdinal(&HostAliasesHandler{}, annotations, &corev1.PodSpec{}, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		if !reflect.DeepEqual(spec.HostAliases, []corev1.HostAlias{want}) {
+			t.Errorf("ApplyOrdinal() ordinal %d host aliases = %v, want %v", ordinal, spec.HostAliases, want)
+		}
+	}
+}
+
+func Test_hostAliasesParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       hostAliasesParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    hostAliasesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Qualifier: "0",
+					Name:      HostAliases,
+				}: `[{"ip":"127.0.0.1","hostnames":["self-{{ordinal}}"]}]`,
+			}},
+			want: []*hostAliasesConfig{{
+				qualifier: "0",
+				aliases:   []corev1.HostAlias{{IP: "127.0.0.1", Hostnames: []string{"self-{{ordinal}}"}}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "every annotation in key order",
+			p:    hostAliasesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "3-5", Name: HostAliases}: `[{"ip":"10.0.0.2","hostnames":["high"]}]`,
+				{Qualifier: "0-2", Name: HostAliases}: `[{"ip":"10.0.0.1","hostnames":["low"]}]`,
+			}},
+			want: []*hostAliasesConfig{{
+				qualifier: "0-2",
+				aliases:   []corev1.HostAlias{{IP: "10.0.0.1", Hostnames: []string{"low"}}},
+			}, {
+				qualifier: "3-5",
+				aliases:   []corev1.HostAlias{{IP: "10.0.0.2", Hostnames: []string{"high"}}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "invalid json",
+			p:    hostAliasesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: HostAliases}: `[{"ip":`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package imagepullsecrets
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// ImagePullSecrets is the annotation key for image pull secrets configuration
+	ImagePullSecrets = "image-pull-secrets"
+)
+
+var log = logf.Log.WithName("image_pull_secrets")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed imagepullsecrets.schema.json
+var schema []byte
+
+// imagePullSecretsConfig holds the image pull secrets configuration with its pod qualifier
+type imagePullSecretsConfig struct {
+	qualifier string                       // Which pods this applies to
+	cfg       *imagePullSecretsConfigValue // The actual image pull secrets configuration
+}
+
+// imagePullSecretsConfigValue represents the JSON structure of the image pull
+// secrets configuration
+type imagePullSecretsConfigValue struct {
+	// Secrets names the secrets to add to the pod's image pull secrets
+	Secrets []string `json:"secrets"`
+	// OrdinalSuffix appends the pod ordinal to each secret name, e.g. regcred-2,
+	// like the mount-volume handler does for secret volumes
+	OrdinalSuffix bool `json:"ordinalSuffix,omitempty"`
+}
+
+// Ensure ImagePullSecretsHandler implements Handler interface
+var _ annotation.Handler = (*ImagePullSecretsHandler)(nil)
+
+// ImagePullSecretsHandler adds image pull secrets to the pod spec based on
+// annotations, e.g. for ordinals pulling from a private registry
+type ImagePullSecretsHandler struct{}
+
+// Mutate appends the configured secrets the pod doesn't reference yet
+func (h *ImagePullSecretsHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*imagePullSecretsConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*imagePullSecretsConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		if err := c.apply(spec, mc, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply adds the secrets of a single configuration
+func (c *imagePullSecretsConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) error {
+	for _, name := range c.cfg.Secrets {
+		if c.cfg.OrdinalSuffix {
+			name += "-" + strconv.Itoa(mc.Ordinal)
+		}
+
+		if slices.ContainsFunc(spec.ImagePullSecrets, func(r corev1.LocalObjectReference) bool { return r.Name == name }) {
+			l.Info("image pull secret already present, skipping", "secret", name)
+			continue
+		}
+
+		l.Info("adding image pull secret", "secret", name)
+		spec.ImagePullSecrets = append(spec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+	}
+
+	return nil
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *ImagePullSecretsHandler) Name() string {
+	return ImagePullSecrets
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *ImagePullSecretsHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for image pull secrets annotations
+func (h *ImagePullSecretsHandler) GetParser() annotation.Parser {
+	return imagePullSecretsParser
+}
+
+// imagePullSecretsParser parses every image pull secrets annotation, whatever its
+// qualifier, into an imagePullSecretsConfig, returning them in annotation key
+// order
+var imagePullSecretsParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*imagePullSecretsConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != ImagePullSecrets {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing image pull secrets configuration")
+
+		value := &imagePullSecretsConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse image pull secrets configuration")
+			return nil, fmt.Errorf("invalid image pull secrets configuration: %w", err)
+		}
+
+		if slices.Contains(value.Secrets, "") {
+			return nil, fmt.Errorf("invalid image pull secrets configuration: empty secret name")
+		}
+
+		if len(value.Secrets) == 0 {
+			logger.Info("configuration has no secrets, skipping")
+			continue
+		}
+
+		configs = append(configs, &imagePullSecretsConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
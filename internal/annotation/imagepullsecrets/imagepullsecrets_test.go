@@ -0,0 +1,187 @@
+package imagepullsecrets
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestImagePullSecretsHandler_Mutate(t *testing.T) {
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 1,
+				cfg: []*imagePullSecretsConfig{{
+					qualifier: "3-",
+					cfg:       &imagePullSecretsConfigValue{Secrets: []string{"regcred"}},
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "append secret names as given",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 3,
+				cfg: []*imagePullSecretsConfig{{
+					qualifier: "3-",
+					cfg:       &imagePullSecretsConfigValue{Secrets: []string{"regcred", "mirror"}},
+				}},
+			},
+			want: &corev1.PodSpec{ImagePullSecrets: []corev1.LocalObjectReference{
+				{Name: "regcred"},
+				{Name: "mirror"},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "append secret names suffixed with the ordinal",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 4,
+				cfg: []*imagePullSecretsConfig{{
+					cfg: &imagePullSecretsConfigValue{Secrets: []string{"regcred"}, OrdinalSuffix: true},
+				}},
+			},
+			want: &corev1.PodSpec{ImagePullSecrets: []corev1.LocalObjectReference{
+				{Name: "regcred-4"},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "skip secrets the pod already references",
+			args: args{
+				spec: &corev1.PodSpec{ImagePullSecrets: []corev1.LocalObjectReference{
+					{Name: "public"},
+					{Name: "regcred"},
+				}},
+				ordinal: 0,
+				cfg: []*imagePullSecretsConfig{{
+					cfg: &imagePullSecretsConfigValue{Secrets: []string{"regcred", "mirror", "mirror"}},
+				}},
+			},
+			want: &corev1.PodSpec{ImagePullSecrets: []corev1.LocalObjectReference{
+				{Name: "public"},
+				{Name: "regcred"},
+				{Name: "mirror"},
+			}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ImagePullSecretsHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestImagePullSecretsHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: ImagePullSecrets}:  `{"secrets":["canary-registry"]}`,
+		{Qualifier: "1-", Name: ImagePullSecrets}: `{"secrets":["registry"]}`,
+	}
+
+	for ordinal, want := range map[int]string{0: "canary-registry", 3: "registry"} {
+		spec, err := annotationtest.ApplyOrdinal(&ImagePullSecretsHandler{}, annotations, &corev1.PodSpec{}, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		if !reflect.DeepEqual(spec.ImagePullSecrets, []corev1.LocalObjectReference{{Name: want}}) {
+			t.Errorf("ApplyOrdinal() ordinal %d image pull secrets = %v, want %s", ordinal, spec.ImagePullSecrets, want)
+		}
+	}
+}
+
+func Test_imagePullSecretsParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       imagePullSecretsParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    imagePullSecretsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "3-", Name: ImagePullSecrets}: `{"secrets":["regcred"],"ordinalSuffix":true}`,
+			}},
+			want: []*imagePullSecretsConfig{{
+				qualifier: "3-",
+				cfg:       &imagePullSecretsConfigValue{Secrets: []string{"regcred"}, OrdinalSuffix: true},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "empty secret name",
+			p:    imagePullSecretsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: ImagePullSecrets}: `{"secrets":[""]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid json",
+			p:    imagePullSecretsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: ImagePullSecrets}: `{"secrets":`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package labels
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// InjectOrdinalLabel is the annotation key naming the label set to the pod ordinal
+	InjectOrdinalLabel = "inject-ordinal-label"
+	// DefaultOrdinalLabel is the label key used when the annotation value is empty
+	DefaultOrdinalLabel = "pod-ordinal"
+)
+
+var log = logf.Log.WithName("ordinal_label")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed ordinal.schema.json
+var schema []byte
+
+// ordinalLabelConfig holds the label key with its pod qualifier
+type ordinalLabelConfig struct {
+	qualifier string // Which pods this applies to
+	key       string // Label key to set to the ordinal
+}
+
+// Ensure OrdinalLabelHandler implements MetadataHandler interface
+var _ annotation.MetadataHandler = (*OrdinalLabelHandler)(nil)
+
+// OrdinalLabelHandler stamps the pod ordinal as a pod label
+type OrdinalLabelHandler struct{}
+
+// Mutate leaves the pod spec untouched, the label is set by MutateMeta
+func (h *OrdinalLabelHandler) Mutate(_ *corev1.PodSpec, _ annotation.MutationContext, cfg any) error {
+	if _, ok := cfg.([]*ordinalLabelConfig); !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*ordinalLabelConfig", cfg)
+	}
+	return nil
+}
+
+// MutateMeta sets the configured label to the pod ordinal
+func (h *OrdinalLabelHandler) MutateMeta(meta *metav1.ObjectMeta, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*ordinalLabelConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*ordinalLabelConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.applyMeta(meta, mc, l)
+	}
+
+	return nil
+}
+
+// applyMeta sets the label of a single configuration to the pod ordinal
+func (c *ordinalLabelConfig) applyMeta(meta *metav1.ObjectMeta, mc annotation.MutationContext, l logr.Logger) {
+	if meta.Labels == nil {
+		meta.Labels = make(map[string]string)
+	}
+
+	value := strconv.Itoa(mc.Ordinal)
+	l.Info("setting ordinal label", "key", c.key, "value", value)
+	meta.Labels[c.key] = value
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *OrdinalLabelHandler) Name() string {
+	return InjectOrdinalLabel
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *OrdinalLabelHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for ordinal label annotations
+func (h *OrdinalLabelHandler) GetParser() annotation.Parser {
+	return ordinalLabelParser
+}
+
+// ordinalLabelParser parses every ordinal label annotation, whatever its
+// qualifier, into an ordinalLabelConfig, returning them in annotation key order
+var ordinalLabelParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*ordinalLabelConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != InjectOrdinalLabel {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing ordinal label configuration")
+
+		key := strings.TrimSpace(v)
+		if key == "" {
+			key = DefaultOrdinalLabel
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid ordinal label key %q: %s", key, strings.Join(errs, "; "))
+		}
+
+		configs = append(configs, &ordinalLabelConfig{
+			qualifier: k.Qualifier,
+			key:       key,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,176 @@
+package labels
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOrdinalLabelHandler_MutateMeta(t *testing.T) {
+	type args struct {
+		meta    *metav1.ObjectMeta
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *metav1.ObjectMeta
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				meta:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				meta:    &metav1.ObjectMeta{},
+				ordinal: 0,
+				cfg: []*ordinalLabelConfig{{
+					qualifier: "1-2",
+					key:       DefaultOrdinalLabel,
+				}},
+			},
+			want:    &metav1.ObjectMeta{},
+			wantErr: false,
+		},
+		{
+			name: "set ordinal label",
+			args: args{
+				meta: &metav1.ObjectMeta{
+					Labels: map[string]string{"app": "web"},
+				},
+				ordinal: 4,
+				cfg: []*ordinalLabelConfig{{
+					key: "example.com/ordinal",
+				}},
+			},
+			want: &metav1.ObjectMeta{
+				Labels: map[string]string{
+					"app":                 "web",
+					"example.com/ordinal": "4",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "set ordinal label without existing labels",
+			args: args{
+				meta:    &metav1.ObjectMeta{},
+				ordinal: 0,
+				cfg: []*ordinalLabelConfig{{
+					key: DefaultOrdinalLabel,
+				}},
+			},
+			want: &metav1.ObjectMeta{
+				Labels: map[string]string{DefaultOrdinalLabel: "0"},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &OrdinalLabelHandler{}
+			if err := h.MutateMeta(tt.args.meta, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("MutateMeta() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.meta, tt.want) {
+				t.Errorf("MutateMeta() = %v, want %v", tt.args.meta, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrdinalLabelHandler_MutateMeta_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: InjectOrdinalLabel}:  "seed-ordinal",
+		{Qualifier: "1-", Name: InjectOrdinalLabel}: "replica-ordinal",
+	}
+
+	h := &OrdinalLabelHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for ordinal, want := range map[int]map[string]string{
+		0: {"seed-ordinal": "0"},
+		3: {"replica-ordinal": "3"},
+	} {
+		meta := &metav1.ObjectMeta{}
+		if err := h.MutateMeta(meta, annotation.MutationContext{Ordinal: ordinal}, cfg); err != nil {
+			t.Fatalf("MutateMeta() ordinal %d error = %v", ordinal, err)
+		}
+		if !reflect.DeepEqual(meta.Labels, want) {
+			t.Errorf("MutateMeta() ordinal %d labels = %v, want %v", ordinal, meta.Labels, want)
+		}
+	}
+}
+
+func Test_ordinalLabelParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       ordinalLabelParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "default label key",
+			p:    ordinalLabelParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: InjectOrdinalLabel}: "",
+			}},
+			want:    []*ordinalLabelConfig{{key: DefaultOrdinalLabel}},
+			wantErr: false,
+		},
+		{
+			name: "custom label key",
+			p:    ordinalLabelParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "0-2", Name: InjectOrdinalLabel}: "example.com/ordinal",
+			}},
+			want:    []*ordinalLabelConfig{{qualifier: "0-2", key: "example.com/ordinal"}},
+			wantErr: false,
+		},
+		{
+			name: "invalid label key",
+			p:    ordinalLabelParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: InjectOrdinalLabel}: "not a label",
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package labels
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// StatefulSetLabels is the annotation key listing the StatefulSet labels
+	// to propagate onto the pod
+	StatefulSetLabels = "statefulset-labels"
+)
+
+// statefulSetSchema is the JSON Schema of the annotation value
+//
+//go:embed statefulset.schema.json
+var statefulSetSchema []byte
+
+// statefulSetLabelsConfig holds the label keys to propagate with their pod qualifier
+type statefulSetLabelsConfig struct {
+	qualifier string   // Which pods this applies to
+	keys      []string // Label keys to copy from the StatefulSet
+}
+
+// Ensure StatefulSetLabelsHandler implements MetadataHandler interface
+var _ annotation.MetadataHandler = (*StatefulSetLabelsHandler)(nil)
+
+// StatefulSetLabelsHandler copies the configured labels of the owning
+// StatefulSet onto the pod, e.g. for monitoring selecting on them
+type StatefulSetLabelsHandler struct{}
+
+// Mutate leaves the pod spec untouched, the labels are set by MutateMeta
+func (h *StatefulSetLabelsHandler) Mutate(_ *corev1.PodSpec, _ annotation.MutationContext, cfg any) error {
+	if _, ok := cfg.([]*statefulSetLabelsConfig); !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*statefulSetLabelsConfig", cfg)
+	}
+	return nil
+}
+
+// MutateMeta sets the configured labels to their values on the StatefulSet.
+// Labels the StatefulSet doesn't carry are skipped.
+func (h *StatefulSetLabelsHandler) MutateMeta(meta *metav1.ObjectMeta, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*statefulSetLabelsConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*statefulSetLabelsConfig", cfg)
+	}
+
+	if mc.StatefulSet == nil {
+		l.Info("StatefulSet unavailable, not propagating labels")
+		return nil
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.applyMeta(meta, mc, l)
+	}
+
+	return nil
+}
+
+// applyMeta copies the StatefulSet labels of a single configuration
+func (c *statefulSetLabelsConfig) applyMeta(meta *metav1.ObjectMeta, mc annotation.MutationContext, l logr.Logger) {
+	for _, key := range c.keys {
+		value, ok := mc.StatefulSet.Labels[key]
+		if !ok {
+			l.Info("StatefulSet has no such label, skipping", "key", key)
+			continue
+		}
+		if meta.Labels == nil {
+			meta.Labels = make(map[string]string)
+		}
+		l.Info("propagating StatefulSet label", "key", key, "value", value)
+		meta.Labels[key] = value
+	}
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *StatefulSetLabelsHandler) Name() string {
+	return StatefulSetLabels
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *StatefulSetLabelsHandler) Schema() []byte {
+	return statefulSetSchema
+}
+
+// GetParser returns the parser for StatefulSet label annotations
+func (h *StatefulSetLabelsHandler) GetParser() annotation.Parser {
+	return statefulSetLabelsParser
+}
+
+// statefulSetLabelsParser parses every StatefulSet label annotation, whatever its
+// qualifier, into a statefulSetLabelsConfig, returning them in annotation key
+// order
+var statefulSetLabelsParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*statefulSetLabelsConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != StatefulSetLabels {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing StatefulSet labels configuration")
+
+		var keys []string
+		if err := annotation.Unmarshal(v, &keys); err != nil {
+			logger.Error(err, "failed to parse StatefulSet labels configuration")
+			return nil, fmt.Errorf("invalid StatefulSet labels configuration: %w", err)
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("invalid StatefulSet labels configuration: no label keys")
+		}
+		for _, key := range keys {
+			if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid StatefulSet label key %q: %s", key, strings.Join(errs, "; "))
+			}
+		}
+
+		configs = append(configs, &statefulSetLabelsConfig{
+			qualifier: k.Qualifier,
+			keys:      keys,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,198 @@
+package labels
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStatefulSetLabelsHandler_MutateMeta(t *testing.T) {
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web",
+			Labels: map[string]string{
+				"team":                "storage",
+				"example.com/monitor": "true",
+				"internal":            "yes",
+			},
+		},
+	}
+
+	type args struct {
+		meta *metav1.ObjectMeta
+		mc   annotation.MutationContext
+		cfg  any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *metav1.ObjectMeta
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				meta: nil,
+				cfg:  nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				meta: &metav1.ObjectMeta{},
+				mc:   annotation.MutationContext{Ordinal: 0, StatefulSet: sts},
+				cfg: []*statefulSetLabelsConfig{{
+					qualifier: "1-2",
+					keys:      []string{"team"},
+				}},
+			},
+			want:    &metav1.ObjectMeta{},
+			wantErr: false,
+		},
+		{
+			name: "do nothing without the StatefulSet",
+			args: args{
+				meta: &metav1.ObjectMeta{},
+				mc:   annotation.MutationContext{Ordinal: 1},
+				cfg: []*statefulSetLabelsConfig{{
+					keys: []string{"team"},
+				}},
+			},
+			want:    &metav1.ObjectMeta{},
+			wantErr: false,
+		},
+		{
+			name: "propagate the configured labels present on the StatefulSet",
+			args: args{
+				meta: &metav1.ObjectMeta{
+					Labels: map[string]string{"app": "web"},
+				},
+				mc: annotation.MutationContext{Ordinal: 1, StatefulSet: sts},
+				cfg: []*statefulSetLabelsConfig{{
+					keys: []string{"team", "example.com/monitor", "missing"},
+				}},
+			},
+			want: &metav1.ObjectMeta{
+				Labels: map[string]string{
+					"app":                 "web",
+					"team":                "storage",
+					"example.com/monitor": "true",
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &StatefulSetLabelsHandler{}
+			if err := h.MutateMeta(tt.args.meta, tt.args.mc, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("MutateMeta() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.meta, tt.want) {
+				t.Errorf("MutateMeta() = %v, want %v", tt.args.meta, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatefulSetLabelsHandler_MutateMeta_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: StatefulSetLabels}:  `["team"]`,
+		{Qualifier: "1-", Name: StatefulSetLabels}: `["tier"]`,
+	}
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "storage", "tier": "backend"}},
+	}
+
+	h := &StatefulSetLabelsHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for ordinal, want := range map[int]map[string]string{
+		0: {"team": "storage"},
+		3: {"tier": "backend"},
+	} {
+		meta := &metav1.ObjectMeta{}
+		if err := h.MutateMeta(meta, annotation.MutationContext{Ordinal: ordinal, StatefulSet: sts}, cfg); err != nil {
+			t.Fatalf("MutateMeta() ordinal %d error = %v", ordinal, err)
+		}
+		if !reflect.DeepEqual(meta.Labels, want) {
+			t.Errorf("MutateMeta() ordinal %d labels = %v, want %v", ordinal, meta.Labels, want)
+		}
+	}
+}
+
+func Test_statefulSetLabelsParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       statefulSetLabelsParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "JSON list",
+			p:    statefulSetLabelsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "1-", Name: StatefulSetLabels}: `["team","example.com/monitor"]`,
+			}},
+			want:    []*statefulSetLabelsConfig{{qualifier: "1-", keys: []string{"team", "example.com/monitor"}}},
+			wantErr: false,
+		},
+		{
+			name: "YAML list",
+			p:    statefulSetLabelsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: StatefulSetLabels}: "- team\n- tier\n",
+			}},
+			want:    []*statefulSetLabelsConfig{{keys: []string{"team", "tier"}}},
+			wantErr: false,
+		},
+		{
+			name: "empty list",
+			p:    statefulSetLabelsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: StatefulSetLabels}: `[]`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid label key",
+			p:    statefulSetLabelsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: StatefulSetLabels}: `["not a key"]`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package annotation
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// logRateLimiterPruneSize is the number of tracked messages above which
+// expired ones are dropped
+const logRateLimiterPruneSize = 1024
+
+// RateLimitLogs returns a logger passing at most burst identical info messages
+// per named logger, e.g. per handler, within each window. Suppressed messages
+// are counted and reported as "suppressed" on the next one passed. Errors are
+// never suppressed. A non-positive burst returns l unchanged.
+func RateLimitLogs(l logr.Logger, burst int, window time.Duration) logr.Logger {
+	if burst <= 0 || l.GetSink() == nil {
+		return l
+	}
+	limiter := &logRateLimiter{
+		burst:   burst,
+		window:  window,
+		now:     time.Now,
+		entries: make(map[logRateKey]*logRateEntry),
+	}
+	return logr.New(newRateLimitedSink(l.GetSink(), "", limiter))
+}
+
+// logRateKey identifies identical messages of a named logger
+type logRateKey struct {
+	name string
+	msg  string
+}
+
+// logRateEntry counts the messages logged within the current window
+type logRateEntry struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// logRateLimiter throttles identical messages within fixed windows
+type logRateLimiter struct {
+	mu      sync.Mutex
+	burst   int
+	window  time.Duration
+	now     func() time.Time
+	entries map[logRateKey]*logRateEntry
+}
+
+// allow reports whether a message may be logged, and if so how many identical
+// ones were suppressed since the last one logged
+func (r *logRateLimiter) allow(key logRateKey) (bool, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	e, ok := r.entries[key]
+	if !ok || now.Sub(e.start) >= r.window {
+		if !ok {
+			r.prune(now)
+			e = &logRateEntry{}
+			r.entries[key] = e
+		}
+		e.start = now
+		e.count = 0
+	}
+
+	if e.count >= r.burst {
+		e.suppressed++
+		return false, 0
+	}
+	e.count++
+	suppressed := e.suppressed
+	e.suppressed = 0
+	return true, suppressed
+}
+
+// prune drops the messages whose window ended once too many are tracked
+func (r *logRateLimiter) prune(now time.Time) {
+	if len(r.entries) < logRateLimiterPruneSize {
+		return
+	}
+	for k, e := range r.entries {
+		if now.Sub(e.start) >= r.window && e.suppressed == 0 {
+			delete(r.entries, k)
+		}
+	}
+}
+
+// rateLimitedSink passes info messages through its limiter, keyed by the
+// logger name
+type rateLimitedSink struct {
+	logr.LogSink
+	name    string
+	limiter *logRateLimiter
+}
+
+// newRateLimitedSink wraps sink, accounting for the extra call frame
+func newRateLimitedSink(sink logr.LogSink, name string, limiter *logRateLimiter) logr.LogSink {
+	if cd, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = cd.WithCallDepth(1)
+	}
+	return &rateLimitedSink{LogSink: sink, name: name, limiter: limiter}
+}
+
+// Info implements logr.LogSink
+func (s *rateLimitedSink) Info(level int, msg string, keysAndValues ...any) {
+	ok, suppressed := s.limiter.allow(logRateKey{name: s.name, msg: msg})
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		keysAndValues = append(keysAndValues, "suppressed", suppressed)
+	}
+	s.LogSink.Info(level, msg, keysAndValues...)
+}
+
+// WithValues implements logr.LogSink
+func (s *rateLimitedSink) WithValues(keysAndValues ...any) logr.LogSink {
+	return &rateLimitedSink{LogSink: s.LogSink.WithValues(keysAndValues...), name: s.name, limiter: s.limiter}
+}
+
+// WithName implements logr.LogSink
+func (s *rateLimitedSink) WithName(name string) logr.LogSink {
+	full := name
+	if s.name != "" {
+		full = s.name + "." + name
+	}
+	return &rateLimitedSink{LogSink: s.LogSink.WithName(name), name: full, limiter: s.limiter}
+}
+
+// WithCallDepth implements logr.CallDepthLogSink
+func (s *rateLimitedSink) WithCallDepth(depth int) logr.LogSink {
+	cd, ok := s.LogSink.(logr.CallDepthLogSink)
+	if !ok {
+		return s
+	}
+	return &rateLimitedSink{LogSink: cd.WithCallDepth(depth), name: s.name, limiter: s.limiter}
+}
@@ -0,0 +1,63 @@
+package annotation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+)
+
+func TestRateLimitLogs(t *testing.T) {
+	var lines []string
+	sink := funcr.New(func(prefix, args string) {
+		lines = append(lines, prefix+" "+args)
+	}, funcr.Options{}).GetSink()
+
+	now := time.Unix(0, 0)
+	limiter := &logRateLimiter{
+		burst:   2,
+		window:  time.Minute,
+		now:     func() time.Time { return now },
+		entries: make(map[logRateKey]*logRateEntry),
+	}
+	root := logr.New(newRateLimitedSink(sink, "", limiter))
+	hostPort := root.WithName("host_port")
+	mount := root.WithName("mount_volume")
+
+	// Identical messages beyond the burst are suppressed within the window,
+	// whatever their values
+	for i := 0; i < 5; i++ {
+		hostPort.WithValues("ordinal", i).Info("modifying hostPort")
+	}
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines within the window, want 2: %q", len(lines), lines)
+	}
+
+	// Other messages and other features have their own budget, and errors
+	// always pass
+	hostPort.Info("adding new port")
+	mount.Info("modifying hostPort")
+	hostPort.Error(nil, "modifying hostPort")
+	if len(lines) != 5 {
+		t.Fatalf("logged %d lines, want 5: %q", len(lines), lines)
+	}
+
+	// The next window passes messages again, reporting the suppressed ones
+	now = now.Add(time.Minute)
+	hostPort.Info("modifying hostPort")
+	if len(lines) != 6 {
+		t.Fatalf("logged %d lines after the window, want 6: %q", len(lines), lines)
+	}
+	want := `host_port "level"=0 "msg"="modifying hostPort" "suppressed"=3`
+	if got := lines[5]; got != want {
+		t.Errorf("line after the window = %q, want %q", got, want)
+	}
+}
+
+func TestRateLimitLogs_Disabled(t *testing.T) {
+	l := funcr.New(func(string, string) {}, funcr.Options{})
+	if got := RateLimitLogs(l, 0, time.Minute); got != l {
+		t.Errorf("RateLimitLogs() with no burst wrapped the logger")
+	}
+}
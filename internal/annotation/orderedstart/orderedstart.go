@@ -0,0 +1,205 @@
+package orderedstart
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// OrderedStart is the annotation key for ordered start configuration
+	OrderedStart = "ordered-start"
+	// InitContainerName is the name of the injected init container
+	InitContainerName = "spoditor-ordered-start"
+	// defaultImage provides the shell and nslookup the poll command runs with
+	defaultImage = "busybox:1.36"
+	// defaultIntervalSeconds is the pause between two polls of a peer
+	defaultIntervalSeconds = 2
+	// pollScript polls the peers between two ordinals, pausing in between. The
+	// names it resolves come from the init container env, never from the script.
+	pollScript = `for i in $(seq %d %d); do until nslookup "$STATEFULSET-$i.$SERVICE.$NAMESPACE.svc" >/dev/null 2>&1; do echo "waiting for $STATEFULSET-$i"; sleep %d; done; done`
+)
+
+var log = logf.Log.WithName("ordered_start")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed orderedstart.schema.json
+var schema []byte
+
+// orderedStartConfig holds the ordered start configuration with its pod qualifier
+type orderedStartConfig struct {
+	qualifier string                   // Which pods this applies to
+	cfg       *orderedStartConfigValue // The actual ordered start configuration
+}
+
+// orderedStartConfigValue represents the JSON structure of the ordered start
+// configuration
+type orderedStartConfigValue struct {
+	// ServiceName is the headless service the peers are resolved through,
+	// the StatefulSet's service name when empty
+	ServiceName string `json:"serviceName,omitempty"`
+	// Image runs the poll command, defaultImage when empty
+	Image string `json:"image,omitempty"`
+	// IntervalSeconds is the pause between two polls, defaultIntervalSeconds
+	// when zero
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+}
+
+// Ensure OrderedStartHandler implements Handler interface
+var _ annotation.Handler = (*OrderedStartHandler)(nil)
+
+// OrderedStartHandler injects an init container holding each pod back until
+// its lower-ordinal peers are ready, e.g. for clustered apps that must join
+// an existing member. A peer counts as ready once its name resolves through
+// the headless service, which only publishes ready pods by default.
+type OrderedStartHandler struct{}
+
+// Mutate prepends the init container polling the peers below the pod
+// ordinal. The first ordinal has no peers to wait for and is left alone.
+func (h *OrderedStartHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*orderedStartConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*orderedStartConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply prepends the init container of a single configuration
+func (c *orderedStartConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	start := 0
+	serviceName := c.cfg.ServiceName
+	if mc.StatefulSet != nil {
+		if mc.StatefulSet.Spec.Ordinals != nil {
+			start = int(mc.StatefulSet.Spec.Ordinals.Start)
+		}
+		if serviceName == "" {
+			serviceName = mc.StatefulSet.Spec.ServiceName
+		}
+	}
+	if serviceName == "" {
+		l.Info("no headless service known, not waiting for peers")
+		return
+	}
+	if errs := validation.IsDNS1123Label(serviceName); len(errs) > 0 {
+		l.Info("invalid headless service name, not waiting for peers", "service", serviceName, "errors", errs)
+		return
+	}
+
+	peers := mc.Ordinal - start
+	if peers <= 0 {
+		l.Info("no lower-ordinal peers to wait for")
+		return
+	}
+
+	for _, ic := range spec.InitContainers {
+		if ic.Name == InitContainerName {
+			l.Info("init container already present, skipping")
+			return
+		}
+	}
+
+	l.Info("adding init container waiting for peers", "peers", peers, "service", serviceName)
+	spec.InitContainers = append([]corev1.Container{c.cfg.initContainer(mc.SSName, serviceName, start, peers)}, spec.InitContainers...)
+}
+
+// initContainer returns the init container polling the peers ordinals start
+// to start+peers-1 of the StatefulSet through the headless service. The peers
+// are resolved by their fully qualified names, in the pod namespace read from
+// the downward API.
+func (c *orderedStartConfigValue) initContainer(ssName, serviceName string, start, peers int) corev1.Container {
+	image := c.Image
+	if image == "" {
+		image = defaultImage
+	}
+	interval := c.IntervalSeconds
+	if interval == 0 {
+		interval = defaultIntervalSeconds
+	}
+
+	return corev1.Container{
+		Name:    InitContainerName,
+		Image:   image,
+		Command: []string{"sh", "-c", fmt.Sprintf(pollScript, start, start+peers-1, interval)},
+		Env: []corev1.EnvVar{
+			{Name: "STATEFULSET", Value: ssName},
+			{Name: "SERVICE", Value: serviceName},
+			{Name: "NAMESPACE", ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "metadata.namespace"},
+			}},
+		},
+	}
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *OrderedStartHandler) Name() string {
+	return OrderedStart
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *OrderedStartHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for ordered start annotations
+func (h *OrderedStartHandler) GetParser() annotation.Parser {
+	return orderedStartParser
+}
+
+// orderedStartParser parses every ordered start annotation, whatever its
+// qualifier, into an orderedStartConfig, returning them in annotation key order
+var orderedStartParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*orderedStartConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != OrderedStart {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing ordered start configuration")
+
+		value := &orderedStartConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse ordered start configuration")
+			return nil, fmt.Errorf("invalid ordered start configuration: %w", err)
+		}
+		if value.IntervalSeconds < 0 {
+			return nil, fmt.Errorf("invalid ordered start configuration: intervalSeconds %d must not be negative", value.IntervalSeconds)
+		}
+		if value.ServiceName != "" {
+			if errs := validation.IsDNS1123Label(value.ServiceName); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid ordered start service name %q: %s", value.ServiceName, strings.Join(errs, "; "))
+			}
+		}
+
+		configs = append(configs, &orderedStartConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,272 @@
+package orderedstart
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOrderedStartHandler_Mutate(t *testing.T) {
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db"},
+		Spec:       appsv1.StatefulSetSpec{ServiceName: "db-headless"},
+	}
+
+	type args struct {
+		spec *corev1.PodSpec
+		mc   annotation.MutationContext
+		cfg  any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec: nil,
+				mc:   annotation.MutationContext{},
+				cfg:  nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc:   annotation.MutationContext{Ordinal: 2, SSName: "db", StatefulSet: sts},
+				cfg:  []*orderedStartConfig{{qualifier: "3-", cfg: &orderedStartConfigValue{}}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "first ordinal has no peers to wait for",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc:   annotation.MutationContext{Ordinal: 0, SSName: "db", StatefulSet: sts},
+				cfg:  []*orderedStartConfig{{cfg: &orderedStartConfigValue{}}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "do nothing without a known headless service",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc:   annotation.MutationContext{Ordinal: 2, SSName: "db"},
+				cfg:  []*orderedStartConfig{{cfg: &orderedStartConfigValue{}}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "do nothing when the headless service isn't a DNS label",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc: annotation.MutationContext{Ordinal: 2, SSName: "db", StatefulSet: &appsv1.StatefulSet{
+					Spec: appsv1.StatefulSetSpec{ServiceName: "db;reboot"},
+				}},
+				cfg: []*orderedStartConfig{{cfg: &orderedStartConfigValue{}}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "wait for lower ordinals ahead of existing init containers",
+			args: args{
+				spec: &corev1.PodSpec{InitContainers: []corev1.Container{{Name: "migrate"}}},
+				mc:   annotation.MutationContext{Ordinal: 2, SSName: "db", StatefulSet: sts},
+				cfg:  []*orderedStartConfig{{cfg: &orderedStartConfigValue{}}},
+			},
+			want: &corev1.PodSpec{InitContainers: []corev1.Container{
+				{
+					Name:  InitContainerName,
+					Image: defaultImage,
+					Command: []string{"sh", "-c",
+						`for i in $(seq 0 1); do until nslookup "$STATEFULSET-$i.$SERVICE.$NAMESPACE.svc" >/dev/null 2>&1; do echo "waiting for $STATEFULSET-$i"; sleep 2; done; done`},
+					Env: peerEnv("db", "db-headless"),
+				},
+				{Name: "migrate"},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "configured service, image and interval",
+			args: args{
+				spec: &corev1.PodSpec{},
+				mc:   annotation.MutationContext{Ordinal: 1, SSName: "db"},
+				cfg: []*orderedStartConfig{{cfg: &orderedStartConfigValue{
+					ServiceName:     "peers",
+					Image:           "alpine:3",
+					IntervalSeconds: 5,
+				}}},
+			},
+			want: &corev1.PodSpec{InitContainers: []corev1.Container{{
+				Name:  InitContainerName,
+				Image: "alpine:3",
+				Command: []string{"sh", "-c",
+					`for i in $(seq 0 0); do until nslookup "$STATEFULSET-$i.$SERVICE.$NAMESPACE.svc" >/dev/null 2>&1; do echo "waiting for $STATEFULSET-$i"; sleep 5; done; done`},
+				Env: peerEnv("db", "peers"),
+			}}},
+			wantErr: false,
+		},
+		{
+			name: "keep an init container already present",
+			args: args{
+				spec: &corev1.PodSpec{InitContainers: []corev1.Container{{Name: InitContainerName, Image: "custom"}}},
+				mc:   annotation.MutationContext{Ordinal: 2, SSName: "db", StatefulSet: sts},
+				cfg:  []*orderedStartConfig{{cfg: &orderedStartConfigValue{}}},
+			},
+			want:    &corev1.PodSpec{InitContainers: []corev1.Container{{Name: InitContainerName, Image: "custom"}}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &OrderedStartHandler{}
+			if err := h.Mutate(tt.args.spec, tt.args.mc, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+// peerEnv returns the env the init container resolves the peers with
+func peerEnv(ssName, serviceName string) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: "STATEFULSET", Value: ssName},
+		{Name: "SERVICE", Value: serviceName},
+		{Name: "NAMESPACE", ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "metadata.namespace"},
+		}},
+	}
+}
+
+func TestOrderedStartHandler_Mutate_PeerCount(t *testing.T) {
+	tests := []struct {
+		ordinal int
+		start   int32
+		want    string
+	}{
+		{ordinal: 1, start: 0, want: "seq 0 0"},
+		{ordinal: 5, start: 0, want: "seq 0 4"},
+		{ordinal: 12, start: 10, want: "seq 10 11"},
+	}
+	for _, tt := range tests {
+		sts := &appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{
+			ServiceName: "db",
+			Ordinals:    &appsv1.StatefulSetOrdinals{Start: tt.start},
+		}}
+		spec := &corev1.PodSpec{}
+		mc := annotation.MutationContext{Ordinal: tt.ordinal, SSName: "db", StatefulSet: sts}
+		if err := (&OrderedStartHandler{}).Mutate(spec, mc, []*orderedStartConfig{{cfg: &orderedStartConfigValue{}}}); err != nil {
+			t.Fatalf("Mutate() ordinal %d error = %v", tt.ordinal, err)
+		}
+		if len(spec.InitContainers) != 1 || !strings.Contains(spec.InitContainers[0].Command[2], tt.want) {
+			t.Errorf("Mutate() ordinal %d init containers = %v, want the command to poll %s", tt.ordinal, spec.InitContainers, tt.want)
+		}
+	}
+}
+
+func TestOrderedStartHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0-2", Name: OrderedStart}: `{"image":"alpine:3"}`,
+		{Qualifier: "3-", Name: OrderedStart}:  `{"image":"busybox:1.37"}`,
+	}
+	sts := &appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{ServiceName: "db"}}
+
+	for ordinal, want := range map[int]string{1: "alpine:3", 4: "busybox:1.37"} {
+		mc := annotation.MutationContext{Ordinal: ordinal, SSName: "db", StatefulSet: sts}
+		spec, err := annotationtest.Apply(&OrderedStartHandler{}, annotations, &corev1.PodSpec{}, mc)
+		if err != nil {
+			t.Fatalf("Apply() ordinal %d error = %v", ordinal, err)
+		}
+		if len(spec.InitContainers) != 1 || spec.InitContainers[0].Image != want {
+			t.Errorf("Apply() ordinal %d init containers = %v, want one running %s", ordinal, spec.InitContainers, want)
+		}
+	}
+}
+
+func Test_orderedStartParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       orderedStartParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    orderedStartParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "1-", Name: OrderedStart}: `{"serviceName":"peers","intervalSeconds":5}`,
+			}},
+			want: []*orderedStartConfig{{
+				qualifier: "1-",
+				cfg:       &orderedStartConfigValue{ServiceName: "peers", IntervalSeconds: 5},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "service name not a DNS label",
+			p:    orderedStartParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: OrderedStart}: `{"serviceName":"db; rm -rf /"}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "negative interval",
+			p:    orderedStartParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: OrderedStart}: `{"intervalSeconds":-1}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid json",
+			p:    orderedStartParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: OrderedStart}: `{"serviceName":`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
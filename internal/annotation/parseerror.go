@@ -0,0 +1,30 @@
+package annotation
+
+import "fmt"
+
+// ParseError reports an annotation whose value could not be parsed into a
+// handler configuration. Callers can use errors.As to recover the offending
+// annotation and render a structured message
+type ParseError struct {
+	// Name is the qualified name of the offending annotation
+	Name QualifiedName
+	// Value is the raw annotation value
+	Value string
+	// Err is the underlying decoding or validation error
+	Err error
+}
+
+// NewParseError wraps err as a ParseError for the annotation k with value v
+func NewParseError(k QualifiedName, v string, err error) *ParseError {
+	return &ParseError{Name: k, Value: v, Err: err}
+}
+
+// Error names the annotation by its key under Collector, so the message shows
+// the key as written with a custom prefix or separator
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid %s configuration in %s: %v", e.Name.Name, KeyOf(Collector, e.Name), e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -1,12 +1,14 @@
 package ports
 
 import (
+	_ "embed"
 	"fmt"
+	"slices"
 	"strconv"
 
+	"github.com/go-logr/logr"
 	"github.com/golem-base/spoditor/internal/annotation"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/json"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -15,8 +17,10 @@ const (
 	HostPort = "host-port"
 	// PodOrdinal is the environment variable name for pod ordinal
 	PodOrdinal = "POD_ORDINAL"
-	// PortPrefix is the prefix for port environment variables
+	// PortPrefix is the default prefix for port environment variables
 	PortPrefix = "PORT_"
+	// ContainerPortPrefix is the prefix for offset container port environment variables
+	ContainerPortPrefix = "CPORT_"
 )
 
 // Compile-time interface check
@@ -24,6 +28,11 @@ var _ annotation.Handler = (*HostPortHandler)(nil)
 
 var log = logf.Log.WithName("host_port")
 
+// schema is the JSON Schema of the annotation value
+//
+//go:embed hostport.schema.json
+var schema []byte
+
 // portConfig holds the port modification configuration with its pod qualifier
 type portConfig struct {
 	qualifier string
@@ -33,33 +42,176 @@ type portConfig struct {
 // portConfigValue represents the JSON structure of the port modification configuration
 type portConfigValue struct {
 	Containers []containerPortsConfig `json:"containers"`
+	// PortEnvPrefix prefixes the injected port environment variables,
+	// PortPrefix when empty
+	PortEnvPrefix string `json:"portEnvPrefix,omitempty"`
+	// OrdinalPortMap sets the host port of the listed ordinals explicitly,
+	// e.g. for externally managed ports that aren't contiguous. Other
+	// ordinals get hostPort + ordinal. Only valid with a single host port.
+	OrdinalPortMap map[int]int32 `json:"ordinalPortMap,omitempty"`
+	// InjectEnv injects the POD_ORDINAL and port env vars into the matched
+	// containers, true when unset
+	InjectEnv *bool `json:"injectEnv,omitempty"`
+}
+
+// injectEnv reports whether env vars are injected, defaulting to true
+func (c *portConfigValue) injectEnv() bool {
+	return c.InjectEnv == nil || *c.InjectEnv
+}
+
+// hostPortFor returns the (first) host port of p for the given ordinal: the
+// mapped one if the ordinal is listed in OrdinalPortMap, hostPort + ordinal
+// times the range size otherwise. It is computed in int64, so callers can
+// check it is a valid port before narrowing it.
+func (c *portConfigValue) hostPortFor(p hostPortConfig, ordinal int) int64 {
+	if mapped, ok := c.OrdinalPortMap[ordinal]; ok {
+		return int64(mapped)
+	}
+	return int64(p.HostPort) + int64(ordinal)*int64(p.rangeSize())
+}
+
+// envPrefix returns the configured port environment variable prefix or the
+// default one
+func (c *portConfigValue) envPrefix() string {
+	if c.PortEnvPrefix == "" {
+		return PortPrefix
+	}
+	return c.PortEnvPrefix
 }
 
 // containerPortsConfig defines the ports to modify for a specific container
 type containerPortsConfig struct {
-	Name  string                 `json:"name"`
-	Ports []corev1.ContainerPort `json:"ports"`
+	Name  string           `json:"name"`
+	Ports []hostPortConfig `json:"ports"`
+	// OffsetContainerPort also offsets the container ports by the ordinal,
+	// for pods listening on a distinct port each, and injects them as
+	// CPORT_<name> env vars. Container ports are left untouched when unset.
+	OffsetContainerPort bool `json:"offsetContainerPort,omitempty"`
+}
+
+// hostPortConfig is a container port whose host port may be a block of
+// contiguous host ports
+type hostPortConfig struct {
+	corev1.ContainerPort
+	// HostPortRangeSize, when above 1, reserves that many contiguous host
+	// ports per ordinal, from hostPort + ordinal*size, e.g. for RTP media
+	// ranges. The container ports of the block follow containerPort.
+	HostPortRangeSize int32 `json:"hostPortRangeSize,omitempty"`
+}
+
+// rangeSize returns the number of host ports the port reserves per ordinal
+func (p hostPortConfig) rangeSize() int32 {
+	return max(p.HostPortRangeSize, 1)
+}
+
+// protocol returns the protocol of the port, TCP when unset
+func (p hostPortConfig) protocol() corev1.Protocol {
+	return protocolOrTCP(p.Protocol)
+}
+
+// protocolOrTCP returns the protocol, defaulting to TCP like Kubernetes does
+func protocolOrTCP(protocol corev1.Protocol) corev1.Protocol {
+	if protocol == "" {
+		return corev1.ProtocolTCP
+	}
+	return protocol
+}
+
+// validate checks that port names are unique within each container and that
+// port numbers are in range, since either would make the mutated pod invalid,
+// and that an ordinalPortMap applies to a single host port
+func (c *portConfigValue) validate() error {
+	hostPorts := 0
+	for _, container := range c.Containers {
+		// Port names must be unique within a pod, so the same ports can't be
+		// added to every container
+		if container.Name == annotation.WildcardContainer {
+			return fmt.Errorf("the %q container wildcard is not supported for ports", annotation.WildcardContainer)
+		}
+		names := make(map[string]bool, len(container.Ports))
+		for _, p := range container.Ports {
+			if p.Name != "" {
+				if names[p.Name] {
+					return fmt.Errorf("container %q: duplicate port name %q", container.Name, p.Name)
+				}
+				names[p.Name] = true
+			}
+			if p.ContainerPort.ContainerPort < 1 || p.ContainerPort.ContainerPort > 65535 {
+				return fmt.Errorf("container %q: port %q: containerPort %d must be between 1 and 65535", container.Name, p.Name, p.ContainerPort.ContainerPort)
+			}
+			if p.HostPort < 0 || p.HostPort > 65535 {
+				return fmt.Errorf("container %q: port %q: hostPort %d must be between 0 and 65535", container.Name, p.Name, p.HostPort)
+			}
+			if p.HostPortRangeSize < 0 {
+				return fmt.Errorf("container %q: port %q: hostPortRangeSize %d must not be negative", container.Name, p.Name, p.HostPortRangeSize)
+			}
+			if p.HostPortRangeSize > 1 {
+				if p.Name == "" || p.HostPort == 0 {
+					return fmt.Errorf("container %q: a hostPortRangeSize needs a named port with a hostPort", container.Name)
+				}
+				if end := p.ContainerPort.ContainerPort + p.HostPortRangeSize - 1; end > 65535 {
+					return fmt.Errorf("container %q: port %q: containerPort range end %d must not exceed 65535", container.Name, p.Name, end)
+				}
+			}
+			if p.HostPort > 0 {
+				hostPorts++
+			}
+		}
+	}
+
+	if len(c.OrdinalPortMap) == 0 {
+		return nil
+	}
+	// The map holds a single port per ordinal, so it must not be ambiguous
+	if hostPorts != 1 {
+		return fmt.Errorf("ordinalPortMap needs exactly one port with a hostPort, found %d", hostPorts)
+	}
+	for ordinal, port := range c.OrdinalPortMap {
+		if ordinal < 0 {
+			return fmt.Errorf("ordinalPortMap: ordinal %d must not be negative", ordinal)
+		}
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("ordinalPortMap: hostPort %d of ordinal %d must be between 1 and 65535", port, ordinal)
+		}
+	}
+	return nil
 }
 
 // HostPortHandler implements the handler interface for modifying container ports
-type HostPortHandler struct{}
+type HostPortHandler struct {
+	// Strict rejects annotation values with fields the configuration doesn't
+	// know about, instead of ignoring them
+	Strict bool
+}
 
-// Mutate modifies the container ports in the pod spec based on the configuration
-func (h *HostPortHandler) Mutate(spec *corev1.PodSpec, ordinal int, cfg any) error {
-	logger := log.WithValues("ordinal", ordinal)
+// Mutate modifies the container ports in the pod spec based on every
+// configuration whose qualifier matches the pod ordinal
+func (h *HostPortHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	logger := log.WithValues("ordinal", mc.Ordinal)
 
 	// Type assertion for our config
-	m, ok := cfg.(*portConfig)
+	configs, ok := cfg.([]*portConfig)
 	if !ok {
 		return fmt.Errorf("unexpected config type %T", cfg)
 	}
 
-	// Check if this pod matches the qualifier
-	if !annotation.CommonPodQualifier(ordinal, m.qualifier) {
-		logger.Info("qualifier excludes this pod")
-		return nil
+	for _, m := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(m.qualifier) {
+			logger.Info("qualifier excludes this pod", "qualifier", m.qualifier)
+			continue
+		}
+		if err := m.apply(spec, mc, logger); err != nil {
+			return err
+		}
 	}
 
+	return nil
+}
+
+// apply modifies the container ports of a single configuration. It fails
+// when the host port computed for the ordinal is out of range.
+func (m *portConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, logger logr.Logger) error {
 	logger.Info("modifying container ports for pod")
 
 	// Map to collect port assignments to inject as environment variables
@@ -80,60 +232,107 @@ func (h *HostPortHandler) Mutate(spec *corev1.PodSpec, ordinal int, cfg any) err
 			containerLogger := logger.WithValues("container", containerConfig.Name)
 			containerLogger.Info("processing container")
 
+			// Reserved host port ranges by port name, to detect overlaps
+			var reserved []hostPortRange
+
 			// Process each port in the config
 			for _, portConfig := range containerConfig.Ports {
-				// Skip ports with no hostPort defined
-				if portConfig.HostPort <= 0 {
+				// Skip ports with no hostPort defined, unless their container
+				// port is offset
+				if portConfig.HostPort <= 0 && !containerConfig.OffsetContainerPort {
 					continue
 				}
+				size := portConfig.rangeSize()
+
+				// Calculate new containerPort with ordinal offset, if asked for
+				newContainerPort := portConfig.ContainerPort.ContainerPort
+				if containerConfig.OffsetContainerPort {
+					// Computed in int64 so large ordinals can't wrap around
+					first := int64(newContainerPort) + int64(mc.Ordinal)*int64(size)
+					if last := first + int64(size) - 1; last > 65535 {
+						return fmt.Errorf("container %q: port %q: containerPort %d + ordinal %d = %d must not exceed 65535",
+							containerConfig.Name, portConfig.Name, portConfig.ContainerPort.ContainerPort, mc.Ordinal, last)
+					}
+					newContainerPort = int32(first)
+					if portConfig.Name != "" {
+						portEnvVars[containerConfig.Name][ContainerPortPrefix+portConfig.Name] = strconv.Itoa(int(newContainerPort))
+					}
+				}
 
-				// Calculate new hostPort with ordinal offset
-				newHostPort := int32(portConfig.HostPort) + int32(ordinal)
-				portVarName := fmt.Sprintf("%s%s", PortPrefix, portConfig.Name)
+				// Calculate new hostPort with ordinal offset, unless mapped explicitly
+				var newHostPort int32
+				var r hostPortRange
+				portVarName := fmt.Sprintf("%s%s", m.cfg.envPrefix(), portConfig.Name)
+				if portConfig.HostPort > 0 {
+					first := m.cfg.hostPortFor(portConfig, mc.Ordinal)
+					if last := first + int64(size) - 1; first < 1 || last > 65535 {
+						return fmt.Errorf("container %q: port %q: hostPort %d + ordinal %d = %d must be between 1 and 65535",
+							containerConfig.Name, portConfig.Name, portConfig.HostPort, mc.Ordinal, last)
+					}
+					newHostPort = int32(first)
+					r = hostPortRange{name: portConfig.Name, protocol: portConfig.protocol(), start: newHostPort, end: newHostPort + size - 1}
+					for _, other := range reserved {
+						if r.overlaps(other) {
+							return fmt.Errorf("container %q: host ports %d-%d of port %q overlap %d-%d of port %q",
+								containerConfig.Name, r.start, r.end, r.name, other.start, other.end, other.name)
+						}
+					}
+					reserved = append(reserved, r)
 
-				// Find if this port already exists in the container
-				foundPort := false
+					// Store for environment variable
+					portEnvVars[containerConfig.Name][portVarName] = strconv.Itoa(int(newHostPort))
+				}
 
-				// Look for ports with the same name
-				for j := range container.Ports {
-					if container.Ports[j].Name == portConfig.Name {
-						// Found matching port, update hostPort value
+				// Update the port if it already exists in the container, or add it
+				if j := findPort(container.Ports, portConfig, newContainerPort); j >= 0 {
+					// Found matching port, update its ports
+					if portConfig.HostPort > 0 {
 						containerLogger.Info("modifying hostPort",
 							"port", portConfig.Name,
 							"oldValue", container.Ports[j].HostPort,
 							"newValue", newHostPort)
 						container.Ports[j].HostPort = newHostPort
-
-						// Store for environment variable
-						portEnvVars[containerConfig.Name][portVarName] = strconv.Itoa(int(newHostPort))
-						foundPort = true
-						break
 					}
-				}
-
-				// If port wasn't found, add it
-				if !foundPort {
-					newPort := portConfig.DeepCopy()
+					if containerConfig.OffsetContainerPort {
+						containerLogger.Info("modifying containerPort",
+							"port", portConfig.Name,
+							"oldValue", container.Ports[j].ContainerPort,
+							"newValue", newContainerPort)
+						container.Ports[j].ContainerPort = newContainerPort
+					}
+				} else {
+					newPort := portConfig.ContainerPort.DeepCopy()
 					newPort.HostPort = newHostPort
+					newPort.ContainerPort = newContainerPort
 					containerLogger.Info("adding new port",
 						"port", newPort.Name,
+						"containerPort", newPort.ContainerPort,
 						"hostPort", newPort.HostPort)
 					container.Ports = append(container.Ports, *newPort)
+				}
 
-					// Store for environment variable
-					portEnvVars[containerConfig.Name][portVarName] = strconv.Itoa(int(newPort.HostPort))
+				// Ranges always have a host port, as validated
+				if size > 1 {
+					reserveRange(container, portConfig, newContainerPort, newHostPort, containerLogger)
+					portEnvVars[containerConfig.Name][portVarName+"_START"] = strconv.Itoa(int(r.start))
+					portEnvVars[containerConfig.Name][portVarName+"_END"] = strconv.Itoa(int(r.end))
 				}
 			}
 
+			if !m.cfg.injectEnv() {
+				containerLogger.Info("env var injection disabled, skipping")
+				continue
+			}
+
 			// Add pod ordinal as an environment variable
-			container.Env = appendEnvVarIfNotExists(container.Env, corev1.EnvVar{
+			container.Env = annotation.AppendEnvVarIfNotExists(container.Env, corev1.EnvVar{
 				Name:  PodOrdinal,
-				Value: strconv.Itoa(ordinal),
+				Value: strconv.Itoa(mc.Ordinal),
 			})
 
 			// Add port environment variables
 			for varName, varValue := range portEnvVars[containerConfig.Name] {
-				container.Env = appendEnvVarIfNotExists(container.Env, corev1.EnvVar{
+				container.Env = annotation.AppendEnvVarIfNotExists(container.Env, corev1.EnvVar{
 					Name:  varName,
 					Value: varValue,
 				})
@@ -144,42 +343,122 @@ func (h *HostPortHandler) Mutate(spec *corev1.PodSpec, ordinal int, cfg any) err
 	return nil
 }
 
-// appendEnvVarIfNotExists adds or updates an environment variable
-func appendEnvVarIfNotExists(envVars []corev1.EnvVar, envVar corev1.EnvVar) []corev1.EnvVar {
-	for i, existing := range envVars {
-		if existing.Name == envVar.Name {
-			envVars[i] = envVar
-			return envVars
+// findPort returns the index of the container port p configures, or -1. Named
+// ports are looked up by name, unnamed ones by protocol and container port,
+// either as configured or as offset to containerPort, so a pod admitted again
+// has its ports updated rather than added twice.
+func findPort(ports []corev1.ContainerPort, p hostPortConfig, containerPort int32) int {
+	return slices.IndexFunc(ports, func(existing corev1.ContainerPort) bool {
+		if p.Name != "" {
+			return existing.Name == p.Name
+		}
+		return existing.Name == "" && protocolOrTCP(existing.Protocol) == p.protocol() &&
+			(existing.ContainerPort == p.ContainerPort.ContainerPort || existing.ContainerPort == containerPort)
+	})
+}
+
+// hostPortRange is the block of host ports a named port reserves
+type hostPortRange struct {
+	name       string
+	protocol   corev1.Protocol
+	start, end int32
+}
+
+// overlaps reports whether two ranges share a host port of the same protocol
+func (r hostPortRange) overlaps(other hostPortRange) bool {
+	return r.protocol == other.protocol && r.start <= other.end && other.start <= r.end
+}
+
+// reserveRange adds the unnamed ports following the named port p, whose
+// container and host ports are containerStart and start, so the container
+// holds the whole block of ports. Ports already in the container are given
+// their host port instead.
+func reserveRange(container *corev1.Container, p hostPortConfig, containerStart, start int32, logger logr.Logger) {
+	for i := int32(1); i < p.rangeSize(); i++ {
+		port := corev1.ContainerPort{
+			ContainerPort: containerStart + i,
+			HostPort:      start + i,
+			Protocol:      p.Protocol,
+			HostIP:        p.HostIP,
+		}
+
+		found := false
+		for j := range container.Ports {
+			existing := &container.Ports[j]
+			if existing.ContainerPort == port.ContainerPort && protocolOrTCP(existing.Protocol) == p.protocol() {
+				existing.HostPort = port.HostPort
+				found = true
+				break
+			}
+		}
+		if !found {
+			container.Ports = append(container.Ports, port)
 		}
 	}
-	// Append if not found
-	return append(envVars, envVar)
+	logger.Info("reserved port range", "port", p.Name, "containerStart", containerStart, "start", start, "size", p.rangeSize())
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *HostPortHandler) Name() string {
+	return HostPort
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *HostPortHandler) Schema() []byte {
+	return schema
 }
 
 // GetParser returns the parser for port modification annotations
 func (h *HostPortHandler) GetParser() annotation.Parser {
+	if h.Strict {
+		return strictParser
+	}
 	return parser
 }
 
-// parser parses port modification annotations into a portConfig
-var parser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
-	for k, v := range annotations {
+var (
+	// parser parses port modification annotations into a portConfig
+	parser = newParser()
+	// strictParser is parser rejecting unknown fields
+	strictParser = newParser(annotation.Strict())
+)
+
+// newParser creates a port modification parser decoding with the given options
+func newParser(opts ...annotation.UnmarshalOption) annotation.ParserFunc {
+	return func(annotations map[annotation.QualifiedName]string) (any, error) {
+		return parsePorts(annotations, opts...)
+	}
+}
+
+// parsePorts parses every port modification annotation, whatever its
+// qualifier, into a portConfig, returning them in annotation key order
+func parsePorts(annotations map[annotation.QualifiedName]string, opts ...annotation.UnmarshalOption) (any, error) {
+	var configs []*portConfig
+	for _, k := range annotation.SortedKeys(annotations) {
 		if k.Name != HostPort {
 			continue
 		}
+		v := annotations[k]
 
 		logger := log.WithValues("qualifiedName", k, "value", v)
 		logger.Info("parsing port modification configuration")
 
 		c := &portConfigValue{}
-		if err := json.Unmarshal([]byte(v), c); err != nil {
-			return nil, fmt.Errorf("failed to parse port configuration: %w", err)
+		if err := annotation.Unmarshal(v, c, opts...); err != nil {
+			return nil, annotation.NewParseError(k, v, err)
+		}
+		if err := c.validate(); err != nil {
+			return nil, annotation.NewParseError(k, v, err)
 		}
 
-		return &portConfig{
+		configs = append(configs, &portConfig{
 			qualifier: k.Qualifier,
 			cfg:       c,
-		}, nil
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
 	}
-	return nil, nil
+	return configs, nil
 }
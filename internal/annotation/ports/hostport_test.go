@@ -1,14 +1,17 @@
 package ports
 
 import (
+	"errors"
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/golem-base/spoditor/internal/annotation"
 	corev1 "k8s.io/api/core/v1"
 )
 
-func TestPortModifierHandler_Mutate(t *testing.T) {
+func TestHostPortHandler_Mutate(t *testing.T) {
 	type args struct {
 		spec    *corev1.PodSpec
 		ordinal int
@@ -35,10 +38,10 @@ func TestPortModifierHandler_Mutate(t *testing.T) {
 			args: args{
 				spec:    &corev1.PodSpec{},
 				ordinal: 0,
-				cfg: &portConfig{
+				cfg: []*portConfig{{
 					qualifier: "1-2",
 					cfg:       nil,
-				},
+				}},
 			},
 			want:    &corev1.PodSpec{},
 			wantErr: false,
@@ -61,23 +64,23 @@ func TestPortModifierHandler_Mutate(t *testing.T) {
 					},
 				},
 				ordinal: 2,
-				cfg: &portConfig{
+				cfg: []*portConfig{{
 					qualifier: "",
 					cfg: &portConfigValue{
 						Containers: []containerPortsConfig{
 							{
 								Name: "web",
-								Ports: []corev1.ContainerPort{
-									{
+								Ports: []hostPortConfig{
+									{ContainerPort: corev1.ContainerPort{
 										Name:          "http",
 										ContainerPort: 8080,
 										HostPort:      30000,
-									},
+									}},
 								},
 							},
 						},
 					},
-				},
+				}},
 			},
 			want: &corev1.PodSpec{
 				Containers: []corev1.Container{
@@ -105,12 +108,168 @@ func TestPortModifierHandler_Mutate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "inject env vars with a custom prefix",
+			args: args{
+				spec: &corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web"}},
+				},
+				ordinal: 1,
+				cfg: []*portConfig{{
+					qualifier: "",
+					cfg: &portConfigValue{
+						PortEnvPrefix: "SVC_PORT_",
+						Containers: []containerPortsConfig{
+							{
+								Name: "web",
+								Ports: []hostPortConfig{
+									{ContainerPort: corev1.ContainerPort{
+										Name:          "http",
+										ContainerPort: 8080,
+										HostPort:      30000,
+									}},
+								},
+							},
+						},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "web",
+						Ports: []corev1.ContainerPort{
+							{
+								Name:          "http",
+								ContainerPort: 8080,
+								HostPort:      30001,
+							},
+						},
+						Env: []corev1.EnvVar{
+							{
+								Name:  "POD_ORDINAL",
+								Value: "1",
+							},
+							{
+								Name:  "SVC_PORT_http",
+								Value: "30001",
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "use the host port mapped to the ordinal",
+			args: args{
+				spec: &corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web"}},
+				},
+				ordinal: 2,
+				cfg: []*portConfig{{
+					qualifier: "",
+					cfg: &portConfigValue{
+						OrdinalPortMap: map[int]int32{0: 30000, 1: 30100, 2: 30250},
+						Containers: []containerPortsConfig{
+							{
+								Name: "web",
+								Ports: []hostPortConfig{
+									{ContainerPort: corev1.ContainerPort{
+										Name:          "http",
+										ContainerPort: 8080,
+										HostPort:      30000,
+									}},
+								},
+							},
+						},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "web",
+						Ports: []corev1.ContainerPort{
+							{
+								Name:          "http",
+								ContainerPort: 8080,
+								HostPort:      30250,
+							},
+						},
+						Env: []corev1.EnvVar{
+							{
+								Name:  "POD_ORDINAL",
+								Value: "2",
+							},
+							{
+								Name:  "PORT_http",
+								Value: "30250",
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "fall back to the offset for unmapped ordinals",
+			args: args{
+				spec: &corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web"}},
+				},
+				ordinal: 3,
+				cfg: []*portConfig{{
+					qualifier: "",
+					cfg: &portConfigValue{
+						OrdinalPortMap: map[int]int32{0: 30000, 1: 30100, 2: 30250},
+						Containers: []containerPortsConfig{
+							{
+								Name: "web",
+								Ports: []hostPortConfig{
+									{ContainerPort: corev1.ContainerPort{
+										Name:          "http",
+										ContainerPort: 8080,
+										HostPort:      30000,
+									}},
+								},
+							},
+						},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "web",
+						Ports: []corev1.ContainerPort{
+							{
+								Name:          "http",
+								ContainerPort: 8080,
+								HostPort:      30003,
+							},
+						},
+						Env: []corev1.EnvVar{
+							{
+								Name:  "POD_ORDINAL",
+								Value: "3",
+							},
+							{
+								Name:  "PORT_http",
+								Value: "30003",
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			h := &HostPortHandler{}
-			if err := h.Mutate(tt.args.spec, tt.args.ordinal, tt.args.cfg); (err != nil) != tt.wantErr {
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
 				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
 			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
 				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
@@ -119,6 +278,410 @@ func TestPortModifierHandler_Mutate(t *testing.T) {
 	}
 }
 
+func TestHostPortHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0-2", Name: HostPort}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+		{Qualifier: "3-5", Name: HostPort}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":31000}]}]}`,
+	}
+
+	h := &HostPortHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for ordinal, want := range map[int]int32{1: 30001, 4: 31004, 6: 0} {
+		spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: ordinal}, cfg); err != nil {
+			t.Fatalf("Mutate() ordinal %d error = %v", ordinal, err)
+		}
+
+		var got int32
+		if ports := spec.Containers[0].Ports; len(ports) > 0 {
+			if len(ports) != 1 {
+				t.Fatalf("Mutate() ordinal %d ports = %v, want a single port", ordinal, ports)
+			}
+			got = ports[0].HostPort
+		}
+		if got != want {
+			t.Errorf("Mutate() ordinal %d hostPort = %d, want %d", ordinal, got, want)
+		}
+	}
+}
+
+func TestHostPortHandler_Mutate_Idempotent(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       []corev1.ContainerPort
+	}{
+		{
+			name:       "named port",
+			annotation: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			want:       []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, HostPort: 30002}},
+		},
+		{
+			name:       "unnamed ports",
+			annotation: `{"containers":[{"name":"web","ports":[{"containerPort":8080,"hostPort":30000},{"containerPort":8081,"hostPort":31000}]}]}`,
+			want: []corev1.ContainerPort{
+				{ContainerPort: 8080, HostPort: 30002},
+				{ContainerPort: 8081, HostPort: 31002},
+			},
+		},
+		{
+			name:       "host port range",
+			annotation: `{"containers":[{"name":"web","ports":[{"name":"rtp","containerPort":5000,"hostPort":40000,"hostPortRangeSize":2}]}]}`,
+			want: []corev1.ContainerPort{
+				{Name: "rtp", ContainerPort: 5000, HostPort: 40004},
+				{ContainerPort: 5001, HostPort: 40005},
+			},
+		},
+		{
+			name:       "offset unnamed container port",
+			annotation: `{"containers":[{"name":"web","offsetContainerPort":true,"ports":[{"containerPort":8080,"hostPort":30000}]}]}`,
+			want:       []corev1.ContainerPort{{ContainerPort: 8082, HostPort: 30002}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &HostPortHandler{}
+			cfg, err := h.GetParser().Parse(map[annotation.QualifiedName]string{{Name: HostPort}: tt.annotation})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+			for i := 0; i < 2; i++ {
+				if err := h.Mutate(spec, annotation.MutationContext{Ordinal: 2}, cfg); err != nil {
+					t.Fatalf("Mutate() pass %d error = %v", i, err)
+				}
+			}
+
+			if got := spec.Containers[0].Ports; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Mutate() ports = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostPortHandler_Mutate_OrdinalPortMap(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: HostPort}: `{"ordinalPortMap":{"0":32000,"2":32500},"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+	}
+
+	h := &HostPortHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	// Mapped ordinals get their listed port, the others the computed one
+	for ordinal, want := range map[int]int32{0: 32000, 1: 30001, 2: 32500, 3: 30003} {
+		spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: ordinal}, cfg); err != nil {
+			t.Fatalf("Mutate() ordinal %d error = %v", ordinal, err)
+		}
+		if got := spec.Containers[0].Ports[0].HostPort; got != want {
+			t.Errorf("Mutate() ordinal %d hostPort = %d, want %d", ordinal, got, want)
+		}
+	}
+}
+
+func TestHostPortHandler_Mutate_HostPortRange(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: HostPort}: `{"containers":[{"name":"media","ports":[` +
+			`{"name":"sip","containerPort":5060,"hostPort":30000},` +
+			`{"name":"rtp","containerPort":20000,"hostPort":40000,"protocol":"UDP","hostPortRangeSize":10}]}]}`,
+	}
+
+	h := &HostPortHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "media"}}}
+	if err := h.Mutate(spec, annotation.MutationContext{Ordinal: 2}, cfg); err != nil {
+		t.Fatalf("Mutate() error = %v", err)
+	}
+
+	// Ordinal 2 reserves the third block of ten host ports
+	ports := spec.Containers[0].Ports
+	if len(ports) != 11 {
+		t.Fatalf("Mutate() ports = %v, want sip and ten rtp ports", ports)
+	}
+	if ports[0].HostPort != 30002 {
+		t.Errorf("sip hostPort = %d, want 30002", ports[0].HostPort)
+	}
+	for i, p := range ports[1:] {
+		want := corev1.ContainerPort{ContainerPort: 20000 + int32(i), HostPort: 40020 + int32(i), Protocol: corev1.ProtocolUDP}
+		if i == 0 {
+			want.Name = "rtp"
+		}
+		if !reflect.DeepEqual(p, want) {
+			t.Errorf("rtp port %d = %v, want %v", i, p, want)
+		}
+	}
+
+	env := map[string]string{}
+	for _, e := range spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	for name, want := range map[string]string{"PORT_rtp": "40020", "PORT_rtp_START": "40020", "PORT_rtp_END": "40029", "PORT_sip": "30002"} {
+		if env[name] != want {
+			t.Errorf("env %s = %q, want %q", name, env[name], want)
+		}
+	}
+	if _, ok := env["PORT_sip_START"]; ok {
+		t.Errorf("env PORT_sip_START set for a single host port")
+	}
+}
+
+func TestHostPortHandler_Mutate_HostPortRangeOverlap(t *testing.T) {
+	tests := []struct {
+		name    string
+		ports   string
+		wantErr bool
+	}{
+		{
+			name:    "single port within the range",
+			ports:   `{"name":"rtp","containerPort":20000,"hostPort":40000,"hostPortRangeSize":10},{"name":"rtcp","containerPort":21000,"hostPort":40015}`,
+			wantErr: true,
+		},
+		{
+			name:    "overlapping ranges",
+			ports:   `{"name":"rtp","containerPort":20000,"hostPort":40000,"hostPortRangeSize":10},{"name":"video","containerPort":22000,"hostPort":40005,"hostPortRangeSize":10}`,
+			wantErr: true,
+		},
+		{
+			name:    "other protocol",
+			ports:   `{"name":"rtp","containerPort":20000,"hostPort":40000,"hostPortRangeSize":10,"protocol":"UDP"},{"name":"rtcp","containerPort":21000,"hostPort":40015}`,
+			wantErr: false,
+		},
+		{
+			name:    "adjacent ranges",
+			ports:   `{"name":"rtp","containerPort":20000,"hostPort":40000,"hostPortRangeSize":10},{"name":"rtcp","containerPort":21000,"hostPort":40030}`,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := map[annotation.QualifiedName]string{
+				{Name: HostPort}: `{"containers":[{"name":"media","ports":[` + tt.ports + `]}]}`,
+			}
+			h := &HostPortHandler{}
+			cfg, err := h.GetParser().Parse(annotations)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			// Ordinal 1 reserves 40010-40019 for rtp, and 40015-40024 for video
+			spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "media"}}}
+			err = h.Mutate(spec, annotation.MutationContext{Ordinal: 1}, cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHostPortHandler_Mutate_OffsetContainerPort(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		spec       *corev1.PodSpec
+		wantPorts  []corev1.ContainerPort
+		wantEnv    map[string]string
+	}{
+		{
+			name:       "container and host port offset",
+			annotation: `{"containers":[{"name":"web","offsetContainerPort":true,"ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			spec:       &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			wantPorts:  []corev1.ContainerPort{{Name: "http", ContainerPort: 8083, HostPort: 30003}},
+			wantEnv:    map[string]string{"PORT_http": "30003", "CPORT_http": "8083"},
+		},
+		{
+			name:       "container port only, keeping the template's host port",
+			annotation: `{"containers":[{"name":"web","offsetContainerPort":true,"ports":[{"name":"http","containerPort":8080}]}]}`,
+			spec: &corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  "web",
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, HostPort: 8080}},
+			}}},
+			wantPorts: []corev1.ContainerPort{{Name: "http", ContainerPort: 8083, HostPort: 8080}},
+			wantEnv:   map[string]string{"CPORT_http": "8083"},
+		},
+		{
+			name:       "container port untouched by default",
+			annotation: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			spec:       &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			wantPorts:  []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, HostPort: 30003}},
+			wantEnv:    map[string]string{"PORT_http": "30003"},
+		},
+		{
+			name:       "offset range",
+			annotation: `{"containers":[{"name":"web","offsetContainerPort":true,"ports":[{"name":"rtp","containerPort":20000,"hostPort":40000,"hostPortRangeSize":2}]}]}`,
+			spec:       &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			wantPorts: []corev1.ContainerPort{
+				{Name: "rtp", ContainerPort: 20006, HostPort: 40006},
+				{ContainerPort: 20007, HostPort: 40007},
+			},
+			wantEnv: map[string]string{"PORT_rtp_START": "40006", "PORT_rtp_END": "40007", "CPORT_rtp": "20006"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &HostPortHandler{}
+			cfg, err := h.GetParser().Parse(map[annotation.QualifiedName]string{{Name: HostPort}: tt.annotation})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if err := h.Mutate(tt.spec, annotation.MutationContext{Ordinal: 3}, cfg); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+
+			container := tt.spec.Containers[0]
+			if !reflect.DeepEqual(container.Ports, tt.wantPorts) {
+				t.Errorf("Mutate() ports = %v, want %v", container.Ports, tt.wantPorts)
+			}
+			env := map[string]string{}
+			for _, e := range container.Env {
+				env[e.Name] = e.Value
+			}
+			for name, want := range tt.wantEnv {
+				if env[name] != want {
+					t.Errorf("env %s = %q, want %q", name, env[name], want)
+				}
+			}
+			if _, ok := tt.wantEnv["CPORT_http"]; !ok {
+				if _, set := env["CPORT_http"]; set {
+					t.Errorf("env CPORT_http set without offsetContainerPort")
+				}
+			}
+		})
+	}
+}
+
+func TestHostPortHandler_Mutate_Bounds(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: HostPort}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":65530}]}]}`,
+	}
+
+	h := &HostPortHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	// The highest valid port is still assigned
+	spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+	if err := h.Mutate(spec, annotation.MutationContext{Ordinal: 5}, cfg); err != nil {
+		t.Fatalf("Mutate() ordinal 5 error = %v", err)
+	}
+	if got := spec.Containers[0].Ports[0].HostPort; got != 65535 {
+		t.Errorf("Mutate() ordinal 5 hostPort = %d, want 65535", got)
+	}
+
+	// One past it fails, naming what the port was computed from
+	spec = &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+	err = h.Mutate(spec, annotation.MutationContext{Ordinal: 6}, cfg)
+	if err == nil {
+		t.Fatalf("Mutate() ordinal 6 error = nil, want an out of range error")
+	}
+	want := `container "web": port "http": hostPort 65530 + ordinal 6 = 65536 must be between 1 and 65535`
+	if err.Error() != want {
+		t.Errorf("Mutate() ordinal 6 error = %q, want %q", err, want)
+	}
+}
+
+func TestHostPortHandler_Mutate_BoundsOverflow(t *testing.T) {
+	// In int32, the offset of such an ordinal wraps around instead of exceeding
+	// the port range
+	tests := []struct {
+		name       string
+		annotation string
+	}{
+		{
+			name:       "host port",
+			annotation: `{"containers":[{"name":"web","ports":[{"name":"rtp","containerPort":5000,"hostPort":30000,"hostPortRangeSize":2}]}]}`,
+		},
+		{
+			name:       "offset container port",
+			annotation: `{"containers":[{"name":"web","offsetContainerPort":true,"ports":[{"name":"rtp","containerPort":5000}]}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &HostPortHandler{}
+			cfg, err := h.GetParser().Parse(map[annotation.QualifiedName]string{{Name: HostPort}: tt.annotation})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+			if err := h.Mutate(spec, annotation.MutationContext{Ordinal: math.MaxInt32}, cfg); err == nil {
+				t.Errorf("Mutate() error = nil, ports = %v, want an out of range error", spec.Containers[0].Ports)
+			}
+		})
+	}
+}
+
+func TestHostPortHandler_Mutate_InjectEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantEnv []corev1.EnvVar
+	}{
+		{
+			name:  "injected by default",
+			value: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			wantEnv: []corev1.EnvVar{
+				{Name: "MANAGED", Value: "ours"},
+				{Name: PodOrdinal, Value: "1"},
+				{Name: "PORT_http", Value: "30001"},
+			},
+		},
+		{
+			name:  "injected when enabled",
+			value: `{"injectEnv":true,"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			wantEnv: []corev1.EnvVar{
+				{Name: "MANAGED", Value: "ours"},
+				{Name: PodOrdinal, Value: "1"},
+				{Name: "PORT_http", Value: "30001"},
+			},
+		},
+		{
+			name:    "skipped when disabled",
+			value:   `{"injectEnv":false,"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			wantEnv: []corev1.EnvVar{{Name: "MANAGED", Value: "ours"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &HostPortHandler{}
+			cfg, err := h.GetParser().Parse(map[annotation.QualifiedName]string{{Name: HostPort}: tt.value})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			spec := &corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "web",
+				Env:  []corev1.EnvVar{{Name: "MANAGED", Value: "ours"}},
+			}}}
+			if err := h.Mutate(spec, annotation.MutationContext{Ordinal: 1}, cfg); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+
+			// The host port is offset either way
+			if got := spec.Containers[0].Ports[0].HostPort; got != 30001 {
+				t.Errorf("Mutate() hostPort = %d, want 30001", got)
+			}
+			if got := spec.Containers[0].Env; !reflect.DeepEqual(got, tt.wantEnv) {
+				t.Errorf("Mutate() env = %v, want %v", got, tt.wantEnv)
+			}
+		})
+	}
+}
+
 func Test_parserFunc_Parse(t *testing.T) {
 	type args struct {
 		annotations map[annotation.QualifiedName]string
@@ -146,23 +709,50 @@ func Test_parserFunc_Parse(t *testing.T) {
 					Name: HostPort,
 				}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
 			}},
-			want: &portConfig{
+			want: []*portConfig{{
 				qualifier: "",
 				cfg: &portConfigValue{
 					Containers: []containerPortsConfig{
 						{
 							Name: "web",
-							Ports: []corev1.ContainerPort{
-								{
+							Ports: []hostPortConfig{
+								{ContainerPort: corev1.ContainerPort{
 									Name:          "http",
 									ContainerPort: 8080,
 									HostPort:      30000,
-								},
+								}},
 							},
 						},
 					},
 				},
-			},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "valid yaml config",
+			p:    parser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: HostPort,
+				}: "containers:\n- name: web\n  ports:\n  - name: http\n    containerPort: 8080\n    hostPort: 30000\n",
+			}},
+			want: []*portConfig{{
+				qualifier: "",
+				cfg: &portConfigValue{
+					Containers: []containerPortsConfig{
+						{
+							Name: "web",
+							Ports: []hostPortConfig{
+								{ContainerPort: corev1.ContainerPort{
+									Name:          "http",
+									ContainerPort: 8080,
+									HostPort:      30000,
+								}},
+							},
+						},
+					},
+				},
+			}},
 			wantErr: false,
 		},
 		{
@@ -176,6 +766,119 @@ func Test_parserFunc_Parse(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "duplicate port name",
+			p:    parser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: HostPort,
+				}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000},{"name":"http","containerPort":8081,"hostPort":31000}]}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "same port name in different containers",
+			p:    parser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: HostPort,
+				}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]},{"name":"api","ports":[{"name":"http","containerPort":8080,"hostPort":31000}]}]}`,
+			}},
+			want: []*portConfig{{
+				cfg: &portConfigValue{
+					Containers: []containerPortsConfig{
+						{Name: "web", Ports: []hostPortConfig{{ContainerPort: corev1.ContainerPort{Name: "http", ContainerPort: 8080, HostPort: 30000}}}},
+						{Name: "api", Ports: []hostPortConfig{{ContainerPort: corev1.ContainerPort{Name: "http", ContainerPort: 8080, HostPort: 31000}}}},
+					},
+				},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "container port out of range",
+			p:    parser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: HostPort,
+				}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":70000,"hostPort":30000}]}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "missing container port",
+			p:    parser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: HostPort,
+				}: `{"containers":[{"name":"web","ports":[{"name":"http","hostPort":30000}]}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "negative host port",
+			p:    parser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: HostPort,
+				}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":-1}]}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "wildcard container",
+			p:    parser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: HostPort,
+				}: `{"containers":[{"name":"*","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "ordinal port map",
+			p:    parser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: HostPort,
+				}: `{"ordinalPortMap":{"1":30100},"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}},
+			want: []*portConfig{{
+				cfg: &portConfigValue{
+					OrdinalPortMap: map[int]int32{1: 30100},
+					Containers: []containerPortsConfig{
+						{Name: "web", Ports: []hostPortConfig{{ContainerPort: corev1.ContainerPort{Name: "http", ContainerPort: 8080, HostPort: 30000}}}},
+					},
+				},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "ordinal port map with several host ports",
+			p:    parser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: HostPort,
+				}: `{"ordinalPortMap":{"1":30100},"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000},{"name":"admin","containerPort":9090,"hostPort":31000}]}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "ordinal port map out of range",
+			p:    parser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: HostPort,
+				}: `{"ordinalPortMap":{"1":70000},"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -191,3 +894,74 @@ func Test_parserFunc_Parse(t *testing.T) {
 		})
 	}
 }
+
+func TestHostPortHandler_GetParser_Strict(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: HostPort}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000,"protocl":"UDP"}]}]}`,
+	}
+
+	// Lenient parsing ignores the misspelled field
+	got, err := (&HostPortHandler{}).GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("lenient Parse() error = %v", err)
+	}
+	if port := got.([]*portConfig)[0].cfg.Containers[0].Ports[0]; port.Protocol != "" || port.HostPort != 30000 {
+		t.Errorf("lenient Parse() port = %+v", port)
+	}
+
+	// Strict parsing names the annotation and the unknown field
+	_, err = (&HostPortHandler{Strict: true}).GetParser().Parse(annotations)
+	if err == nil {
+		t.Fatal("strict Parse() expected an error")
+	}
+	for _, want := range []string{"spoditor.io/host-port_0", `"protocl"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("strict Parse() error = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestHostPortHandler_GetParser_Deterministic(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0-2", Name: HostPort}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+		{Qualifier: "3-5", Name: HostPort}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":31000}]}]}`,
+	}
+
+	p := (&HostPortHandler{}).GetParser()
+	want, err := p.Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		got, err := p.Parse(annotations)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Parse() run %d got = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestHostPortHandler_GetParser_ParseError(t *testing.T) {
+	k := annotation.QualifiedName{Qualifier: "0-2", Name: HostPort}
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "malformed value", value: `{"containers":`},
+		{name: "invalid configuration", value: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":0}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&HostPortHandler{}).GetParser().Parse(map[annotation.QualifiedName]string{k: tt.value})
+			var perr *annotation.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("Parse() error = %v, want a *annotation.ParseError", err)
+			}
+			if perr.Name != k || perr.Value != tt.value || perr.Err == nil {
+				t.Errorf("Parse() error = %+v, want it to carry %v and its value", perr, k)
+			}
+		})
+	}
+}
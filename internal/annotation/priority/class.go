@@ -0,0 +1,122 @@
+package priority
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// PriorityClass is the annotation key for priority class configuration
+	PriorityClass = "priority-class"
+)
+
+// classSchema is the JSON Schema of the priority class annotation value
+//
+//go:embed class.schema.json
+var classSchema []byte
+
+// classConfig holds the priority class configuration with its pod qualifier
+type classConfig struct {
+	qualifier string            // Which pods this applies to
+	cfg       *classConfigValue // The actual priority class configuration
+}
+
+// classConfigValue represents the JSON structure of the priority class
+// configuration
+type classConfigValue struct {
+	PriorityClassName string `json:"priorityClassName"`
+	// Override replaces a priority class the pod already has, which is kept
+	// otherwise
+	Override bool `json:"override,omitempty"`
+}
+
+// Ensure PriorityClassHandler implements Handler interface
+var _ annotation.Handler = (*PriorityClassHandler)(nil)
+
+// PriorityClassHandler sets spec.priorityClassName per qualifier, e.g. to
+// schedule a database primary ahead of its replicas
+type PriorityClassHandler struct{}
+
+// Mutate sets the priority class of every configuration whose qualifier
+// matches the pod ordinal, in annotation key order
+func (h *PriorityClassHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*classConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*classConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+
+		if spec.PriorityClassName != "" && !c.cfg.Override {
+			l.Info("pod already has a priority class, keeping it", "priorityClassName", spec.PriorityClassName)
+			continue
+		}
+
+		l.Info("setting priority class", "priorityClassName", c.cfg.PriorityClassName)
+		spec.PriorityClassName = c.cfg.PriorityClassName
+	}
+
+	return nil
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *PriorityClassHandler) Name() string {
+	return PriorityClass
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *PriorityClassHandler) Schema() []byte {
+	return classSchema
+}
+
+// GetParser returns the parser for priority class annotations
+func (h *PriorityClassHandler) GetParser() annotation.Parser {
+	return classParser
+}
+
+// classParser parses every priority class annotation, whatever its qualifier,
+// into a classConfig, returning them in annotation key order
+var classParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*classConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != PriorityClass {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing priority class configuration")
+
+		value := &classConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse priority class configuration")
+			return nil, annotation.NewParseError(k, v, err)
+		}
+
+		if value.PriorityClassName == "" {
+			return nil, annotation.NewParseError(k, v, errors.New("no priorityClassName"))
+		}
+
+		configs = append(configs, &classConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
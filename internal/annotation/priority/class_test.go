@@ -0,0 +1,160 @@
+package priority
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPriorityClassHandler_Mutate(t *testing.T) {
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 1,
+				cfg: []*classConfig{{
+					qualifier: "0",
+					cfg:       &classConfigValue{PriorityClassName: "high-priority"},
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "keep an existing priority class",
+			args: args{
+				spec:    &corev1.PodSpec{PriorityClassName: "system"},
+				ordinal: 0,
+				cfg: []*classConfig{{
+					qualifier: "0",
+					cfg:       &classConfigValue{PriorityClassName: "high-priority"},
+				}},
+			},
+			want:    &corev1.PodSpec{PriorityClassName: "system"},
+			wantErr: false,
+		},
+		{
+			name: "override an existing priority class",
+			args: args{
+				spec:    &corev1.PodSpec{PriorityClassName: "system"},
+				ordinal: 0,
+				cfg: []*classConfig{{
+					qualifier: "0",
+					cfg:       &classConfigValue{PriorityClassName: "high-priority", Override: true},
+				}},
+			},
+			want:    &corev1.PodSpec{PriorityClassName: "high-priority"},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &PriorityClassHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriorityClassHandler_Mutate_LeaderAndFollowers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: PriorityClass}:  `{"priorityClassName":"high-priority"}`,
+		{Qualifier: "1-", Name: PriorityClass}: `{"priorityClassName":"normal"}`,
+	}
+
+	h := &PriorityClassHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for ordinal, want := range map[int]string{0: "high-priority", 1: "normal", 3: "normal"} {
+		spec := &corev1.PodSpec{}
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: ordinal}, cfg); err != nil {
+			t.Fatalf("Mutate() ordinal %d error = %v", ordinal, err)
+		}
+		if spec.PriorityClassName != want {
+			t.Errorf("Mutate() ordinal %d PriorityClassName = %q, want %q", ordinal, spec.PriorityClassName, want)
+		}
+	}
+}
+
+func Test_classParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       classParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    classParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "0", Name: PriorityClass}: `{"priorityClassName":"high-priority","override":true}`,
+			}},
+			want: []*classConfig{{
+				qualifier: "0",
+				cfg:       &classConfigValue{PriorityClassName: "high-priority", Override: true},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "no priority class name",
+			p:    classParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: PriorityClass}: `{"override":true}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package priority
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// PriorityTier is the annotation key for priority tier configuration
+	PriorityTier = "priority-tier"
+)
+
+var log = logf.Log.WithName("priority")
+
+// tierSchema is the JSON Schema of the priority tier annotation value
+//
+//go:embed tier.schema.json
+var tierSchema []byte
+
+// tierConfig holds the priority tier table with its pod qualifier
+type tierConfig struct {
+	qualifier string           // Which pods this applies to
+	cfg       *tierConfigValue // The actual tier table
+}
+
+// tierConfigValue represents the JSON structure of the priority tier
+// configuration
+type tierConfigValue struct {
+	Tiers []tier `json:"tiers"`
+}
+
+// tier maps the ordinals matching a qualifier to a PriorityClass
+type tier struct {
+	// Ordinals selects the pods of the tier, written like an annotation qualifier
+	Ordinals string `json:"ordinals"`
+	// PriorityClassName is the PriorityClass of the pods in the tier
+	PriorityClassName string `json:"priorityClassName"`
+}
+
+// priorityClassFor returns the PriorityClass of the first tier the pod falls
+// into
+func (c *tierConfigValue) priorityClassFor(mc annotation.MutationContext) (string, bool) {
+	for _, t := range c.Tiers {
+		if mc.Selects(t.Ordinals) {
+			return t.PriorityClassName, true
+		}
+	}
+	return "", false
+}
+
+// Ensure PriorityTierHandler implements Handler interface
+var _ annotation.Handler = (*PriorityTierHandler)(nil)
+
+// PriorityTierHandler sets spec.priorityClassName from a table of ordinal
+// tiers. spec.priority is left to the API server, which resolves it from the
+// class and rejects pods whose priority doesn't match their class.
+type PriorityTierHandler struct{}
+
+// Mutate sets the PriorityClass of the tier the pod ordinal falls into
+func (h *PriorityTierHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*tierConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*tierConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply sets the PriorityClass of the tier of a single configuration the
+// ordinal falls into
+func (c *tierConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	name, ok := c.cfg.priorityClassFor(mc)
+	if !ok {
+		l.Info("ordinal falls into no tier")
+		return
+	}
+
+	l.Info("setting priority class", "priorityClassName", name)
+	spec.PriorityClassName = name
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *PriorityTierHandler) Name() string {
+	return PriorityTier
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *PriorityTierHandler) Schema() []byte {
+	return tierSchema
+}
+
+// GetParser returns the parser for priority tier annotations
+func (h *PriorityTierHandler) GetParser() annotation.Parser {
+	return tierParser
+}
+
+// tierParser parses every priority tier annotation, whatever its qualifier, into a
+// tierConfig, returning them in annotation key order
+var tierParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*tierConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != PriorityTier {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing priority tier configuration")
+
+		value := &tierConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse priority tier configuration")
+			return nil, fmt.Errorf("invalid priority tier configuration: %w", err)
+		}
+
+		for _, t := range value.Tiers {
+			if t.PriorityClassName == "" {
+				return nil, fmt.Errorf("invalid priority tier configuration: tier %q has no priorityClassName", t.Ordinals)
+			}
+			if err := annotation.ValidateQualifier(t.Ordinals); err != nil {
+				return nil, fmt.Errorf("invalid priority tier configuration: %w", err)
+			}
+		}
+
+		if len(value.Tiers) == 0 {
+			logger.Info("configuration has no tiers, skipping")
+			continue
+		}
+
+		configs = append(configs, &tierConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,195 @@
+package priority
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPriorityTierHandler_Mutate(t *testing.T) {
+	tiers := &tierConfigValue{Tiers: []tier{
+		{Ordinals: "0-2", PriorityClassName: "high"},
+		{Ordinals: "3-", PriorityClassName: "normal"},
+	}}
+
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 5,
+				cfg:     []*tierConfig{{qualifier: "0-4", cfg: tiers}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "do nothing because ordinal falls into no tier",
+			args: args{
+				spec:    &corev1.PodSpec{PriorityClassName: "default"},
+				ordinal: 1,
+				cfg: []*tierConfig{{cfg: &tierConfigValue{Tiers: []tier{
+					{Ordinals: "3-", PriorityClassName: "normal"},
+				}}}},
+			},
+			want:    &corev1.PodSpec{PriorityClassName: "default"},
+			wantErr: false,
+		},
+		{
+			name: "replace priority class with the tier's",
+			args: args{
+				spec:    &corev1.PodSpec{PriorityClassName: "default"},
+				ordinal: 4,
+				cfg:     []*tierConfig{{cfg: tiers}},
+			},
+			want:    &corev1.PodSpec{PriorityClassName: "normal"},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &PriorityTierHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriorityTierHandler_Mutate_Tiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: PriorityTier}: `{"tiers":[{"ordinals":"0-2","priorityClassName":"high"},{"ordinals":"3-","priorityClassName":"normal"}]}`,
+	}
+
+	h := &PriorityTierHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for ordinal, want := range map[int]string{0: "high", 1: "high", 2: "high", 3: "normal", 10: "normal"} {
+		t.Run(fmt.Sprintf("ordinal %d", ordinal), func(t *testing.T) {
+			spec := &corev1.PodSpec{}
+			if err := h.Mutate(spec, annotation.MutationContext{Ordinal: ordinal}, cfg); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+			if spec.PriorityClassName != want {
+				t.Errorf("PriorityClassName = %q, want %q", spec.PriorityClassName, want)
+			}
+		})
+	}
+}
+
+func TestPriorityTierHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: PriorityTier}:  `{"tiers":[{"ordinals":"0-","priorityClassName":"critical"}]}`,
+		{Qualifier: "1-", Name: PriorityTier}: `{"tiers":[{"ordinals":"1-2","priorityClassName":"high"},{"ordinals":"3-","priorityClassName":"normal"}]}`,
+	}
+
+	for ordinal, want := range map[int]string{0: "critical", 1: "high", 3: "normal"} {
+		spec, err := annotationtest.ApplyOrdinal(&PriorityTierHandler{}, annotations, &corev1.PodSpec{}, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		if spec.PriorityClassName != want {
+			t.Errorf("ApplyOrdinal() ordinal %d PriorityClassName = %q, want %q", ordinal, spec.PriorityClassName, want)
+		}
+	}
+}
+
+func Test_tierParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       tierParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    tierParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: PriorityTier}: `{"tiers":[{"ordinals":"0-2","priorityClassName":"high"}]}`,
+			}},
+			want: []*tierConfig{{
+				cfg: &tierConfigValue{Tiers: []tier{{Ordinals: "0-2", PriorityClassName: "high"}}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "tier without a priority class",
+			p:    tierParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: PriorityTier}: `{"tiers":[{"ordinals":"0-2"}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "malformed tier ordinals",
+			p:    tierParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: PriorityTier}: `{"tiers":[{"ordinals":"0..2","priorityClassName":"high"}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid json",
+			p:    tierParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: PriorityTier}: `{"tiers":`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
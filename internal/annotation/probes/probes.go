@@ -0,0 +1,297 @@
+package probes
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/json"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// Probes is the annotation key for container probe configuration
+	Probes = "probes"
+	// priority orders the handler after the host-port handler, whose ports
+	// the {{port:name}} placeholders resolve to
+	priority = 10
+)
+
+var log = logf.Log.WithName("probes")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed probes.schema.json
+var schema []byte
+
+// probesConfig holds the probe configuration with its pod qualifier
+type probesConfig struct {
+	qualifier string             // Which pods this applies to
+	cfg       *probesConfigValue // The actual probe configuration
+}
+
+// probesConfigValue represents the JSON structure of the probe configuration
+type probesConfigValue struct {
+	Containers []containerProbesConfig `json:"containers"`
+}
+
+// containerProbesConfig defines the probes to set on a specific container
+type containerProbesConfig struct {
+	Name           string       `json:"name"`
+	LivenessProbe  *probeConfig `json:"livenessProbe,omitempty"`
+	ReadinessProbe *probeConfig `json:"readinessProbe,omitempty"`
+	// StartupProbe holds off the liveness probe until the container started,
+	// so slow-starting ordinals can get a generous startup budget alongside a
+	// tight liveness probe. Without a handler of its own it checks the same
+	// endpoint as the liveness probe.
+	StartupProbe *probeConfig `json:"startupProbe,omitempty"`
+}
+
+// probeConfig is a corev1.Probe whose timings may scale with the pod ordinal
+type probeConfig struct {
+	corev1.Probe
+	InitialDelaySeconds *annotation.OrdinalScale `json:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       *annotation.OrdinalScale `json:"periodSeconds,omitempty"`
+	SuccessThreshold    *annotation.OrdinalScale `json:"successThreshold,omitempty"`
+	FailureThreshold    *annotation.OrdinalScale `json:"failureThreshold,omitempty"`
+}
+
+// hasHandler reports whether the probe defines how to check the container
+func (p *probeConfig) hasHandler() bool {
+	return definesCheck(p.ProbeHandler)
+}
+
+// definesCheck reports whether the probe handler defines how to check the container
+func definesCheck(h corev1.ProbeHandler) bool {
+	return h.Exec != nil || h.HTTPGet != nil || h.TCPSocket != nil || h.GRPC != nil
+}
+
+// singleSuccess reports whether the probe, if any, succeeds after a single
+// success, as Kubernetes requires of liveness and startup probes
+func singleSuccess(p *probeConfig) bool {
+	if p == nil || p.SuccessThreshold == nil {
+		return true
+	}
+	return p.SuccessThreshold.Base == 1 && p.SuccessThreshold.Step == 0
+}
+
+// portPlaceholder matches a probe port referring to a named container port,
+// e.g. {{port:http}}
+var portPlaceholder = regexp.MustCompile(`^\{\{port:([^{}]+)\}\}$`)
+
+// build computes the probe for the given ordinal, resolving port placeholders
+// against the ports of the container
+func (p *probeConfig) build(ordinal int, container *corev1.Container, hostNetwork bool) (*corev1.Probe, error) {
+	probe := p.Probe.DeepCopy()
+	// Scaled below 0, the initial delay would be rejected
+	if p.InitialDelaySeconds != nil {
+		probe.InitialDelaySeconds = max(p.InitialDelaySeconds.Int32(ordinal), 0)
+	}
+	// Scaled below 1, the period and the thresholds would be rejected
+	if p.PeriodSeconds != nil {
+		probe.PeriodSeconds = max(p.PeriodSeconds.Int32(ordinal), 1)
+	}
+	if p.SuccessThreshold != nil {
+		probe.SuccessThreshold = max(p.SuccessThreshold.Int32(ordinal), 1)
+	}
+	if p.FailureThreshold != nil {
+		probe.FailureThreshold = max(p.FailureThreshold.Int32(ordinal), 1)
+	}
+
+	var err error
+	if probe.HTTPGet != nil {
+		if probe.HTTPGet.Port, err = resolvePort(probe.HTTPGet.Port, container, hostNetwork); err != nil {
+			return nil, err
+		}
+	}
+	if probe.TCPSocket != nil {
+		if probe.TCPSocket.Port, err = resolvePort(probe.TCPSocket.Port, container, hostNetwork); err != nil {
+			return nil, err
+		}
+	}
+	return probe, nil
+}
+
+// resolvePort replaces a {{port:name}} placeholder with the number of the named
+// container port, as computed by the handlers that ran before. Pods on the host
+// network are probed on the host port, others on the container port.
+func resolvePort(port intstr.IntOrString, container *corev1.Container, hostNetwork bool) (intstr.IntOrString, error) {
+	if port.Type != intstr.String {
+		return port, nil
+	}
+	m := portPlaceholder.FindStringSubmatch(port.StrVal)
+	if m == nil {
+		return port, nil
+	}
+
+	for _, p := range container.Ports {
+		if p.Name != m[1] {
+			continue
+		}
+		if hostNetwork && p.HostPort != 0 {
+			return intstr.FromInt32(p.HostPort), nil
+		}
+		return intstr.FromInt32(p.ContainerPort), nil
+	}
+	return port, fmt.Errorf("container %q has no port named %q", container.Name, m[1])
+}
+
+// Ensure ProbesHandler implements Handler and PrioritizedHandler interfaces
+var (
+	_ annotation.Handler            = (*ProbesHandler)(nil)
+	_ annotation.PrioritizedHandler = (*ProbesHandler)(nil)
+)
+
+// ProbesHandler sets container probes based on annotations
+type ProbesHandler struct{}
+
+// Mutate sets the configured probes on the matching containers. Its priority
+// runs it after the host-port handler, so {{port:name}} placeholders see the
+// computed ports.
+func (h *ProbesHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*probesConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*probesConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		if err := c.apply(spec, mc, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply sets the probes of a single configuration on the matching containers
+func (c *probesConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) error {
+	for _, source := range c.cfg.Containers {
+		for i := range spec.Containers {
+			container := &spec.Containers[i]
+			if container.Name != source.Name {
+				continue
+			}
+
+			if source.LivenessProbe != nil {
+				probe, err := source.LivenessProbe.build(mc.Ordinal, container, spec.HostNetwork)
+				if err != nil {
+					return fmt.Errorf("invalid liveness probe: %w", err)
+				}
+				container.LivenessProbe = probe
+				l.Info("setting liveness probe",
+					"container", container.Name,
+					"initialDelaySeconds", container.LivenessProbe.InitialDelaySeconds)
+			}
+
+			if source.ReadinessProbe != nil {
+				probe, err := source.ReadinessProbe.build(mc.Ordinal, container, spec.HostNetwork)
+				if err != nil {
+					return fmt.Errorf("invalid readiness probe: %w", err)
+				}
+				container.ReadinessProbe = probe
+				l.Info("setting readiness probe",
+					"container", container.Name,
+					"initialDelaySeconds", container.ReadinessProbe.InitialDelaySeconds,
+					"periodSeconds", container.ReadinessProbe.PeriodSeconds,
+					"successThreshold", container.ReadinessProbe.SuccessThreshold)
+			}
+
+			if source.StartupProbe != nil {
+				startup := source.StartupProbe
+				if !startup.hasHandler() && source.LivenessProbe != nil {
+					// Check the liveness endpoint until the container started
+					startup = &probeConfig{
+						Probe:               *startup.Probe.DeepCopy(),
+						InitialDelaySeconds: startup.InitialDelaySeconds,
+						PeriodSeconds:       startup.PeriodSeconds,
+						SuccessThreshold:    startup.SuccessThreshold,
+						FailureThreshold:    startup.FailureThreshold,
+					}
+					startup.ProbeHandler = *source.LivenessProbe.ProbeHandler.DeepCopy()
+				}
+				probe, err := startup.build(mc.Ordinal, container, spec.HostNetwork)
+				if err != nil {
+					return fmt.Errorf("invalid startup probe: %w", err)
+				}
+				container.StartupProbe = probe
+				l.Info("setting startup probe",
+					"container", container.Name,
+					"failureThreshold", container.StartupProbe.FailureThreshold)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *ProbesHandler) Name() string {
+	return Probes
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *ProbesHandler) Schema() []byte {
+	return schema
+}
+
+// Priority orders the handler after the host-port handler
+func (h *ProbesHandler) Priority() int {
+	return priority
+}
+
+// GetParser returns the parser for probe annotations
+func (h *ProbesHandler) GetParser() annotation.Parser {
+	return probesParser
+}
+
+// probesParser parses every probe annotation, whatever its qualifier, into a
+// probesConfig, returning them in annotation key order
+var probesParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*probesConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != Probes {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing probes configuration")
+
+		config := &probesConfigValue{}
+		if err := json.Unmarshal([]byte(v), config); err != nil {
+			logger.Error(err, "failed to parse probes configuration")
+			return nil, fmt.Errorf("invalid probes configuration: %w", err)
+		}
+
+		for _, c := range config.Containers {
+			if !singleSuccess(c.LivenessProbe) || !singleSuccess(c.StartupProbe) {
+				return nil, fmt.Errorf("invalid probes configuration: the success threshold of the liveness and startup probes of container %q must be 1", c.Name)
+			}
+			if c.StartupProbe != nil && !c.StartupProbe.hasHandler() && (c.LivenessProbe == nil || !c.LivenessProbe.hasHandler()) {
+				return nil, fmt.Errorf("invalid probes configuration: startup probe of container %q has no handler and no liveness probe to share one with", c.Name)
+			}
+		}
+
+		configs = append(configs, &probesConfig{
+			qualifier: k.Qualifier,
+			cfg:       config,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,479 @@
+package probes
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	"github.com/golem-base/spoditor/internal/annotation/ports"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestProbesHandler_Mutate(t *testing.T) {
+	httpGet := corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt32(8080)},
+	}
+	cfg := []*probesConfig{{
+		cfg: &probesConfigValue{
+			Containers: []containerProbesConfig{
+				{
+					Name: "web",
+					LivenessProbe: &probeConfig{
+						Probe:               corev1.Probe{ProbeHandler: httpGet, PeriodSeconds: 10},
+						InitialDelaySeconds: &annotation.OrdinalScale{Base: 10, Step: 5},
+					},
+					ReadinessProbe: &probeConfig{
+						Probe: corev1.Probe{ProbeHandler: httpGet, InitialDelaySeconds: 3},
+					},
+				},
+			},
+		},
+	}}
+
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+				ordinal: 0,
+				cfg:     []*probesConfig{{qualifier: "1-2", cfg: cfg[0].cfg}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			wantErr: false,
+		},
+		{
+			name: "set liveness and readiness probes",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+				ordinal: 2,
+				cfg:     cfg,
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{
+					Name: "web",
+					LivenessProbe: &corev1.Probe{
+						ProbeHandler:        httpGet,
+						InitialDelaySeconds: 20,
+						PeriodSeconds:       10,
+					},
+					ReadinessProbe: &corev1.Probe{ProbeHandler: httpGet, InitialDelaySeconds: 3},
+				},
+			}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ProbesHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbesHandler_Mutate_ScaledInitialDelay(t *testing.T) {
+	cfg := []*probesConfig{{
+		cfg: &probesConfigValue{
+			Containers: []containerProbesConfig{
+				{
+					Name: "web",
+					LivenessProbe: &probeConfig{
+						Probe: corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(8080)},
+						}},
+						InitialDelaySeconds: &annotation.OrdinalScale{Base: 10, Step: 5},
+					},
+				},
+			},
+		},
+	}}
+
+	tests := []struct {
+		ordinal int
+		want    int32
+	}{
+		{ordinal: 0, want: 10},
+		{ordinal: 1, want: 15},
+		{ordinal: 4, want: 30},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("ordinal %d", tt.ordinal), func(t *testing.T) {
+			spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}, {Name: "other"}}}
+			h := &ProbesHandler{}
+			if err := h.Mutate(spec, annotation.MutationContext{Ordinal: tt.ordinal}, cfg); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+			if got := spec.Containers[0].LivenessProbe.InitialDelaySeconds; got != tt.want {
+				t.Errorf("InitialDelaySeconds = %v, want %v", got, tt.want)
+			}
+			if spec.Containers[1].LivenessProbe != nil {
+				t.Errorf("unmatched container got a liveness probe")
+			}
+		})
+	}
+}
+
+func TestProbesHandler_Mutate_ScaledReadiness(t *testing.T) {
+	cfg := []*probesConfig{{
+		cfg: &probesConfigValue{
+			Containers: []containerProbesConfig{
+				{
+					Name: "web",
+					ReadinessProbe: &probeConfig{
+						Probe: corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(8080)},
+						}},
+						PeriodSeconds:    &annotation.OrdinalScale{Base: 5, Step: 5},
+						SuccessThreshold: &annotation.OrdinalScale{Base: 3, Step: -1},
+					},
+				},
+			},
+		},
+	}}
+
+	tests := []struct {
+		ordinal          int
+		periodSeconds    int32
+		successThreshold int32
+	}{
+		{ordinal: 0, periodSeconds: 5, successThreshold: 3},
+		{ordinal: 1, periodSeconds: 10, successThreshold: 2},
+		{ordinal: 2, periodSeconds: 15, successThreshold: 1},
+		{ordinal: 5, periodSeconds: 30, successThreshold: 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("ordinal %d", tt.ordinal), func(t *testing.T) {
+			spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+			h := &ProbesHandler{}
+			if err := h.Mutate(spec, annotation.MutationContext{Ordinal: tt.ordinal}, cfg); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+			probe := spec.Containers[0].ReadinessProbe
+			if probe.PeriodSeconds != tt.periodSeconds {
+				t.Errorf("PeriodSeconds = %v, want %v", probe.PeriodSeconds, tt.periodSeconds)
+			}
+			if probe.SuccessThreshold != tt.successThreshold {
+				t.Errorf("SuccessThreshold = %v, want %v", probe.SuccessThreshold, tt.successThreshold)
+			}
+		})
+	}
+}
+
+func TestProbesHandler_Mutate_ScaledBelowMinimum(t *testing.T) {
+	cfg := []*probesConfig{{
+		cfg: &probesConfigValue{
+			Containers: []containerProbesConfig{
+				{
+					Name: "web",
+					LivenessProbe: &probeConfig{
+						Probe: corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(8080)},
+						}},
+						InitialDelaySeconds: &annotation.OrdinalScale{Base: 10, Step: -5},
+						FailureThreshold:    &annotation.OrdinalScale{Base: 3, Step: -1},
+					},
+				},
+			},
+		},
+	}}
+
+	spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+	h := &ProbesHandler{}
+	if err := h.Mutate(spec, annotation.MutationContext{Ordinal: 5}, cfg); err != nil {
+		t.Fatalf("Mutate() error = %v", err)
+	}
+	probe := spec.Containers[0].LivenessProbe
+	if probe.InitialDelaySeconds != 0 {
+		t.Errorf("InitialDelaySeconds = %v, want 0", probe.InitialDelaySeconds)
+	}
+	if probe.FailureThreshold != 1 {
+		t.Errorf("FailureThreshold = %v, want 1", probe.FailureThreshold)
+	}
+}
+
+func TestProbesHandler_Mutate_PortPlaceholder(t *testing.T) {
+	cfg := []*probesConfig{{
+		cfg: &probesConfigValue{
+			Containers: []containerProbesConfig{
+				{
+					Name: "web",
+					LivenessProbe: &probeConfig{
+						Probe: corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("{{port:http}}")},
+						}},
+					},
+					ReadinessProbe: &probeConfig{
+						Probe: corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+							HTTPGet: &corev1.HTTPGetAction{Path: "/ready", Port: intstr.FromString("{{port:metrics}}")},
+						}},
+					},
+				},
+			},
+		},
+	}}
+	ports := []corev1.ContainerPort{
+		{Name: "http", ContainerPort: 8080, HostPort: 30002},
+		{Name: "metrics", ContainerPort: 9090},
+	}
+
+	tests := []struct {
+		name          string
+		hostNetwork   bool
+		ports         []corev1.ContainerPort
+		wantLiveness  intstr.IntOrString
+		wantReadiness intstr.IntOrString
+		wantErr       bool
+	}{
+		{
+			name:          "resolve to the named container ports",
+			ports:         ports,
+			wantLiveness:  intstr.FromInt32(8080),
+			wantReadiness: intstr.FromInt32(9090),
+		},
+		{
+			name:          "resolve to the host port on the host network",
+			hostNetwork:   true,
+			ports:         ports,
+			wantLiveness:  intstr.FromInt32(30002),
+			wantReadiness: intstr.FromInt32(9090),
+		},
+		{
+			name:    "fail on an unknown port name",
+			ports:   ports[:1],
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &corev1.PodSpec{
+				HostNetwork: tt.hostNetwork,
+				Containers:  []corev1.Container{{Name: "web", Ports: tt.ports}},
+			}
+			h := &ProbesHandler{}
+			err := h.Mutate(spec, annotation.MutationContext{Ordinal: 2}, cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := spec.Containers[0].LivenessProbe.TCPSocket.Port; got != tt.wantLiveness {
+				t.Errorf("liveness port = %v, want %v", got.String(), tt.wantLiveness.String())
+			}
+			if got := spec.Containers[0].ReadinessProbe.HTTPGet.Port; got != tt.wantReadiness {
+				t.Errorf("readiness port = %v, want %v", got.String(), tt.wantReadiness.String())
+			}
+		})
+	}
+
+	// The shared configuration keeps its placeholders for the next pod
+	if got := cfg[0].cfg.Containers[0].LivenessProbe.TCPSocket.Port.StrVal; got != "{{port:http}}" {
+		t.Errorf("Mutate() changed the configuration, port = %q", got)
+	}
+}
+
+func TestProbesHandler_AfterHostPort(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: ports.HostPort}: `{"containers":[{"name":"web","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+		{Name: Probes}:         `{"containers":[{"name":"web","livenessProbe":{"tcpSocket":{"port":"{{port:http}}"}}}]}`,
+	}
+
+	hh, ph := &ports.HostPortHandler{}, &ProbesHandler{}
+	if annotation.HandlerPriority(ph) <= annotation.HandlerPriority(hh) {
+		t.Fatalf("probes priority %d must order it after host-port", annotation.HandlerPriority(ph))
+	}
+
+	spec := &corev1.PodSpec{HostNetwork: true, Containers: []corev1.Container{{Name: "web"}}}
+	spec, err := annotationtest.ApplyOrdinal(hh, annotations, spec, 2)
+	if err != nil {
+		t.Fatalf("host-port: %v", err)
+	}
+	spec, err = annotationtest.ApplyOrdinal(ph, annotations, spec, 2)
+	if err != nil {
+		t.Fatalf("probes: %v", err)
+	}
+
+	if got, want := spec.Containers[0].LivenessProbe.TCPSocket.Port, intstr.FromInt32(30002); got != want {
+		t.Errorf("liveness port = %v, want %v", got.String(), want.String())
+	}
+}
+
+func TestProbesHandler_Mutate_StartupGatesLiveness(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: Probes}: `{"containers":[{"name":"web",` +
+			`"livenessProbe":{"httpGet":{"path":"/healthz","port":8080},"periodSeconds":5,"failureThreshold":2},` +
+			`"startupProbe":{"periodSeconds":10,"failureThreshold":{"base":30,"step":10,"max":90}}}]}`,
+	}
+
+	h := &ProbesHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	httpGet := corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt32(8080)},
+	}
+	tests := []struct {
+		ordinal          int
+		failureThreshold int32
+	}{
+		{ordinal: 0, failureThreshold: 30},
+		{ordinal: 2, failureThreshold: 50},
+		{ordinal: 9, failureThreshold: 90},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("ordinal %d", tt.ordinal), func(t *testing.T) {
+			spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+			if err := h.Mutate(spec, annotation.MutationContext{Ordinal: tt.ordinal}, cfg); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+
+			container := spec.Containers[0]
+			wantLiveness := &corev1.Probe{ProbeHandler: httpGet, PeriodSeconds: 5, FailureThreshold: 2}
+			if !reflect.DeepEqual(container.LivenessProbe, wantLiveness) {
+				t.Errorf("LivenessProbe = %v, want %v", container.LivenessProbe, wantLiveness)
+			}
+			// The startup probe checks the liveness endpoint with the scaled budget
+			wantStartup := &corev1.Probe{ProbeHandler: httpGet, PeriodSeconds: 10, FailureThreshold: tt.failureThreshold}
+			if !reflect.DeepEqual(container.StartupProbe, wantStartup) {
+				t.Errorf("StartupProbe = %v, want %v", container.StartupProbe, wantStartup)
+			}
+		})
+	}
+}
+
+func TestProbesHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: Probes}:  `{"containers":[{"name":"web","livenessProbe":{"tcpSocket":{"port":8080},"initialDelaySeconds":60}}]}`,
+		{Qualifier: "1-", Name: Probes}: `{"containers":[{"name":"web","livenessProbe":{"tcpSocket":{"port":8080},"initialDelaySeconds":{"base":10,"step":5}}}]}`,
+	}
+	pod := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+
+	for ordinal, want := range map[int]int32{0: 60, 1: 15, 3: 25} {
+		spec, err := annotationtest.ApplyOrdinal(&ProbesHandler{}, annotations, pod, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		if got := spec.Containers[0].LivenessProbe.InitialDelaySeconds; got != want {
+			t.Errorf("ApplyOrdinal() ordinal %d initialDelaySeconds = %d, want %d", ordinal, got, want)
+		}
+	}
+}
+
+func Test_probesParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       probesParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "scaled and constant initial delays",
+			p:    probesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Probes}: `{"containers":[{"name":"web",` +
+					`"livenessProbe":{"tcpSocket":{"port":8080},"initialDelaySeconds":{"base":10,"step":5}},` +
+					`"readinessProbe":{"tcpSocket":{"port":8080},"initialDelaySeconds":3}}]}`,
+			}},
+			want: []*probesConfig{{
+				cfg: &probesConfigValue{
+					Containers: []containerProbesConfig{
+						{
+							Name: "web",
+							LivenessProbe: &probeConfig{
+								Probe: corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+									TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(8080)},
+								}},
+								InitialDelaySeconds: &annotation.OrdinalScale{Base: 10, Step: 5},
+							},
+							ReadinessProbe: &probeConfig{
+								Probe: corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+									TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(8080)},
+								}},
+								InitialDelaySeconds: &annotation.OrdinalScale{Base: 3},
+							},
+						},
+					},
+				},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "startup probe without a handler to share",
+			p:    probesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Probes}: `{"containers":[{"name":"web","startupProbe":{"failureThreshold":30}}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "scaled success threshold of a liveness probe",
+			p:    probesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Probes}: `{"containers":[{"name":"web","livenessProbe":{"tcpSocket":{"port":8080},"successThreshold":{"base":1,"step":1}}}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid json",
+			p:    probesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Probes}: `{"containers":[`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package probes
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// StartupProbe is the annotation key for startup probe configuration
+	StartupProbe = "startup-probe"
+	// startupPriority orders the handler after the probes handler, whose
+	// startup probes it merges into
+	startupPriority = priority + 10
+)
+
+// startupSchema is the JSON Schema of the annotation value
+//
+//go:embed startup.schema.json
+var startupSchema []byte
+
+// startupProbeConfig holds the startup probe configuration with its pod qualifier
+type startupProbeConfig struct {
+	qualifier string                   // Which pods this applies to
+	cfg       *startupProbeConfigValue // The actual startup probe configuration
+}
+
+// startupProbeConfigValue represents the JSON structure of the startup probe
+// configuration
+type startupProbeConfigValue struct {
+	Containers []containerStartupProbeConfig `json:"containers"`
+}
+
+// containerStartupProbeConfig defines the startup probe fields to set on a
+// specific container
+type containerStartupProbeConfig struct {
+	Name         string       `json:"name"`
+	StartupProbe *probeConfig `json:"startupProbe"`
+}
+
+// merge sets the fields the probe configures on the existing probe, keeping
+// the others, and returns the result. Without an existing probe, the configured
+// one is returned as it is.
+func (p *probeConfig) merge(existing *corev1.Probe, ordinal int, container *corev1.Container, hostNetwork bool) (*corev1.Probe, error) {
+	probe, err := p.build(ordinal, container, hostNetwork)
+	if err != nil || existing == nil {
+		return probe, err
+	}
+
+	merged := existing.DeepCopy()
+	if p.hasHandler() {
+		merged.ProbeHandler = probe.ProbeHandler
+	}
+	if probe.InitialDelaySeconds != 0 {
+		merged.InitialDelaySeconds = probe.InitialDelaySeconds
+	}
+	if probe.TimeoutSeconds != 0 {
+		merged.TimeoutSeconds = probe.TimeoutSeconds
+	}
+	if probe.PeriodSeconds != 0 {
+		merged.PeriodSeconds = probe.PeriodSeconds
+	}
+	if probe.SuccessThreshold != 0 {
+		merged.SuccessThreshold = probe.SuccessThreshold
+	}
+	if probe.FailureThreshold != 0 {
+		merged.FailureThreshold = probe.FailureThreshold
+	}
+	if probe.TerminationGracePeriodSeconds != nil {
+		merged.TerminationGracePeriodSeconds = probe.TerminationGracePeriodSeconds
+	}
+	return merged, nil
+}
+
+// Ensure StartupProbeHandler implements Handler and PrioritizedHandler interfaces
+var (
+	_ annotation.Handler            = (*StartupProbeHandler)(nil)
+	_ annotation.PrioritizedHandler = (*StartupProbeHandler)(nil)
+)
+
+// StartupProbeHandler sets container startup probes per qualifier, so slow
+// starting ordinals, such as a leader, get a larger startup budget
+type StartupProbeHandler struct{}
+
+// Mutate merges the configured startup probes into the matching containers for
+// every configuration whose qualifier matches the pod ordinal, in annotation key
+// order, so a qualified annotation refines an unqualified one. Its priority runs
+// it after the probes handler, merging into the startup probes it set.
+func (h *StartupProbeHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*startupProbeConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*startupProbeConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		if err := c.apply(spec, mc.Ordinal, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply merges the startup probes of a single configuration
+func (c *startupProbeConfig) apply(spec *corev1.PodSpec, ordinal int, l logr.Logger) error {
+	for _, source := range c.cfg.Containers {
+		for i := range spec.Containers {
+			container := &spec.Containers[i]
+			if container.Name != source.Name {
+				continue
+			}
+
+			probe, err := source.StartupProbe.merge(container.StartupProbe, ordinal, container, spec.HostNetwork)
+			if err != nil {
+				return fmt.Errorf("invalid startup probe: %w", err)
+			}
+			if !definesCheck(probe.ProbeHandler) {
+				// Check the liveness endpoint until the container started
+				if container.LivenessProbe == nil || !definesCheck(container.LivenessProbe.ProbeHandler) {
+					return fmt.Errorf("startup probe of container %q has no handler and the container no liveness probe to share one with", container.Name)
+				}
+				probe.ProbeHandler = *container.LivenessProbe.ProbeHandler.DeepCopy()
+			}
+
+			container.StartupProbe = probe
+			l.Info("setting startup probe",
+				"container", container.Name,
+				"failureThreshold", probe.FailureThreshold,
+				"periodSeconds", probe.PeriodSeconds)
+		}
+	}
+	return nil
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *StartupProbeHandler) Name() string {
+	return StartupProbe
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *StartupProbeHandler) Schema() []byte {
+	return startupSchema
+}
+
+// Priority orders the handler after the probes handler
+func (h *StartupProbeHandler) Priority() int {
+	return startupPriority
+}
+
+// GetParser returns the parser for startup probe annotations
+func (h *StartupProbeHandler) GetParser() annotation.Parser {
+	return startupProbeParser
+}
+
+// startupProbeParser parses every startup probe annotation, whatever its
+// qualifier, into a startupProbeConfig, returning them in annotation key order
+var startupProbeParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*startupProbeConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != StartupProbe {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing startup probe configuration")
+
+		value := &startupProbeConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse startup probe configuration")
+			return nil, fmt.Errorf("invalid startup probe configuration: %w", err)
+		}
+
+		for _, c := range value.Containers {
+			if c.Name == "" {
+				return nil, fmt.Errorf("invalid startup probe configuration: container without a name")
+			}
+			if c.StartupProbe == nil {
+				return nil, fmt.Errorf("invalid startup probe configuration: container %q has no startup probe", c.Name)
+			}
+			if !singleSuccess(c.StartupProbe) {
+				return nil, fmt.Errorf("invalid startup probe configuration: the success threshold of the startup probe of container %q must be 1", c.Name)
+			}
+		}
+
+		configs = append(configs, &startupProbeConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,250 @@
+package probes
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestStartupProbeHandler_Mutate(t *testing.T) {
+	httpGet := corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/started", Port: intstr.FromInt32(8080)}}
+	tcpSocket := corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(8080)}}
+
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name:    "wrong config type",
+			args:    args{spec: nil, ordinal: 0, cfg: nil},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+				ordinal: 1,
+				cfg: []*startupProbeConfig{{
+					qualifier: "0",
+					cfg: &startupProbeConfigValue{Containers: []containerStartupProbeConfig{
+						{Name: "app", StartupProbe: &probeConfig{Probe: corev1.Probe{ProbeHandler: httpGet, FailureThreshold: 60}}},
+					}},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			wantErr: false,
+		},
+		{
+			name: "merge into the existing startup probe",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{{
+					Name:         "app",
+					StartupProbe: &corev1.Probe{ProbeHandler: httpGet, PeriodSeconds: 5, FailureThreshold: 10},
+				}}},
+				ordinal: 0,
+				cfg: []*startupProbeConfig{{
+					cfg: &startupProbeConfigValue{Containers: []containerStartupProbeConfig{
+						{Name: "app", StartupProbe: &probeConfig{Probe: corev1.Probe{FailureThreshold: 60}}},
+					}},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{{
+				Name:         "app",
+				StartupProbe: &corev1.Probe{ProbeHandler: httpGet, PeriodSeconds: 5, FailureThreshold: 60},
+			}}},
+			wantErr: false,
+		},
+		{
+			name: "share the liveness probe handler",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{{
+					Name:          "app",
+					LivenessProbe: &corev1.Probe{ProbeHandler: tcpSocket},
+				}}},
+				ordinal: 0,
+				cfg: []*startupProbeConfig{{
+					cfg: &startupProbeConfigValue{Containers: []containerStartupProbeConfig{
+						{Name: "app", StartupProbe: &probeConfig{Probe: corev1.Probe{PeriodSeconds: 10, FailureThreshold: 30}}},
+					}},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{{
+				Name:          "app",
+				LivenessProbe: &corev1.Probe{ProbeHandler: tcpSocket},
+				StartupProbe:  &corev1.Probe{ProbeHandler: tcpSocket, PeriodSeconds: 10, FailureThreshold: 30},
+			}}},
+			wantErr: false,
+		},
+		{
+			name: "no handler to check the container with",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+				ordinal: 0,
+				cfg: []*startupProbeConfig{{
+					cfg: &startupProbeConfigValue{Containers: []containerStartupProbeConfig{
+						{Name: "app", StartupProbe: &probeConfig{Probe: corev1.Probe{FailureThreshold: 30}}},
+					}},
+				}},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &StartupProbeHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() got = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartupProbeHandler_Mutate_LeaderThreshold(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: StartupProbe}: `{"containers":[{"name":"app","startupProbe":` +
+			`{"httpGet":{"path":"/started","port":8080},"periodSeconds":10,"failureThreshold":12}}]}`,
+		{Name: StartupProbe, Qualifier: "0"}: `{"containers":[{"name":"app","startupProbe":{"failureThreshold":60}}]}`,
+	}
+
+	tests := []struct {
+		ordinal int
+		want    int32
+	}{
+		{ordinal: 0, want: 60},
+		{ordinal: 1, want: 12},
+		{ordinal: 4, want: 12},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("ordinal %d", tt.ordinal), func(t *testing.T) {
+			spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}
+			got, err := annotationtest.ApplyOrdinal(&StartupProbeHandler{}, annotations, spec, tt.ordinal)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			probe := got.Containers[0].StartupProbe
+			if probe.FailureThreshold != tt.want {
+				t.Errorf("FailureThreshold = %v, want %v", probe.FailureThreshold, tt.want)
+			}
+			if probe.PeriodSeconds != 10 || probe.HTTPGet == nil {
+				t.Errorf("StartupProbe = %v, want the unqualified period and handler kept", probe)
+			}
+		})
+	}
+}
+
+func TestStartupProbeHandler_AfterProbes(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: Probes}: `{"containers":[{"name":"app","startupProbe":` +
+			`{"httpGet":{"path":"/started","port":8080},"failureThreshold":12}}]}`,
+		{Name: StartupProbe}: `{"containers":[{"name":"app","startupProbe":{"failureThreshold":60}}]}`,
+	}
+
+	ph, sh := &ProbesHandler{}, &StartupProbeHandler{}
+	if annotation.HandlerPriority(sh) <= annotation.HandlerPriority(ph) {
+		t.Fatalf("startup-probe priority %d must order it after probes", annotation.HandlerPriority(sh))
+	}
+
+	spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}
+	spec, err := annotationtest.ApplyOrdinal(ph, annotations, spec, 0)
+	if err != nil {
+		t.Fatalf("probes: %v", err)
+	}
+	spec, err = annotationtest.ApplyOrdinal(sh, annotations, spec, 0)
+	if err != nil {
+		t.Fatalf("startup-probe: %v", err)
+	}
+
+	probe := spec.Containers[0].StartupProbe
+	if probe.FailureThreshold != 60 || probe.HTTPGet == nil {
+		t.Errorf("StartupProbe = %v, want the probes handler's check with a failure threshold of 60", probe)
+	}
+}
+
+func Test_startupProbeParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       startupProbeParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "all qualifiers in key order",
+			p:    startupProbeParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: StartupProbe, Qualifier: "0"}: `{"containers":[{"name":"app","startupProbe":{"failureThreshold":60}}]}`,
+				{Name: StartupProbe}:                 `{"containers":[{"name":"app","startupProbe":{"failureThreshold":{"base":12}}}]}`,
+			}},
+			want: []*startupProbeConfig{
+				{cfg: &startupProbeConfigValue{Containers: []containerStartupProbeConfig{
+					{Name: "app", StartupProbe: &probeConfig{FailureThreshold: &annotation.OrdinalScale{Base: 12}}},
+				}}},
+				{qualifier: "0", cfg: &startupProbeConfigValue{Containers: []containerStartupProbeConfig{
+					{Name: "app", StartupProbe: &probeConfig{FailureThreshold: &annotation.OrdinalScale{Base: 60}}},
+				}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "container without a startup probe",
+			p:    startupProbeParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: StartupProbe}: `{"containers":[{"name":"app"}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "success threshold above 1",
+			p:    startupProbeParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: StartupProbe}: `{"containers":[{"name":"app","startupProbe":{"successThreshold":3}}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
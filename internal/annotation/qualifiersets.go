@@ -0,0 +1,103 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+const (
+	// QualifierSets is the StatefulSet annotation key defining named qualifiers,
+	// e.g. spoditor.io/qualifier-sets: {"canary":"0,2","bulk":"3-"}. Being an
+	// annotation value, a set may hold a CEL qualifier that isn't a valid
+	// annotation key suffix.
+	QualifierSets = "qualifier-sets"
+)
+
+// ParseQualifierSets parses the value of the qualifier sets annotation into a
+// map from set name to qualifier. Set names must not contain the separator
+// qualifiers are split off with, nor be qualifiers themselves, which would
+// change the meaning of annotations using that qualifier.
+func ParseQualifierSets(v, separator string) (map[string]string, error) {
+	var sets map[string]string
+	if err := json.Unmarshal([]byte(v), &sets); err != nil {
+		return nil, fmt.Errorf("invalid qualifier sets: %w", err)
+	}
+
+	for name, qualifier := range sets {
+		if name == "" || strings.Contains(name, separator) {
+			return nil, fmt.Errorf("invalid qualifier sets: invalid set name %q", name)
+		}
+		if ValidateQualifier(name) == nil {
+			return nil, fmt.Errorf("invalid qualifier sets: set name %q is a qualifier", name)
+		}
+		if strings.TrimSpace(qualifier) == "" {
+			return nil, fmt.Errorf("invalid qualifier sets: set %q has an empty qualifier", name)
+		}
+		if err := ValidateQualifier(qualifier); err != nil {
+			return nil, fmt.Errorf("invalid qualifier sets: set %q: %w", name, err)
+		}
+	}
+
+	return sets, nil
+}
+
+// ResolveQualifierSets replaces qualifiers that name a qualifier set with the
+// qualifier the set stands for. Other annotations are returned as they are.
+// Two annotations of a feature resolving to the same qualifier, e.g. through
+// sets standing for the same qualifier, can't both be kept and are an error.
+func ResolveQualifierSets(annotations map[QualifiedName]string, sets map[string]string) (map[QualifiedName]string, error) {
+	if len(sets) == 0 {
+		return annotations, nil
+	}
+
+	result := make(map[QualifiedName]string, len(annotations))
+	resolvedFrom := make(map[QualifiedName]QualifiedName, len(annotations))
+	for _, k := range SortedKeys(annotations) {
+		v := annotations[k]
+		resolved := k
+		if qualifier, ok := sets[k.Qualifier]; ok {
+			log.Info("resolved qualifier set", "name", k.Name, "set", k.Qualifier, "qualifier", qualifier)
+			resolved.Qualifier = qualifier
+		}
+		if other, ok := resolvedFrom[resolved]; ok {
+			return nil, fmt.Errorf("annotations %q and %q both resolve to qualifier %q",
+				KeyOf(Collector, other), KeyOf(Collector, k), resolved.Qualifier)
+		}
+		resolvedFrom[resolved] = k
+		result[resolved] = v
+	}
+
+	return result, nil
+}
+
+// ApplyDefaultQualifier gives unqualified annotations the default qualifier,
+// so they select the pods it selects rather than all of them. An annotation
+// qualified with the default qualifier explicitly takes precedence over an
+// unqualified one of the same name.
+func ApplyDefaultQualifier(annotations map[QualifiedName]string, qualifier string) map[QualifiedName]string {
+	if qualifier == "" {
+		return annotations
+	}
+
+	result := make(map[QualifiedName]string, len(annotations))
+	for k, v := range annotations {
+		if k.Qualifier != "" {
+			result[k] = v
+		}
+	}
+	for k, v := range annotations {
+		if k.Qualifier != "" {
+			continue
+		}
+		k.Qualifier = qualifier
+		if _, ok := result[k]; ok {
+			log.Info("explicitly qualified annotation overrides the unqualified one", "name", k.Name, "qualifier", qualifier)
+			continue
+		}
+		result[k] = v
+	}
+
+	return result
+}
@@ -0,0 +1,182 @@
+package annotation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQualifierSets(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		separator string
+		want      map[string]string
+		wantErr   bool
+	}{
+		{
+			name:  "valid sets",
+			value: `{"canary":"0,2","bulk":"3-"}`,
+			want:  map[string]string{"canary": "0,2", "bulk": "3-"},
+		},
+		{
+			name:    "invalid json",
+			value:   `{"canary":`,
+			wantErr: true,
+		},
+		{
+			name:    "set name containing the separator",
+			value:   `{"can_ary":"0"}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty qualifier",
+			value:   `{"canary":" "}`,
+			wantErr: true,
+		},
+		{
+			name:      "set name containing a configured separator",
+			value:     `{"can.ary":"0"}`,
+			separator: ".",
+			wantErr:   true,
+		},
+		{
+			name:      "set name containing the default separator with another one configured",
+			value:     `{"can_ary":"0"}`,
+			separator: ".",
+			want:      map[string]string{"can_ary": "0"},
+		},
+		{
+			name:    "set name that is an ordinal",
+			value:   `{"3":"0-5"}`,
+			wantErr: true,
+		},
+		{
+			name:    "set name that is a keyword",
+			value:   `{"even":"0-5"}`,
+			wantErr: true,
+		},
+		{
+			name:    "set name that is a range",
+			value:   `{"1-2":"0-5"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			separator := tt.separator
+			if separator == "" {
+				separator = Separator
+			}
+			got, err := ParseQualifierSets(tt.value, separator)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseQualifierSets() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQualifierSets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveQualifierSets(t *testing.T) {
+	sets := map[string]string{"canary": "0,2", "bulk": "3-"}
+	annotations := map[QualifiedName]string{
+		{Name: "host-port", Qualifier: "canary"}:  "a",
+		{Name: "mount-volume", Qualifier: "bulk"}: "b",
+		{Name: "tolerations", Qualifier: "1"}:     "c",
+		{Name: "env"}:                             "d",
+	}
+
+	got, err := ResolveQualifierSets(annotations, sets)
+	if err != nil {
+		t.Fatalf("ResolveQualifierSets() error = %v", err)
+	}
+
+	want := map[QualifiedName]string{
+		{Name: "host-port", Qualifier: "0,2"}:   "a",
+		{Name: "mount-volume", Qualifier: "3-"}: "b",
+		{Name: "tolerations", Qualifier: "1"}:   "c",
+		{Name: "env"}:                           "d",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ResolveQualifierSets() = %v, want %v", got, want)
+	}
+
+	// The resolved canary set matches ordinals 0 and 2 only
+	for ordinal, wantMatch := range map[int]bool{0: true, 1: false, 2: true, 3: false} {
+		if match := CommonPodQualifier(ordinal, "0,2"); match != wantMatch {
+			t.Errorf("CommonPodQualifier(%d) = %v, want %v", ordinal, match, wantMatch)
+		}
+	}
+}
+
+func TestResolveQualifierSets_Collision(t *testing.T) {
+	tests := []struct {
+		name        string
+		sets        map[string]string
+		annotations map[QualifiedName]string
+	}{
+		{
+			name: "set standing for an explicit qualifier",
+			sets: map[string]string{"first": "0"},
+			annotations: map[QualifiedName]string{
+				{Name: "mount-volume", Qualifier: "first"}: "a",
+				{Name: "mount-volume", Qualifier: "0"}:     "b",
+			},
+		},
+		{
+			name: "sets standing for the same qualifier",
+			sets: map[string]string{"canary": "0,2", "early": "0,2"},
+			annotations: map[QualifiedName]string{
+				{Name: "host-port", Qualifier: "canary"}: "a",
+				{Name: "host-port", Qualifier: "early"}:  "b",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map order must not decide the outcome, so resolve repeatedly
+			for i := 0; i < 10; i++ {
+				if _, err := ResolveQualifierSets(tt.annotations, tt.sets); err == nil {
+					t.Fatal("ResolveQualifierSets() error = nil, want a collision")
+				}
+			}
+		})
+	}
+
+	// Different features may share a qualifier
+	annotations := map[QualifiedName]string{
+		{Name: "host-port", Qualifier: "first"}: "a",
+		{Name: "mount-volume", Qualifier: "0"}:  "b",
+	}
+	if _, err := ResolveQualifierSets(annotations, map[string]string{"first": "0"}); err != nil {
+		t.Errorf("ResolveQualifierSets() error = %v", err)
+	}
+}
+
+func TestApplyDefaultQualifier(t *testing.T) {
+	annotations := map[QualifiedName]string{
+		{Name: "mount-volume"}:                 "a",
+		{Name: "host-port", Qualifier: "5"}:    "b",
+		{Name: "env"}:                          "c",
+		{Name: "env", Qualifier: "0-2"}:        "d",
+		{Name: "tolerations", Qualifier: "3-"}: "e",
+	}
+
+	got := ApplyDefaultQualifier(annotations, "0-2")
+
+	want := map[QualifiedName]string{
+		{Name: "mount-volume", Qualifier: "0-2"}: "a",
+		{Name: "host-port", Qualifier: "5"}:      "b",
+		{Name: "env", Qualifier: "0-2"}:          "d",
+		{Name: "tolerations", Qualifier: "3-"}:   "e",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ApplyDefaultQualifier() = %v, want %v", got, want)
+	}
+
+	if got := ApplyDefaultQualifier(annotations, ""); !reflect.DeepEqual(got, annotations) {
+		t.Errorf("ApplyDefaultQualifier() without a default = %v, want %v", got, annotations)
+	}
+}
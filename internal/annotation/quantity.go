@@ -0,0 +1,160 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// EvalQuantity evaluates an arithmetic expression over resource quantities for
+// the given ordinal, e.g. "500m + {{ordinal}} * 250m" yields 1 for ordinal 2.
+// Quantities can be added, subtracted and multiplied by plain integers, and
+// grouped with parentheses. The {{ordinal}} placeholder is replaced first.
+func EvalQuantity(expr string, ordinal int) (resource.Quantity, error) {
+	p := &quantityParser{tokens: tokenizeQuantity(SubstituteOrdinal(expr, ordinal))}
+	v, err := p.expr()
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("invalid quantity expression %q: %w", expr, err)
+	}
+	if p.pos < len(p.tokens) {
+		return resource.Quantity{}, fmt.Errorf("invalid quantity expression %q: unexpected %q", expr, p.tokens[p.pos])
+	}
+	return v.q, nil
+}
+
+// quantityValue is an intermediate result, remembering whether it is a plain
+// integer that can scale a quantity
+type quantityValue struct {
+	q      resource.Quantity
+	scalar bool
+}
+
+// quantityParser is a recursive descent parser over the tokens of a quantity
+// expression
+type quantityParser struct {
+	tokens []string
+	pos    int
+}
+
+// tokenizeQuantity splits an expression into operators, parentheses and literals
+func tokenizeQuantity(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("+-*()", c):
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			j := i
+			for j < len(s) && !unicode.IsSpace(rune(s[j])) && !strings.ContainsRune("+-*()", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+// peek returns the next token, or an empty string at the end
+func (p *quantityParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// expr parses terms joined by + and -
+func (p *quantityParser) expr() (quantityValue, error) {
+	v, err := p.term()
+	if err != nil {
+		return v, err
+	}
+	for op := p.peek(); op == "+" || op == "-"; op = p.peek() {
+		p.pos++
+		r, err := p.term()
+		if err != nil {
+			return v, err
+		}
+		if op == "+" {
+			v.q.Add(r.q)
+		} else {
+			v.q.Sub(r.q)
+		}
+		v.scalar = v.scalar && r.scalar
+	}
+	return v, nil
+}
+
+// term parses factors joined by *
+func (p *quantityParser) term() (quantityValue, error) {
+	v, err := p.factor()
+	if err != nil {
+		return v, err
+	}
+	for p.peek() == "*" {
+		p.pos++
+		r, err := p.factor()
+		if err != nil {
+			return v, err
+		}
+		switch {
+		case r.scalar:
+			v.q = scaleQuantity(v.q, r.q)
+		case v.scalar:
+			v = quantityValue{q: scaleQuantity(r.q, v.q)}
+		default:
+			return v, fmt.Errorf("cannot multiply %s by %s, one of them must be a plain integer", v.q.String(), r.q.String())
+		}
+	}
+	return v, nil
+}
+
+// factor parses a literal or a parenthesized expression
+func (p *quantityParser) factor() (quantityValue, error) {
+	tok := p.peek()
+	switch tok {
+	case "":
+		return quantityValue{}, fmt.Errorf("unexpected end of expression")
+	case "(":
+		p.pos++
+		v, err := p.expr()
+		if err != nil {
+			return v, err
+		}
+		if p.peek() != ")" {
+			return v, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return v, nil
+	case "+", "-", "*", ")":
+		return quantityValue{}, fmt.Errorf("unexpected %q", tok)
+	}
+
+	p.pos++
+	q, err := resource.ParseQuantity(tok)
+	if err != nil {
+		return quantityValue{}, fmt.Errorf("%q: %w", tok, err)
+	}
+	return quantityValue{q: q, scalar: isInteger(tok)}, nil
+}
+
+// isInteger reports whether a literal is a plain integer without a suffix
+func isInteger(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// scaleQuantity multiplies q by the integer value of n, keeping q's format
+func scaleQuantity(q, n resource.Quantity) resource.Quantity {
+	return *resource.NewMilliQuantity(q.MilliValue()*n.Value(), q.Format)
+}
@@ -0,0 +1,46 @@
+package annotation
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestEvalQuantity(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		ordinal int
+		want    string
+		wantErr bool
+	}{
+		{name: "plain quantity", expr: "512Mi", ordinal: 3, want: "512Mi"},
+		{name: "addition", expr: "500m + 250m", ordinal: 0, want: "750m"},
+		{name: "subtraction", expr: "1 - 250m", ordinal: 0, want: "750m"},
+		{name: "cpu scaled by ordinal 0", expr: "500m + {{ordinal}} * 250m", ordinal: 0, want: "500m"},
+		{name: "cpu scaled by ordinal 2", expr: "500m + {{ordinal}} * 250m", ordinal: 2, want: "1"},
+		{name: "cpu scaled by ordinal 3", expr: "500m + {{ordinal}} * 250m", ordinal: 3, want: "1250m"},
+		{name: "memory scaled by ordinal", expr: "1Gi + 512Mi * {{ordinal}}", ordinal: 2, want: "2Gi"},
+		{name: "parentheses", expr: "({{ordinal}} + 1) * 256Mi", ordinal: 3, want: "1Gi"},
+		{name: "integer arithmetic", expr: "2 * {{ordinal}} + 1", ordinal: 4, want: "9"},
+		{name: "multiplying two quantities", expr: "250m * 500m", wantErr: true},
+		{name: "invalid quantity", expr: "500x", wantErr: true},
+		{name: "dangling operator", expr: "500m +", wantErr: true},
+		{name: "unbalanced parenthesis", expr: "(500m + 250m", wantErr: true},
+		{name: "trailing token", expr: "500m 250m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EvalQuantity(tt.expr, tt.ordinal)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalQuantity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("EvalQuantity() = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package resourceclaims
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// ResourceClaims is the annotation key for DRA resource claim configuration
+	ResourceClaims = "resource-claims"
+)
+
+var log = logf.Log.WithName("resource_claims")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed resourceclaims.schema.json
+var schema []byte
+
+// resourceClaimsConfig holds the resource claims configuration with its pod qualifier
+type resourceClaimsConfig struct {
+	qualifier string                     // Which pods this applies to
+	cfg       *resourceClaimsConfigValue // The actual resource claims configuration
+}
+
+// resourceClaimsConfigValue represents the JSON structure of the resource
+// claims configuration
+type resourceClaimsConfigValue struct {
+	// Claims are added to spec.resourceClaims, with the {{ordinal}}
+	// placeholder replaced in resourceClaimName, e.g. gpu-{{ordinal}}
+	Claims []corev1.PodResourceClaim `json:"claims"`
+	// Containers reference the claims by name
+	Containers []containerClaimsConfig `json:"containers,omitempty"`
+}
+
+// containerClaimsConfig lists the claims a container uses
+type containerClaimsConfig struct {
+	Name   string                 `json:"name"`
+	Claims []corev1.ResourceClaim `json:"claims"`
+}
+
+// validate checks that claims are named uniquely and that containers only
+// reference configured claims
+func (c *resourceClaimsConfigValue) validate() error {
+	names := make([]string, 0, len(c.Claims))
+	for _, claim := range c.Claims {
+		if claim.Name == "" {
+			return fmt.Errorf("claim without a name")
+		}
+		if slices.Contains(names, claim.Name) {
+			return fmt.Errorf("duplicate claim %q", claim.Name)
+		}
+		if (claim.ResourceClaimName == nil) == (claim.ResourceClaimTemplateName == nil) {
+			return fmt.Errorf("claim %q needs exactly one of resourceClaimName and resourceClaimTemplateName", claim.Name)
+		}
+		names = append(names, claim.Name)
+	}
+	for _, container := range c.Containers {
+		for _, claim := range container.Claims {
+			if !slices.Contains(names, claim.Name) {
+				return fmt.Errorf("container %q references unknown claim %q", container.Name, claim.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Ensure ResourceClaimsHandler implements Handler interface
+var _ annotation.Handler = (*ResourceClaimsHandler)(nil)
+
+// ResourceClaimsHandler adds DRA resource claims, e.g. a GPU share per
+// ordinal, to the pod and wires them into its containers
+type ResourceClaimsHandler struct{}
+
+// Mutate adds the configured claims to spec.resourceClaims, resolving their
+// claim names for the pod ordinal, and references them from the containers.
+// Claims and references the pod already has are left alone.
+func (h *ResourceClaimsHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*resourceClaimsConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*resourceClaimsConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply adds the resource claims of a single configuration
+func (c *resourceClaimsConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	for _, source := range c.cfg.Claims {
+		if slices.ContainsFunc(spec.ResourceClaims, func(existing corev1.PodResourceClaim) bool { return existing.Name == source.Name }) {
+			l.Info("resource claim already present, skipping", "claim", source.Name)
+			continue
+		}
+
+		// Create a deep copy to avoid modifying the original
+		claim := source.DeepCopy()
+		if claim.ResourceClaimName != nil {
+			name := annotation.SubstituteOrdinal(*claim.ResourceClaimName, mc.Ordinal)
+			claim.ResourceClaimName = &name
+		}
+
+		l.Info("adding resource claim",
+			"claim", claim.Name,
+			"resourceClaimName", claim.ResourceClaimName,
+			"resourceClaimTemplateName", claim.ResourceClaimTemplateName)
+		spec.ResourceClaims = append(spec.ResourceClaims, *claim)
+	}
+
+	for _, containerConfig := range c.cfg.Containers {
+		for i := range spec.Containers {
+			container := &spec.Containers[i]
+			if container.Name != containerConfig.Name {
+				continue
+			}
+
+			for _, claim := range containerConfig.Claims {
+				if slices.Contains(container.Resources.Claims, claim) {
+					continue
+				}
+				l.Info("referencing resource claim", "container", container.Name, "claim", claim.Name)
+				container.Resources.Claims = append(container.Resources.Claims, claim)
+			}
+		}
+	}
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *ResourceClaimsHandler) Name() string {
+	return ResourceClaims
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *ResourceClaimsHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for resource claims annotations
+func (h *ResourceClaimsHandler) GetParser() annotation.Parser {
+	return resourceClaimsParser
+}
+
+// resourceClaimsParser parses every resource claims annotation, whatever its
+// qualifier, into a resourceClaimsConfig, returning them in annotation key order
+var resourceClaimsParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*resourceClaimsConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != ResourceClaims {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing resource claims configuration")
+
+		value := &resourceClaimsConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse resource claims configuration")
+			return nil, fmt.Errorf("invalid resource claims configuration: %w", err)
+		}
+		if len(value.Claims) == 0 {
+			return nil, fmt.Errorf("invalid resource claims configuration: no claims")
+		}
+		if err := value.validate(); err != nil {
+			return nil, fmt.Errorf("invalid resource claims configuration: %w", err)
+		}
+
+		configs = append(configs, &resourceClaimsConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
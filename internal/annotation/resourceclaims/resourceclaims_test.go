@@ -0,0 +1,221 @@
+package resourceclaims
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestResourceClaimsHandler_Mutate(t *testing.T) {
+	config := &resourceClaimsConfigValue{
+		Claims: []corev1.PodResourceClaim{
+			{Name: "gpu", ResourceClaimName: ptr.To("gpu-{{ordinal}}")},
+			{Name: "scratch", ResourceClaimTemplateName: ptr.To("scratch-template")},
+		},
+		Containers: []containerClaimsConfig{
+			{Name: "app", Claims: []corev1.ResourceClaim{{Name: "gpu"}, {Name: "scratch"}}},
+		},
+	}
+
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+				ordinal: 0,
+				cfg:     []*resourceClaimsConfig{{qualifier: "1-", cfg: config}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			wantErr: false,
+		},
+		{
+			name: "resolve the claims of the ordinal and reference them",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "other"}}},
+				ordinal: 3,
+				cfg:     []*resourceClaimsConfig{{cfg: config}},
+			},
+			want: &corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "app",
+						Resources: corev1.ResourceRequirements{
+							Claims: []corev1.ResourceClaim{{Name: "gpu"}, {Name: "scratch"}},
+						},
+					},
+					{Name: "other"},
+				},
+				ResourceClaims: []corev1.PodResourceClaim{
+					{Name: "gpu", ResourceClaimName: ptr.To("gpu-3")},
+					{Name: "scratch", ResourceClaimTemplateName: ptr.To("scratch-template")},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "keep claims and references the pod already has",
+			args: args{
+				spec: &corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name: "app",
+						Resources: corev1.ResourceRequirements{
+							Claims: []corev1.ResourceClaim{{Name: "gpu"}},
+						},
+					}},
+					ResourceClaims: []corev1.PodResourceClaim{
+						{Name: "gpu", ResourceClaimName: ptr.To("shared-gpu")},
+					},
+				},
+				ordinal: 1,
+				cfg:     []*resourceClaimsConfig{{cfg: config}},
+			},
+			want: &corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name: "app",
+					Resources: corev1.ResourceRequirements{
+						Claims: []corev1.ResourceClaim{{Name: "gpu"}, {Name: "scratch"}},
+					},
+				}},
+				ResourceClaims: []corev1.PodResourceClaim{
+					{Name: "gpu", ResourceClaimName: ptr.To("shared-gpu")},
+					{Name: "scratch", ResourceClaimTemplateName: ptr.To("scratch-template")},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ResourceClaimsHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+
+	// The configuration itself is never templated
+	if got := *config.Claims[0].ResourceClaimName; got != "gpu-{{ordinal}}" {
+		t.Errorf("Mutate() changed the configured claim name to %q", got)
+	}
+}
+
+func TestResourceClaimsHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: ResourceClaims}:  `{"claims":[{"name":"gpu","resourceClaimName":"gpu-leader"}]}`,
+		{Qualifier: "1-", Name: ResourceClaims}: `{"claims":[{"name":"gpu","resourceClaimName":"gpu-{{ordinal}}"}]}`,
+	}
+	pod := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}
+
+	for ordinal, want := range map[int]string{0: "gpu-leader", 3: "gpu-3"} {
+		spec, err := annotationtest.ApplyOrdinal(&ResourceClaimsHandler{}, annotations, pod, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		if got := []corev1.PodResourceClaim{{Name: "gpu", ResourceClaimName: ptr.To(want)}}; !reflect.DeepEqual(spec.ResourceClaims, got) {
+			t.Errorf("ApplyOrdinal() ordinal %d resourceClaims = %v, want %v", ordinal, spec.ResourceClaims, got)
+		}
+	}
+}
+
+func Test_resourceClaimsParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       resourceClaimsParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    resourceClaimsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "0-3", Name: ResourceClaims}: `{"claims":[{"name":"gpu","resourceClaimName":"gpu-{{ordinal}}"}],"containers":[{"name":"app","claims":[{"name":"gpu"}]}]}`,
+			}},
+			want: []*resourceClaimsConfig{{
+				qualifier: "0-3",
+				cfg: &resourceClaimsConfigValue{
+					Claims: []corev1.PodResourceClaim{{Name: "gpu", ResourceClaimName: ptr.To("gpu-{{ordinal}}")}},
+					Containers: []containerClaimsConfig{
+						{Name: "app", Claims: []corev1.ResourceClaim{{Name: "gpu"}}},
+					},
+				},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "container references an unknown claim",
+			p:    resourceClaimsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: ResourceClaims}: `{"claims":[{"name":"gpu","resourceClaimName":"gpu"}],"containers":[{"name":"app","claims":[{"name":"fpga"}]}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "claim with both sources",
+			p:    resourceClaimsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: ResourceClaims}: `{"claims":[{"name":"gpu","resourceClaimName":"gpu","resourceClaimTemplateName":"gpu"}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "duplicate claim",
+			p:    resourceClaimsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: ResourceClaims}: `{"claims":[{"name":"gpu","resourceClaimName":"a"},{"name":"gpu","resourceClaimName":"b"}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package resources
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// Resources is the annotation key for container resources configuration
+	Resources = "resources"
+)
+
+var log = logf.Log.WithName("resources")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed resources.schema.json
+var schema []byte
+
+// resourcesConfig holds the resources configuration with its pod qualifier
+type resourcesConfig struct {
+	qualifier string                // Which pods this applies to
+	cfg       *resourcesConfigValue // The actual resources configuration
+}
+
+// resourcesConfigValue represents the JSON structure of the resources configuration
+type resourcesConfigValue struct {
+	// Limits and Requests set container resources from quantity expressions,
+	// e.g. "500m + {{ordinal}} * 250m", evaluated for each pod's ordinal
+	Limits   map[corev1.ResourceName]string `json:"limits,omitempty"`
+	Requests map[corev1.ResourceName]string `json:"requests,omitempty"`
+	// Guaranteed copies each container's limits into its requests so the pod
+	// gets the Guaranteed QoS class
+	Guaranteed bool `json:"guaranteed,omitempty"`
+	// Containers names the containers to change, all of them when empty
+	Containers []string `json:"containers,omitempty"`
+}
+
+// Ensure ResourcesHandler implements Handler interface
+var _ annotation.Handler = (*ResourcesHandler)(nil)
+
+// ResourcesHandler adjusts container resources, e.g. to give latency-critical
+// ordinals Guaranteed QoS
+type ResourcesHandler struct{}
+
+// Mutate applies the resources configuration to the selected containers and
+// init containers
+func (h *ResourcesHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*resourcesConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*resourcesConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		if err := c.apply(spec, mc, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply sets the resources of a single configuration on the selected containers
+func (c *resourcesConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) error {
+	// Evaluate the expressions once for all containers
+	limits, err := evaluate(c.cfg.Limits, mc.Ordinal)
+	if err != nil {
+		return fmt.Errorf("invalid resources limits: %w", err)
+	}
+	requests, err := evaluate(c.cfg.Requests, mc.Ordinal)
+	if err != nil {
+		return fmt.Errorf("invalid resources requests: %w", err)
+	}
+
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			container := &containers[i]
+			if len(c.cfg.Containers) > 0 && !slices.Contains(c.cfg.Containers, container.Name) {
+				continue
+			}
+
+			setResources(&container.Resources.Limits, limits)
+			setResources(&container.Resources.Requests, requests)
+			if len(limits) > 0 || len(requests) > 0 {
+				l.Info("set resources", "container", container.Name, "limits", limits, "requests", requests)
+			}
+
+			if !c.cfg.Guaranteed {
+				continue
+			}
+
+			// Guaranteed QoS needs both cpu and memory limits, requests are
+			// defaulted from limits but must not differ from them
+			if container.Resources.Limits.Cpu().IsZero() || container.Resources.Limits.Memory().IsZero() {
+				l.Info("container lacks cpu or memory limits, pod won't get Guaranteed QoS", "container", container.Name)
+			}
+
+			if len(container.Resources.Limits) == 0 {
+				continue
+			}
+			if container.Resources.Requests == nil {
+				container.Resources.Requests = make(corev1.ResourceList, len(container.Resources.Limits))
+			}
+			for name, limit := range container.Resources.Limits {
+				container.Resources.Requests[name] = limit.DeepCopy()
+			}
+			l.Info("set requests to limits", "container", container.Name)
+		}
+	}
+
+	return nil
+}
+
+// evaluate computes the quantity expressions for the given ordinal
+func evaluate(exprs map[corev1.ResourceName]string, ordinal int) (corev1.ResourceList, error) {
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	list := make(corev1.ResourceList, len(exprs))
+	for name, expr := range exprs {
+		q, err := annotation.EvalQuantity(expr, ordinal)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		list[name] = q
+	}
+	return list, nil
+}
+
+// setResources sets the given quantities on the resource list, keeping the
+// resources it doesn't name
+func setResources(list *corev1.ResourceList, values corev1.ResourceList) {
+	if len(values) == 0 {
+		return
+	}
+	if *list == nil {
+		*list = make(corev1.ResourceList, len(values))
+	}
+	for name, q := range values {
+		(*list)[name] = q.DeepCopy()
+	}
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *ResourcesHandler) Name() string {
+	return Resources
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *ResourcesHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for resources annotations
+func (h *ResourcesHandler) GetParser() annotation.Parser {
+	return resourcesParser
+}
+
+// resourcesParser parses every resources annotation, whatever its qualifier, into
+// a resourcesConfig, returning them in annotation key order
+var resourcesParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*resourcesConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != Resources {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing resources configuration")
+
+		value := &resourcesConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse resources configuration")
+			return nil, fmt.Errorf("invalid resources configuration: %w", err)
+		}
+
+		if !value.Guaranteed && len(value.Limits) == 0 && len(value.Requests) == 0 {
+			return nil, fmt.Errorf("invalid resources configuration: no option enabled")
+		}
+
+		// Catch malformed expressions at parse time rather than on each pod
+		if _, err := evaluate(value.Limits, 0); err != nil {
+			return nil, fmt.Errorf("invalid resources configuration: limits: %w", err)
+		}
+		if _, err := evaluate(value.Requests, 0); err != nil {
+			return nil, fmt.Errorf("invalid resources configuration: requests: %w", err)
+		}
+
+		configs = append(configs, &resourcesConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
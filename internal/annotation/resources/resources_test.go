@@ -0,0 +1,315 @@
+package resources
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestResourcesHandler_Mutate(t *testing.T) {
+	limited := func(name string) corev1.Container {
+		return corev1.Container{
+			Name: name,
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2"),
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				},
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("500m"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+			},
+		}
+	}
+	guaranteed := func(name string) corev1.Container {
+		return corev1.Container{
+			Name: name,
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2"),
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				},
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2"),
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				},
+			},
+		}
+	}
+
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{limited("app")}},
+				ordinal: 3,
+				cfg: []*resourcesConfig{{
+					qualifier: "0-2",
+					cfg:       &resourcesConfigValue{Guaranteed: true},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{limited("app")}},
+			wantErr: false,
+		},
+		{
+			name: "copy limits into requests of all containers",
+			args: args{
+				spec: &corev1.PodSpec{
+					InitContainers: []corev1.Container{limited("init")},
+					Containers:     []corev1.Container{limited("app"), limited("sidecar")},
+				},
+				ordinal: 1,
+				cfg: []*resourcesConfig{{
+					qualifier: "0-2",
+					cfg:       &resourcesConfigValue{Guaranteed: true},
+				}},
+			},
+			want: &corev1.PodSpec{
+				InitContainers: []corev1.Container{guaranteed("init")},
+				Containers:     []corev1.Container{guaranteed("app"), guaranteed("sidecar")},
+			},
+			wantErr: false,
+		},
+		{
+			name: "copy limits into requests of named containers only",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{limited("app"), limited("sidecar")}},
+				ordinal: 0,
+				cfg: []*resourcesConfig{{
+					cfg: &resourcesConfigValue{Guaranteed: true, Containers: []string{"app"}},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{guaranteed("app"), limited("sidecar")}},
+			wantErr: false,
+		},
+		{
+			name: "set requests on containers without any",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "app",
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("2"),
+							corev1.ResourceMemory: resource.MustParse("4Gi"),
+						},
+					},
+				}}},
+				ordinal: 0,
+				cfg: []*resourcesConfig{{
+					cfg: &resourcesConfigValue{Guaranteed: true},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{guaranteed("app")}},
+			wantErr: false,
+		},
+		{
+			name: "leave containers without limits alone",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+				ordinal: 0,
+				cfg: []*resourcesConfig{{
+					cfg: &resourcesConfigValue{Guaranteed: true},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ResourcesHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourcesHandler_Mutate_Expressions(t *testing.T) {
+	cfg := []*resourcesConfig{{
+		cfg: &resourcesConfigValue{
+			Limits: map[corev1.ResourceName]string{
+				corev1.ResourceCPU:    "500m + {{ordinal}} * 250m",
+				corev1.ResourceMemory: "1Gi + {{ordinal}} * 512Mi",
+			},
+			Requests: map[corev1.ResourceName]string{
+				corev1.ResourceCPU: "250m",
+			},
+			Containers: []string{"app"},
+		},
+	}}
+
+	tests := []struct {
+		ordinal int
+		cpu     string
+		memory  string
+	}{
+		{ordinal: 0, cpu: "500m", memory: "1Gi"},
+		{ordinal: 2, cpu: "1", memory: "2Gi"},
+		{ordinal: 5, cpu: "1750m", memory: "3584Mi"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("ordinal %d", tt.ordinal), func(t *testing.T) {
+			spec := &corev1.PodSpec{Containers: []corev1.Container{
+				{
+					Name: "app",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+					},
+				},
+				{Name: "other"},
+			}}
+			h := &ResourcesHandler{}
+			if err := h.Mutate(spec, annotation.MutationContext{Ordinal: tt.ordinal}, cfg); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+
+			resources := spec.Containers[0].Resources
+			if got := resources.Limits.Cpu(); got.Cmp(resource.MustParse(tt.cpu)) != 0 {
+				t.Errorf("cpu limit = %s, want %s", got, tt.cpu)
+			}
+			if got := resources.Limits.Memory(); got.Cmp(resource.MustParse(tt.memory)) != 0 {
+				t.Errorf("memory limit = %s, want %s", got, tt.memory)
+			}
+			if got := resources.Requests.Cpu(); got.Cmp(resource.MustParse("250m")) != 0 {
+				t.Errorf("cpu request = %s, want 250m", got)
+			}
+			// Requests the annotation doesn't name are kept
+			if got := resources.Requests.Memory(); got.Cmp(resource.MustParse("512Mi")) != 0 {
+				t.Errorf("memory request = %s, want 512Mi", got)
+			}
+			if spec.Containers[1].Resources.Limits != nil {
+				t.Errorf("unnamed container got limits %v", spec.Containers[1].Resources.Limits)
+			}
+		})
+	}
+}
+
+func TestResourcesHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: Resources}:  `{"limits":{"cpu":"4","memory":"8Gi"}}`,
+		{Qualifier: "1-", Name: Resources}: `{"limits":{"cpu":"1","memory":"2Gi"}}`,
+	}
+	pod := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}
+
+	for ordinal, want := range map[int]string{0: "4", 1: "1", 3: "1"} {
+		spec, err := annotationtest.ApplyOrdinal(&ResourcesHandler{}, annotations, pod, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		if got := spec.Containers[0].Resources.Limits.Cpu(); got.Cmp(resource.MustParse(want)) != 0 {
+			t.Errorf("ApplyOrdinal() ordinal %d cpu limit = %v, want %s", ordinal, got, want)
+		}
+	}
+}
+
+func Test_resourcesParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       resourcesParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "guaranteed for named containers",
+			p:    resourcesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "0-2", Name: Resources}: `{"guaranteed":true,"containers":["app"]}`,
+			}},
+			want: []*resourcesConfig{{
+				qualifier: "0-2",
+				cfg:       &resourcesConfigValue{Guaranteed: true, Containers: []string{"app"}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "no option enabled",
+			p:    resourcesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Resources}: `{"guaranteed":false}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "limit expressions",
+			p:    resourcesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Resources}: `{"limits":{"cpu":"500m + {{ordinal}} * 250m"}}`,
+			}},
+			want: []*resourcesConfig{{
+				cfg: &resourcesConfigValue{Limits: map[corev1.ResourceName]string{corev1.ResourceCPU: "500m + {{ordinal}} * 250m"}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "malformed expression",
+			p:    resourcesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Resources}: `{"requests":{"memory":"1Gi * 512Mi"}}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid json",
+			p:    resourcesParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Resources}: `{"guaranteed":`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
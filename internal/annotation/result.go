@@ -0,0 +1,55 @@
+package annotation
+
+import (
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// Result describes what a handler's mutation did to a pod spec
+type Result struct {
+	Changed bool     // Whether the handler modified the pod spec
+	Skipped bool     // Whether the handler left the pod alone, e.g. excluded by its qualifier
+	Fields  []string // Pod spec fields the handler modified, by their JSON name
+}
+
+// ResultHandler is implemented by handlers reporting the result of their
+// mutation themselves rather than having it derived by MutateWithResult
+type ResultHandler interface {
+	Handler
+	MutateResult(spec *corev1.PodSpec, mc MutationContext, cfg any) (Result, error)
+}
+
+// MutateWithResult mutates the spec with the handler and reports what changed.
+// For handlers not implementing ResultHandler the result is derived by
+// comparing the spec before and after the mutation, so a handler that changed
+// nothing is reported as skipped.
+func MutateWithResult(h Handler, spec *corev1.PodSpec, mc MutationContext, cfg any) (Result, error) {
+	if rh, ok := h.(ResultHandler); ok {
+		return rh.MutateResult(spec, mc, cfg)
+	}
+
+	before := spec.DeepCopy()
+	if err := h.Mutate(spec, mc, cfg); err != nil {
+		return Result{}, err
+	}
+	fields := ChangedFields(before, spec)
+	return Result{Changed: len(fields) > 0, Skipped: len(fields) == 0, Fields: fields}, nil
+}
+
+// ChangedFields returns the JSON names of the top-level pod spec fields that
+// differ semantically between before and after, in declaration order
+func ChangedFields(before, after *corev1.PodSpec) []string {
+	b, a := reflect.ValueOf(before).Elem(), reflect.ValueOf(after).Elem()
+	var fields []string
+	for i := 0; i < b.NumField(); i++ {
+		if equality.Semantic.DeepEqual(b.Field(i).Interface(), a.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(b.Type().Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+	}
+	return fields
+}
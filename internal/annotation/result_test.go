@@ -0,0 +1,77 @@
+package annotation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// funcHandler is a handler mutating the spec with a function
+type funcHandler func(spec *corev1.PodSpec, mc MutationContext) error
+
+func (h funcHandler) Mutate(spec *corev1.PodSpec, mc MutationContext, _ any) error {
+	return h(spec, mc)
+}
+
+func (h funcHandler) GetParser() Parser {
+	return nil
+}
+
+// reportingHandler is a handler reporting its own result without mutating
+type reportingHandler struct {
+	funcHandler
+	result Result
+}
+
+func (h reportingHandler) MutateResult(*corev1.PodSpec, MutationContext, any) (Result, error) {
+	return h.result, nil
+}
+
+func TestMutateWithResult(t *testing.T) {
+	onlyOrdinal := func(ordinal int, mutate func(spec *corev1.PodSpec)) funcHandler {
+		return func(spec *corev1.PodSpec, mc MutationContext) error {
+			if mc.Ordinal == ordinal {
+				mutate(spec)
+			}
+			return nil
+		}
+	}
+	setHostname := onlyOrdinal(1, func(spec *corev1.PodSpec) { spec.Hostname = "web-1" })
+	addEnv := onlyOrdinal(1, func(spec *corev1.PodSpec) {
+		spec.Containers[0].Env = append(spec.Containers[0].Env, corev1.EnvVar{Name: "ORDINAL", Value: "1"})
+		spec.Volumes = append(spec.Volumes, corev1.Volume{Name: "data"})
+	})
+	reported := Result{Changed: true, Fields: []string{"volumes"}}
+
+	tests := []struct {
+		name    string
+		h       Handler
+		ordinal int
+		want    Result
+		wantErr bool
+	}{
+		{name: "changed field", h: setHostname, ordinal: 1, want: Result{Changed: true, Fields: []string{"hostname"}}},
+		{name: "skipped", h: setHostname, ordinal: 0, want: Result{Skipped: true}},
+		{name: "fields in declaration order", h: addEnv, ordinal: 1, want: Result{Changed: true, Fields: []string{"volumes", "containers"}}},
+		{name: "reported by the handler", h: reportingHandler{funcHandler: setHostname, result: reported}, ordinal: 1, want: reported},
+		{
+			name:    "error",
+			h:       funcHandler(func(*corev1.PodSpec, MutationContext) error { return errors.New("boom") }),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}
+			got, err := MutateWithResult(tt.h, spec, MutationContext{Ordinal: tt.ordinal}, struct{}{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MutateWithResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MutateWithResult() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
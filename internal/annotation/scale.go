@@ -0,0 +1,72 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// OrdinalScale is an integer configuration value that scales with the pod
+// ordinal as Base + ordinal*Step, clamped to Min and Max when set. In JSON it
+// is either a plain number, which is used for every ordinal, or an object:
+//
+//	{"base": 10, "step": 5, "max": 60}
+//
+// A negative base or step needs a min, so the value can't go negative
+// unnoticed for higher ordinals.
+type OrdinalScale struct {
+	Base int64  `json:"base"`
+	Step int64  `json:"step,omitempty"`
+	Min  *int64 `json:"min,omitempty"`
+	Max  *int64 `json:"max,omitempty"`
+}
+
+// Value computes the scaled value for the given ordinal
+func (s OrdinalScale) Value(ordinal int) int64 {
+	v := s.Base + int64(ordinal)*s.Step
+	if s.Min != nil && v < *s.Min {
+		v = *s.Min
+	}
+	if s.Max != nil && v > *s.Max {
+		v = *s.Max
+	}
+	return v
+}
+
+// Int32 computes the scaled value for the given ordinal as an int32,
+// saturating at the bounds of the int32 range rather than wrapping around
+func (s OrdinalScale) Int32(ordinal int) int32 {
+	return int32(min(max(s.Value(ordinal), math.MinInt32), math.MaxInt32))
+}
+
+// UnmarshalJSON accepts either a plain number or a scale object
+func (s *OrdinalScale) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] != '{' {
+		var base int64
+		if err := json.Unmarshal(trimmed, &base); err != nil {
+			return fmt.Errorf("ordinal scale must be a number or an object: %w", err)
+		}
+		if base < 0 {
+			return fmt.Errorf("ordinal scale %d must not be negative", base)
+		}
+		*s = OrdinalScale{Base: base}
+		return nil
+	}
+
+	// Decode through an alias type to avoid recursing into this method
+	type scale OrdinalScale
+	var v scale
+	if err := json.Unmarshal(trimmed, &v); err != nil {
+		return err
+	}
+	if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+		return fmt.Errorf("ordinal scale min %d is greater than max %d", *v.Min, *v.Max)
+	}
+	if v.Min == nil && (v.Base < 0 || v.Step < 0) {
+		return fmt.Errorf("ordinal scale with a negative base or step needs a min")
+	}
+	*s = OrdinalScale(v)
+	return nil
+}
@@ -0,0 +1,84 @@
+package annotation
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestOrdinalScale_Value(t *testing.T) {
+	ten, sixty := int64(10), int64(60)
+	tests := []struct {
+		name    string
+		s       OrdinalScale
+		ordinal int
+		want    int64
+	}{
+		{name: "constant", s: OrdinalScale{Base: 30}, ordinal: 7, want: 30},
+		{name: "ordinal 0 gets the base", s: OrdinalScale{Base: 10, Step: 5}, ordinal: 0, want: 10},
+		{name: "scaled up", s: OrdinalScale{Base: 10, Step: 5}, ordinal: 3, want: 25},
+		{name: "clamped to max", s: OrdinalScale{Base: 10, Step: 20, Max: &sixty}, ordinal: 5, want: 60},
+		{name: "scaled down to min", s: OrdinalScale{Base: 40, Step: -10, Min: &ten}, ordinal: 5, want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.Value(tt.ordinal); got != tt.want {
+				t.Errorf("Value() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrdinalScale_Int32(t *testing.T) {
+	floor := int64(math.MinInt64)
+	tests := []struct {
+		name    string
+		s       OrdinalScale
+		ordinal int
+		want    int32
+	}{
+		{name: "in range", s: OrdinalScale{Base: 10, Step: 5}, ordinal: 3, want: 25},
+		{name: "saturated at max", s: OrdinalScale{Base: math.MaxInt32, Step: 1}, ordinal: 1, want: math.MaxInt32},
+		{name: "saturated at min", s: OrdinalScale{Base: 0, Step: math.MinInt32, Min: &floor}, ordinal: 2, want: math.MinInt32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.Int32(tt.ordinal); got != tt.want {
+				t.Errorf("Int32() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrdinalScale_UnmarshalJSON(t *testing.T) {
+	sixty := int64(60)
+	tests := []struct {
+		name    string
+		data    string
+		want    OrdinalScale
+		wantErr bool
+	}{
+		{name: "plain number", data: `15`, want: OrdinalScale{Base: 15}},
+		{name: "object", data: `{"base":10,"step":5,"max":60}`, want: OrdinalScale{Base: 10, Step: 5, Max: &sixty}},
+		{name: "min above max", data: `{"base":10,"min":60,"max":10}`, wantErr: true},
+		{name: "not a number", data: `"ten"`, wantErr: true},
+		{name: "negative number", data: `-15`, wantErr: true},
+		{name: "negative step without min", data: `{"base":60,"step":-30}`, wantErr: true},
+		{name: "negative base without min", data: `{"base":-10,"step":5}`, wantErr: true},
+		{name: "negative step with min", data: `{"base":60,"step":-30,"min":60}`, want: OrdinalScale{Base: 60, Step: -30, Min: &sixty}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got OrdinalScale
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnmarshalJSON() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package securitycontext
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// SecurityContext is the annotation key for security context configuration
+	SecurityContext = "security-context"
+)
+
+var log = logf.Log.WithName("security_context")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed securitycontext.schema.json
+var schema []byte
+
+// securityContextConfig holds the security context configuration with its pod qualifier
+type securityContextConfig struct {
+	qualifier string                      // Which pods this applies to
+	cfg       *securityContextConfigValue // The actual security context configuration
+}
+
+// securityContextConfigValue represents the JSON structure of the security
+// context configuration
+type securityContextConfigValue struct {
+	Pod        *podSecurityContext              `json:"pod,omitempty"`
+	Containers []containerSecurityContextConfig `json:"containers,omitempty"`
+}
+
+// containerSecurityContextConfig defines the security context to merge into a
+// specific container
+type containerSecurityContextConfig struct {
+	Name            string                    `json:"name"`
+	SecurityContext *containerSecurityContext `json:"securityContext"`
+}
+
+// podSecurityContext is a corev1.PodSecurityContext whose user and group IDs
+// may scale with the pod ordinal, e.g. to run each shard as a distinct UID
+type podSecurityContext struct {
+	corev1.PodSecurityContext
+	RunAsUser  *annotation.OrdinalScale `json:"runAsUser,omitempty"`
+	RunAsGroup *annotation.OrdinalScale `json:"runAsGroup,omitempty"`
+	FSGroup    *annotation.OrdinalScale `json:"fsGroup,omitempty"`
+}
+
+// build computes the pod security context for the given ordinal
+func (c *podSecurityContext) build(ordinal int) *corev1.PodSecurityContext {
+	sc := c.PodSecurityContext.DeepCopy()
+	if c.RunAsUser != nil {
+		sc.RunAsUser = ptr.To(c.RunAsUser.Value(ordinal))
+	}
+	if c.RunAsGroup != nil {
+		sc.RunAsGroup = ptr.To(c.RunAsGroup.Value(ordinal))
+	}
+	if c.FSGroup != nil {
+		sc.FSGroup = ptr.To(c.FSGroup.Value(ordinal))
+	}
+	return sc
+}
+
+// containerSecurityContext is a corev1.SecurityContext whose user and group
+// IDs may scale with the pod ordinal
+type containerSecurityContext struct {
+	corev1.SecurityContext
+	RunAsUser  *annotation.OrdinalScale `json:"runAsUser,omitempty"`
+	RunAsGroup *annotation.OrdinalScale `json:"runAsGroup,omitempty"`
+}
+
+// build computes the container security context for the given ordinal
+func (c *containerSecurityContext) build(ordinal int) *corev1.SecurityContext {
+	sc := c.SecurityContext.DeepCopy()
+	if c.RunAsUser != nil {
+		sc.RunAsUser = ptr.To(c.RunAsUser.Value(ordinal))
+	}
+	if c.RunAsGroup != nil {
+		sc.RunAsGroup = ptr.To(c.RunAsGroup.Value(ordinal))
+	}
+	return sc
+}
+
+// Ensure SecurityContextHandler implements Handler interface
+var _ annotation.Handler = (*SecurityContextHandler)(nil)
+
+// SecurityContextHandler merges pod and container security contexts based on
+// annotations, e.g. to give the leader a different fsGroup than its followers
+type SecurityContextHandler struct{}
+
+// Mutate merges every configuration whose qualifier matches the pod ordinal
+// into the pod and container security contexts
+func (h *SecurityContextHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*securityContextConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*securityContextConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		if err := c.apply(spec, mc.Ordinal, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply merges a single configuration, computed for the ordinal, into the pod spec
+func (c *securityContextConfig) apply(spec *corev1.PodSpec, ordinal int, l logr.Logger) error {
+	if c.cfg.Pod != nil {
+		if spec.SecurityContext == nil {
+			spec.SecurityContext = &corev1.PodSecurityContext{}
+		}
+		if err := merge(spec.SecurityContext, c.cfg.Pod.build(ordinal)); err != nil {
+			return fmt.Errorf("failed to merge pod security context: %w", err)
+		}
+		l.Info("merged pod security context")
+	}
+
+	for _, source := range c.cfg.Containers {
+		for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+			for i := range containers {
+				container := &containers[i]
+				if container.Name != source.Name || source.SecurityContext == nil {
+					continue
+				}
+				if container.SecurityContext == nil {
+					container.SecurityContext = &corev1.SecurityContext{}
+				}
+				if err := merge(container.SecurityContext, source.SecurityContext.build(ordinal)); err != nil {
+					return fmt.Errorf("failed to merge security context of container %q: %w", container.Name, err)
+				}
+				l.Info("merged container security context", "container", container.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateProcMount checks the container security context asks for a proc
+// mount type the kubelet knows, e.g. Unmasked for sandboxed ordinals
+func validateProcMount(sc *corev1.SecurityContext) error {
+	if sc == nil || sc.ProcMount == nil {
+		return nil
+	}
+	switch *sc.ProcMount {
+	case corev1.DefaultProcMount, corev1.UnmaskedProcMount:
+		return nil
+	default:
+		return fmt.Errorf("procMount %q must be %q or %q", *sc.ProcMount, corev1.DefaultProcMount, corev1.UnmaskedProcMount)
+	}
+}
+
+// merge sets the fields set in overlay on dst, merging nested objects field by
+// field and keeping the fields overlay leaves unset
+func merge[T any](dst *T, overlay *T) error {
+	var base, over map[string]any
+	b, err := json.Marshal(dst)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &base); err != nil {
+		return err
+	}
+	o, err := json.Marshal(overlay)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(o, &over); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergeMaps(base, over))
+	if err != nil {
+		return err
+	}
+	var result T
+	if err := json.Unmarshal(merged, &result); err != nil {
+		return err
+	}
+	*dst = result
+	return nil
+}
+
+// mergeMaps merges over into base, recursing into objects present in both
+func mergeMaps(base, over map[string]any) map[string]any {
+	if base == nil {
+		base = make(map[string]any, len(over))
+	}
+	for k, v := range over {
+		if vm, ok := v.(map[string]any); ok {
+			if bm, ok := base[k].(map[string]any); ok {
+				base[k] = mergeMaps(bm, vm)
+				continue
+			}
+		}
+		base[k] = v
+	}
+	return base
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *SecurityContextHandler) Name() string {
+	return SecurityContext
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *SecurityContextHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for security context annotations
+func (h *SecurityContextHandler) GetParser() annotation.Parser {
+	return securityContextParser
+}
+
+// securityContextParser parses every security context annotation, whatever its
+// qualifier, into a securityContextConfig, returning them in annotation key order
+var securityContextParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*securityContextConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != SecurityContext {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing security context configuration")
+
+		value := &securityContextConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse security context configuration")
+			return nil, fmt.Errorf("invalid security context configuration: %w", err)
+		}
+
+		for _, c := range value.Containers {
+			if c.Name == "" {
+				return nil, fmt.Errorf("invalid security context configuration: container without a name")
+			}
+			if c.SecurityContext == nil {
+				continue
+			}
+			if err := validateProcMount(&c.SecurityContext.SecurityContext); err != nil {
+				return nil, fmt.Errorf("invalid security context configuration: container %q: %w", c.Name, err)
+			}
+		}
+
+		configs = append(configs, &securityContextConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
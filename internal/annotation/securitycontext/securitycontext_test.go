@@ -0,0 +1,334 @@
+package securitycontext
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestSecurityContextHandler_Mutate(t *testing.T) {
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+				ordinal: 1,
+				cfg: []*securityContextConfig{{
+					qualifier: "0",
+					cfg: &securityContextConfigValue{
+						Pod: &podSecurityContext{FSGroup: &annotation.OrdinalScale{Base: 2000}},
+					},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			wantErr: false,
+		},
+		{
+			name: "set pod fsGroup keeping other fields",
+			args: args{
+				spec: &corev1.PodSpec{
+					SecurityContext: &corev1.PodSecurityContext{
+						FSGroup:      ptr.To[int64](1000),
+						RunAsNonRoot: ptr.To(true),
+					},
+					Containers: []corev1.Container{{Name: "web"}},
+				},
+				ordinal: 0,
+				cfg: []*securityContextConfig{{
+					qualifier: "0",
+					cfg: &securityContextConfigValue{
+						Pod: &podSecurityContext{FSGroup: &annotation.OrdinalScale{Base: 2000}},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{
+				SecurityContext: &corev1.PodSecurityContext{
+					FSGroup:      ptr.To[int64](2000),
+					RunAsNonRoot: ptr.To(true),
+				},
+				Containers: []corev1.Container{{Name: "web"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "create missing pod security context",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+				ordinal: 0,
+				cfg: []*securityContextConfig{{
+					cfg: &securityContextConfigValue{
+						Pod: &podSecurityContext{FSGroup: &annotation.OrdinalScale{Base: 2000}},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{
+				SecurityContext: &corev1.PodSecurityContext{FSGroup: ptr.To[int64](2000)},
+				Containers:      []corev1.Container{{Name: "web"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "merge runAsUser into container security context",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{
+					{
+						Name: "web",
+						SecurityContext: &corev1.SecurityContext{
+							RunAsUser:              ptr.To[int64](1000),
+							ReadOnlyRootFilesystem: ptr.To(true),
+							Capabilities:           &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+						},
+					},
+					{Name: "other"},
+				}},
+				ordinal: 3,
+				cfg: []*securityContextConfig{{
+					qualifier: "1-",
+					cfg: &securityContextConfigValue{
+						Containers: []containerSecurityContextConfig{{
+							Name: "web",
+							SecurityContext: &containerSecurityContext{
+								SecurityContext: corev1.SecurityContext{
+									Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_BIND_SERVICE"}},
+								},
+								RunAsUser: &annotation.OrdinalScale{Base: 1003},
+							},
+						}},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{
+					Name: "web",
+					SecurityContext: &corev1.SecurityContext{
+						RunAsUser:              ptr.To[int64](1003),
+						ReadOnlyRootFilesystem: ptr.To(true),
+						Capabilities: &corev1.Capabilities{
+							Add:  []corev1.Capability{"NET_BIND_SERVICE"},
+							Drop: []corev1.Capability{"ALL"},
+						},
+					},
+				},
+				{Name: "other"},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "unmask proc of the sandboxed container",
+			args: args{
+				spec: &corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "sandbox", SecurityContext: &corev1.SecurityContext{ProcMount: ptr.To(corev1.DefaultProcMount)}},
+					{Name: "other"},
+				}},
+				ordinal: 2,
+				cfg: []*securityContextConfig{{
+					qualifier: "2",
+					cfg: &securityContextConfigValue{
+						Containers: []containerSecurityContextConfig{{
+							Name:            "sandbox",
+							SecurityContext: &containerSecurityContext{SecurityContext: corev1.SecurityContext{ProcMount: ptr.To(corev1.UnmaskedProcMount)}},
+						}},
+					},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "sandbox", SecurityContext: &corev1.SecurityContext{ProcMount: ptr.To(corev1.UnmaskedProcMount)}},
+				{Name: "other"},
+			}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &SecurityContextHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecurityContextHandler_Mutate_LeaderAndFollowers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: SecurityContext}:  `{"pod":{"fsGroup":2000}}`,
+		{Qualifier: "1-", Name: SecurityContext}: `{"pod":{"fsGroup":3000}}`,
+	}
+
+	h := &SecurityContextHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for ordinal, want := range map[int]int64{0: 2000, 1: 3000, 4: 3000} {
+		spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: ordinal}, cfg); err != nil {
+			t.Fatalf("Mutate() ordinal %d error = %v", ordinal, err)
+		}
+		if got := *spec.SecurityContext.FSGroup; got != want {
+			t.Errorf("Mutate() ordinal %d fsGroup = %d, want %d", ordinal, got, want)
+		}
+	}
+}
+
+func TestSecurityContextHandler_Mutate_OrdinalIDs(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: SecurityContext}: `{"pod":{"runAsUser":{"base":10000,"step":1},"runAsGroup":{"base":20000,"step":10},"fsGroup":{"base":20000,"step":10}},` +
+			`"containers":[{"name":"web","securityContext":{"runAsUser":{"base":30000,"step":100,"max":30200}}}]}`,
+	}
+
+	h := &SecurityContextHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		ordinal       int
+		wantUser      int64
+		wantGroup     int64
+		wantContainer int64
+	}{
+		{ordinal: 0, wantUser: 10000, wantGroup: 20000, wantContainer: 30000},
+		{ordinal: 2, wantUser: 10002, wantGroup: 20020, wantContainer: 30200},
+		{ordinal: 5, wantUser: 10005, wantGroup: 20050, wantContainer: 30200},
+	}
+	for _, tt := range tests {
+		spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: tt.ordinal}, cfg); err != nil {
+			t.Fatalf("Mutate() ordinal %d error = %v", tt.ordinal, err)
+		}
+
+		want := &corev1.PodSecurityContext{
+			RunAsUser:  ptr.To(tt.wantUser),
+			RunAsGroup: ptr.To(tt.wantGroup),
+			FSGroup:    ptr.To(tt.wantGroup),
+		}
+		if !reflect.DeepEqual(spec.SecurityContext, want) {
+			t.Errorf("Mutate() ordinal %d pod security context = %v, want %v", tt.ordinal, spec.SecurityContext, want)
+		}
+		if got := *spec.Containers[0].SecurityContext.RunAsUser; got != tt.wantContainer {
+			t.Errorf("Mutate() ordinal %d container runAsUser = %d, want %d", tt.ordinal, got, tt.wantContainer)
+		}
+	}
+}
+
+func Test_securityContextParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       securityContextParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    securityContextParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "0", Name: SecurityContext}: `{"pod":{"fsGroup":2000},"containers":[{"name":"web","securityContext":{"runAsUser":1000}}]}`,
+			}},
+			want: []*securityContextConfig{{
+				qualifier: "0",
+				cfg: &securityContextConfigValue{
+					Pod: &podSecurityContext{FSGroup: &annotation.OrdinalScale{Base: 2000}},
+					Containers: []containerSecurityContextConfig{{
+						Name:            "web",
+						SecurityContext: &containerSecurityContext{RunAsUser: &annotation.OrdinalScale{Base: 1000}},
+					}},
+				},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "container without a name",
+			p:    securityContextParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: SecurityContext}: `{"containers":[{"securityContext":{"runAsUser":1000}}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "unmasked proc mount",
+			p:    securityContextParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "1", Name: SecurityContext}: `{"containers":[{"name":"sandbox","securityContext":{"procMount":"Unmasked"}}]}`,
+			}},
+			want: []*securityContextConfig{{
+				qualifier: "1",
+				cfg: &securityContextConfigValue{
+					Containers: []containerSecurityContextConfig{{
+						Name:            "sandbox",
+						SecurityContext: &containerSecurityContext{SecurityContext: corev1.SecurityContext{ProcMount: ptr.To(corev1.UnmaskedProcMount)}},
+					}},
+				},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "unknown proc mount",
+			p:    securityContextParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: SecurityContext}: `{"containers":[{"name":"sandbox","securityContext":{"procMount":"Masked"}}]}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "invalid json",
+			p:    securityContextParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: SecurityContext}: `{"pod":`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package serviceaccount
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// ServiceAccount is the annotation key for service account configuration
+	ServiceAccount = "service-account"
+	// defaultServiceAccount is the account pods get when they name none
+	defaultServiceAccount = "default"
+)
+
+var log = logf.Log.WithName("service_account")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed serviceaccount.schema.json
+var schema []byte
+
+// serviceAccountConfig holds the service account configuration with its pod qualifier
+type serviceAccountConfig struct {
+	qualifier string                     // Which pods this applies to
+	cfg       *serviceAccountConfigValue // The actual service account configuration
+}
+
+// serviceAccountConfigValue represents the JSON structure of the service
+// account configuration
+type serviceAccountConfigValue struct {
+	ServiceAccountName string `json:"serviceAccountName"`
+	// OrdinalSuffix appends the pod ordinal to the name, e.g. worker-2
+	OrdinalSuffix bool `json:"ordinalSuffix,omitempty"`
+	// Override replaces a non-default account the pod already names, which
+	// is kept otherwise
+	Override bool `json:"override,omitempty"`
+}
+
+// Ensure ServiceAccountHandler implements Handler interface
+var _ annotation.Handler = (*ServiceAccountHandler)(nil)
+
+// ServiceAccountHandler sets spec.serviceAccountName per qualifier, e.g. so
+// ordinals assume different IAM-bound service accounts
+type ServiceAccountHandler struct{}
+
+// Mutate sets the service account of every configuration whose qualifier
+// matches the pod ordinal, in annotation key order
+func (h *ServiceAccountHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*serviceAccountConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*serviceAccountConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+
+		current := spec.ServiceAccountName
+		if current != "" && current != defaultServiceAccount && !c.cfg.Override {
+			l.Info("pod already names a service account, keeping it", "serviceAccountName", current)
+			continue
+		}
+
+		name := c.cfg.ServiceAccountName
+		if c.cfg.OrdinalSuffix {
+			name += "-" + strconv.Itoa(mc.Ordinal)
+		}
+
+		l.Info("setting service account", "serviceAccountName", name)
+		spec.ServiceAccountName = name
+		// Keep the deprecated alias in line when the pod set it
+		if spec.DeprecatedServiceAccount != "" {
+			spec.DeprecatedServiceAccount = name
+		}
+	}
+
+	return nil
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *ServiceAccountHandler) Name() string {
+	return ServiceAccount
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *ServiceAccountHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for service account annotations
+func (h *ServiceAccountHandler) GetParser() annotation.Parser {
+	return serviceAccountParser
+}
+
+// serviceAccountParser parses every service account annotation, whatever its
+// qualifier, into a serviceAccountConfig, returning them in annotation key order
+var serviceAccountParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*serviceAccountConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != ServiceAccount {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing service account configuration")
+
+		value := &serviceAccountConfigValue{}
+		if err := annotation.Unmarshal(v, value); err != nil {
+			logger.Error(err, "failed to parse service account configuration")
+			return nil, annotation.NewParseError(k, v, err)
+		}
+
+		if value.ServiceAccountName == "" {
+			return nil, annotation.NewParseError(k, v, errors.New("no serviceAccountName"))
+		}
+
+		configs = append(configs, &serviceAccountConfig{
+			qualifier: k.Qualifier,
+			cfg:       value,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
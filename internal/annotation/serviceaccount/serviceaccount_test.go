@@ -0,0 +1,161 @@
+package serviceaccount
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestServiceAccountHandler_Mutate(t *testing.T) {
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{ServiceAccountName: "default"},
+				ordinal: 0,
+				cfg: []*serviceAccountConfig{{
+					qualifier: "1-",
+					cfg:       &serviceAccountConfigValue{ServiceAccountName: "worker"},
+				}},
+			},
+			want:    &corev1.PodSpec{ServiceAccountName: "default"},
+			wantErr: false,
+		},
+		{
+			name: "replace the default account",
+			args: args{
+				spec:    &corev1.PodSpec{ServiceAccountName: "default"},
+				ordinal: 2,
+				cfg: []*serviceAccountConfig{{
+					qualifier: "1-",
+					cfg:       &serviceAccountConfigValue{ServiceAccountName: "worker"},
+				}},
+			},
+			want:    &corev1.PodSpec{ServiceAccountName: "worker"},
+			wantErr: false,
+		},
+		{
+			name: "suffix the account with the ordinal",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 2,
+				cfg: []*serviceAccountConfig{{
+					cfg: &serviceAccountConfigValue{ServiceAccountName: "worker", OrdinalSuffix: true},
+				}},
+			},
+			want:    &corev1.PodSpec{ServiceAccountName: "worker-2"},
+			wantErr: false,
+		},
+		{
+			name: "keep a non-default account",
+			args: args{
+				spec:    &corev1.PodSpec{ServiceAccountName: "custom"},
+				ordinal: 2,
+				cfg: []*serviceAccountConfig{{
+					cfg: &serviceAccountConfigValue{ServiceAccountName: "worker"},
+				}},
+			},
+			want:    &corev1.PodSpec{ServiceAccountName: "custom"},
+			wantErr: false,
+		},
+		{
+			name: "override a non-default account",
+			args: args{
+				spec:    &corev1.PodSpec{ServiceAccountName: "custom", DeprecatedServiceAccount: "custom"},
+				ordinal: 2,
+				cfg: []*serviceAccountConfig{{
+					cfg: &serviceAccountConfigValue{ServiceAccountName: "worker", OrdinalSuffix: true, Override: true},
+				}},
+			},
+			want:    &corev1.PodSpec{ServiceAccountName: "worker-2", DeprecatedServiceAccount: "worker-2"},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ServiceAccountHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func Test_serviceAccountParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       serviceAccountParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid configs in key order",
+			p:    serviceAccountParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "1-", Name: ServiceAccount}: `{"serviceAccountName":"worker","ordinalSuffix":true}`,
+				{Qualifier: "0", Name: ServiceAccount}:  `{"serviceAccountName":"leader"}`,
+			}},
+			want: []*serviceAccountConfig{
+				{qualifier: "0", cfg: &serviceAccountConfigValue{ServiceAccountName: "leader"}},
+				{qualifier: "1-", cfg: &serviceAccountConfigValue{ServiceAccountName: "worker", OrdinalSuffix: true}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "no service account name",
+			p:    serviceAccountParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: ServiceAccount}: `{"override":true}`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package sidecars
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// Sidecars is the annotation key for sidecar container injection configuration
+	Sidecars = "sidecars"
+)
+
+var log = logf.Log.WithName("sidecars")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed sidecars.schema.json
+var schema []byte
+
+// sidecarsConfig holds the sidecar containers with their pod qualifier
+type sidecarsConfig struct {
+	qualifier  string             // Which pods this applies to
+	containers []corev1.Container // Sidecar containers to be added to the pod
+}
+
+// Ensure SidecarsHandler implements Handler interface
+var _ annotation.Handler = (*SidecarsHandler)(nil)
+
+// SidecarsHandler appends sidecar containers to the pod spec based on annotations
+type SidecarsHandler struct{}
+
+// Mutate appends the configured sidecars whose names aren't taken yet,
+// templating the ordinal and StatefulSet name into their env values
+func (h *SidecarsHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*sidecarsConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*sidecarsConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply appends the sidecars of a single configuration
+func (c *sidecarsConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	for _, source := range c.containers {
+		if hasContainer(spec.Containers, source.Name) {
+			l.Info("container already present, skipping", "container", source.Name)
+			continue
+		}
+
+		// Create a deep copy to avoid modifying the original
+		sidecar := source.DeepCopy()
+		for i := range sidecar.Env {
+			value := annotation.SubstituteOrdinal(sidecar.Env[i].Value, mc.Ordinal)
+			sidecar.Env[i].Value = annotation.SubstituteSSName(value, mc.SSName)
+		}
+
+		l.Info("adding sidecar container", "container", sidecar.Name, "image", sidecar.Image)
+		spec.Containers = append(spec.Containers, *sidecar)
+	}
+}
+
+// hasContainer reports whether a container with the given name exists
+func hasContainer(containers []corev1.Container, name string) bool {
+	for _, c := range containers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *SidecarsHandler) Name() string {
+	return Sidecars
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *SidecarsHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for sidecar annotations
+func (h *SidecarsHandler) GetParser() annotation.Parser {
+	return sidecarsParser
+}
+
+// sidecarsParser parses every sidecar annotation, whatever its qualifier, into a
+// sidecarsConfig, returning them in annotation key order
+var sidecarsParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*sidecarsConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != Sidecars {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing sidecars configuration")
+
+		var containers []corev1.Container
+		if err := json.Unmarshal([]byte(v), &containers); err != nil {
+			logger.Error(err, "failed to parse sidecars configuration")
+			return nil, fmt.Errorf("invalid sidecars configuration: %w", err)
+		}
+
+		for _, c := range containers {
+			if c.Name == "" {
+				return nil, fmt.Errorf("invalid sidecars configuration: sidecar container without a name")
+			}
+		}
+
+		if len(containers) == 0 {
+			logger.Info("configuration has no sidecars, skipping")
+			continue
+		}
+
+		configs = append(configs, &sidecarsConfig{
+			qualifier:  k.Qualifier,
+			containers: containers,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
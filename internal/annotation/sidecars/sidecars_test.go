@@ -0,0 +1,254 @@
+package sidecars
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSidecarsHandler_Mutate(t *testing.T) {
+	logger := corev1.Container{
+		Name:  "logger",
+		Image: "fluent-bit",
+		Env: []corev1.EnvVar{
+			{Name: "SHARD", Value: "shard-{{ordinal}}"},
+			{Name: "LEVEL", Value: "info"},
+		},
+	}
+
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+				ordinal: 0,
+				cfg: []*sidecarsConfig{{
+					qualifier:  "1-2",
+					containers: []corev1.Container{logger},
+				}},
+			},
+			want:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			wantErr: false,
+		},
+		{
+			name: "append sidecar with templated env",
+			args: args{
+				spec:    &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+				ordinal: 2,
+				cfg: []*sidecarsConfig{{
+					containers: []corev1.Container{logger},
+				}},
+			},
+			want: &corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "web"},
+				{
+					Name:  "logger",
+					Image: "fluent-bit",
+					Env: []corev1.EnvVar{
+						{Name: "SHARD", Value: "shard-2"},
+						{Name: "LEVEL", Value: "info"},
+					},
+				},
+			}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &SidecarsHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestSidecarsHandler_Mutate_NotDuplicatedOnRerun(t *testing.T) {
+	cfg := []*sidecarsConfig{{
+		containers: []corev1.Container{{Name: "logger", Image: "fluent-bit"}},
+	}}
+	spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+
+	h := &SidecarsHandler{}
+	for i := 0; i < 2; i++ {
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: 1}, cfg); err != nil {
+			t.Fatalf("Mutate() error = %v", err)
+		}
+	}
+
+	want := &corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "web"},
+		{Name: "logger", Image: "fluent-bit"},
+	}}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("Mutate() = %v, want %v", spec, want)
+	}
+}
+
+func TestSidecarsHandler_Mutate_ProxyForHighOrdinals(t *testing.T) {
+	// High-ordinal pods route through a local proxy pointed at their upstream shard
+	cfg := []*sidecarsConfig{{
+		qualifier: "3-",
+		containers: []corev1.Container{{
+			Name:  "proxy",
+			Image: "envoyproxy/envoy",
+			Env: []corev1.EnvVar{
+				{Name: "UPSTREAM", Value: "{{ssName}}-shard-{{ordinal}}.{{ssName}}:9000"},
+				{Name: "LISTEN_PORT", Value: "15001"},
+			},
+		}},
+	}}
+
+	for _, tt := range []struct {
+		ordinal int
+		want    []corev1.Container
+	}{
+		{
+			ordinal: 0,
+			want:    []corev1.Container{{Name: "web"}},
+		},
+		{
+			ordinal: 2,
+			want:    []corev1.Container{{Name: "web"}},
+		},
+		{
+			ordinal: 3,
+			want: []corev1.Container{
+				{Name: "web"},
+				{
+					Name:  "proxy",
+					Image: "envoyproxy/envoy",
+					Env: []corev1.EnvVar{
+						{Name: "UPSTREAM", Value: "db-shard-3.db:9000"},
+						{Name: "LISTEN_PORT", Value: "15001"},
+					},
+				},
+			},
+		},
+		{
+			ordinal: 7,
+			want: []corev1.Container{
+				{Name: "web"},
+				{
+					Name:  "proxy",
+					Image: "envoyproxy/envoy",
+					Env: []corev1.EnvVar{
+						{Name: "UPSTREAM", Value: "db-shard-7.db:9000"},
+						{Name: "LISTEN_PORT", Value: "15001"},
+					},
+				},
+			},
+		},
+	} {
+		spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+		h := &SidecarsHandler{}
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: tt.ordinal, SSName: "db"}, cfg); err != nil {
+			t.Fatalf("Mutate() ordinal %d error = %v", tt.ordinal, err)
+		}
+		if !reflect.DeepEqual(spec.Containers, tt.want) {
+			t.Errorf("Mutate() ordinal %d = %v, want %v", tt.ordinal, spec.Containers, tt.want)
+		}
+	}
+
+	// The configuration is shared between pods and must not be templated in place
+	if got := cfg[0].containers[0].Env[0].Value; got != "{{ssName}}-shard-{{ordinal}}.{{ssName}}:9000" {
+		t.Errorf("Mutate() changed the configuration, env value = %q", got)
+	}
+}
+
+func TestSidecarsHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: Sidecars}:  `[{"name":"backup","image":"backup"}]`,
+		{Qualifier: "1-", Name: Sidecars}: `[{"name":"proxy","image":"envoyproxy/envoy"}]`,
+	}
+	pod := &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}}
+
+	for ordinal, want := range map[int]string{0: "backup", 3: "proxy"} {
+		spec, err := annotationtest.ApplyOrdinal(&SidecarsHandler{}, annotations, pod, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		if len(spec.Containers) != 2 || spec.Containers[1].Name != want {
+			t.Errorf("ApplyOrdinal() ordinal %d containers = %v, want web and %s", ordinal, spec.Containers, want)
+		}
+	}
+}
+
+func Test_sidecarsParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       sidecarsParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    sidecarsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "3-", Name: Sidecars}: `[{"name":"logger","image":"fluent-bit"}]`,
+			}},
+			want: []*sidecarsConfig{{
+				qualifier:  "3-",
+				containers: []corev1.Container{{Name: "logger", Image: "fluent-bit"}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "sidecar without a name",
+			p:    sidecarsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Sidecars}: `[{"image":"fluent-bit"}]`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package annotation
+
+// Suggest returns the candidate closest to name by edit distance, if it is
+// close enough to plausibly be what was meant
+func Suggest(name string, candidates []string) (string, bool) {
+	best, bestDistance := "", -1
+	for _, c := range candidates {
+		d := levenshtein(name, c)
+		if bestDistance == -1 || d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+
+	// Allow roughly one edit for every three characters, but at least two
+	threshold := len(name) / 3
+	if threshold < 2 {
+		threshold = 2
+	}
+
+	if bestDistance == -1 || bestDistance > threshold {
+		return "", false
+	}
+	return best, true
+}
+
+// levenshtein computes the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
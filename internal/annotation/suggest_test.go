@@ -0,0 +1,31 @@
+package annotation
+
+import "testing"
+
+func TestSuggest(t *testing.T) {
+	features := []string{"mount-volume", "host-port", "tolerations"}
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		wantOk bool
+	}{
+		{name: "transposed letters", input: "mount-volmue", want: "mount-volume", wantOk: true},
+		{name: "missing dash", input: "hostport", want: "host-port", wantOk: true},
+		{name: "extra letter", input: "toleration", want: "tolerations", wantOk: true},
+		{name: "nothing close", input: "sidecars", wantOk: false},
+		{name: "no candidates", input: "host-port", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := features
+			if tt.name == "no candidates" {
+				candidates = nil
+			}
+			got, ok := Suggest(tt.input, candidates)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Suggest() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
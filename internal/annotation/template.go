@@ -0,0 +1,23 @@
+package annotation
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// OrdinalPlaceholder is replaced with the pod ordinal in templated values
+	OrdinalPlaceholder = "{{ordinal}}"
+	// SSNamePlaceholder is replaced with the StatefulSet name in templated values
+	SSNamePlaceholder = "{{ssName}}"
+)
+
+// SubstituteOrdinal replaces every ordinal placeholder in s with the given ordinal
+func SubstituteOrdinal(s string, ordinal int) string {
+	return strings.ReplaceAll(s, OrdinalPlaceholder, strconv.Itoa(ordinal))
+}
+
+// SubstituteSSName replaces every StatefulSet name placeholder in s with the given name
+func SubstituteSSName(s string, ssName string) string {
+	return strings.ReplaceAll(s, SSNamePlaceholder, ssName)
+}
@@ -0,0 +1,185 @@
+package tolerations
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// Tolerations is the annotation key for toleration injection configuration
+	Tolerations = "tolerations"
+)
+
+var log = logf.Log.WithName("tolerations")
+
+// schema is the JSON Schema of the annotation value
+//
+//go:embed tolerations.schema.json
+var schema []byte
+
+// tolerationsConfig holds the tolerations to inject with their pod qualifier
+type tolerationsConfig struct {
+	qualifier   string             // Which pods this applies to
+	clear       bool               // Whether to remove the pod's tolerations first
+	tolerations []tolerationConfig // Tolerations to be added to the pod
+}
+
+// tolerationsConfigValue is the object form of the annotation value, which
+// can also clear the pod's tolerations, e.g. to isolate ordinals on untainted
+// nodes
+type tolerationsConfigValue struct {
+	Clear       bool               `json:"clear,omitempty"`
+	Tolerations []tolerationConfig `json:"tolerations,omitempty"`
+}
+
+// tolerationConfig is a corev1.Toleration whose tolerationSeconds may scale
+// with the pod ordinal, e.g. to stagger evictions
+type tolerationConfig struct {
+	corev1.Toleration
+	TolerationSeconds *annotation.OrdinalScale `json:"tolerationSeconds,omitempty"`
+}
+
+// build computes the toleration for the given ordinal
+func (t *tolerationConfig) build(ordinal int) *corev1.Toleration {
+	toleration := t.Toleration.DeepCopy()
+	if t.TolerationSeconds != nil {
+		seconds := t.TolerationSeconds.Value(ordinal)
+		toleration.TolerationSeconds = &seconds
+	}
+	return toleration
+}
+
+// Ensure TolerationsHandler implements Handler interface
+var _ annotation.Handler = (*TolerationsHandler)(nil)
+
+// TolerationsHandler appends tolerations to the pod spec based on annotations
+type TolerationsHandler struct{}
+
+// Mutate appends the configured tolerations that the pod doesn't already have,
+// after removing all of its tolerations if the configuration clears them
+func (h *TolerationsHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
+
+	// Type assertion for our config
+	configs, ok := cfg.([]*tolerationsConfig)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T, expected []*tolerationsConfig", cfg)
+	}
+
+	for _, c := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(c.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", c.qualifier)
+			continue
+		}
+		c.apply(spec, mc, l)
+	}
+
+	return nil
+}
+
+// apply adds the tolerations of a single configuration, after clearing those
+// of the pod if it asks to
+func (c *tolerationsConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
+	if c.clear {
+		l.Info("clearing tolerations", "count", len(spec.Tolerations))
+		spec.Tolerations = nil
+	}
+
+	for i := range c.tolerations {
+		t := c.tolerations[i].build(mc.Ordinal)
+		if hasToleration(spec.Tolerations, *t) {
+			l.Info("toleration already present, skipping",
+				"key", t.Key,
+				"operator", t.Operator,
+				"value", t.Value,
+				"effect", t.Effect)
+			continue
+		}
+
+		l.Info("adding toleration",
+			"key", t.Key,
+			"operator", t.Operator,
+			"value", t.Value,
+			"effect", t.Effect,
+			"tolerationSeconds", t.TolerationSeconds)
+		spec.Tolerations = append(spec.Tolerations, *t)
+	}
+}
+
+// hasToleration reports whether a toleration with the same key, operator, value and effect exists
+func hasToleration(tolerations []corev1.Toleration, t corev1.Toleration) bool {
+	for _, existing := range tolerations {
+		if existing.Key == t.Key &&
+			existing.Operator == t.Operator &&
+			existing.Value == t.Value &&
+			existing.Effect == t.Effect {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *TolerationsHandler) Name() string {
+	return Tolerations
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *TolerationsHandler) Schema() []byte {
+	return schema
+}
+
+// GetParser returns the parser for toleration annotations
+func (h *TolerationsHandler) GetParser() annotation.Parser {
+	return tolerationsParser
+}
+
+// tolerationsParser parses every toleration annotation, whatever its qualifier,
+// into a tolerationsConfig, returning them in annotation key order
+var tolerationsParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
+	var configs []*tolerationsConfig
+	for _, k := range annotation.SortedKeys(annotations) {
+		if k.Name != Tolerations {
+			continue
+		}
+		v := annotations[k]
+
+		logger := log.WithValues("qualifiedName", k, "value", v)
+		logger.Info("parsing tolerations configuration")
+
+		// The value is either an array of tolerations or the object form
+		value := &tolerationsConfigValue{}
+		target := any(&value.Tolerations)
+		if strings.HasPrefix(strings.TrimSpace(v), "{") {
+			target = value
+		}
+		if err := json.Unmarshal([]byte(v), target); err != nil {
+			logger.Error(err, "failed to parse tolerations configuration")
+			return nil, fmt.Errorf("invalid tolerations configuration: %w", err)
+		}
+
+		if len(value.Tolerations) == 0 && !value.Clear {
+			logger.Info("configuration has no tolerations, skipping")
+			continue
+		}
+
+		configs = append(configs, &tolerationsConfig{
+			qualifier:   k.Qualifier,
+			clear:       value.Clear,
+			tolerations: value.Tolerations,
+		})
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
+}
@@ -0,0 +1,363 @@
+package tolerations
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/annotationtest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestTolerationsHandler_Mutate(t *testing.T) {
+	gpu := corev1.Toleration{
+		Key:      "pool",
+		Operator: corev1.TolerationOpEqual,
+		Value:    "gpu",
+		Effect:   corev1.TaintEffectNoSchedule,
+	}
+	spot := corev1.Toleration{
+		Key:      "spot",
+		Operator: corev1.TolerationOpExists,
+		Effect:   corev1.TaintEffectNoExecute,
+	}
+	unreachable := corev1.Toleration{
+		Key:      "node.kubernetes.io/unreachable",
+		Operator: corev1.TolerationOpExists,
+		Effect:   corev1.TaintEffectNoExecute,
+	}
+	withSeconds := func(t corev1.Toleration, seconds int64) corev1.Toleration {
+		t.TolerationSeconds = &seconds
+		return t
+	}
+
+	type args struct {
+		spec    *corev1.PodSpec
+		ordinal int
+		cfg     any
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "wrong config type",
+			args: args{
+				spec:    nil,
+				ordinal: 0,
+				cfg:     nil,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "do nothing because ordinal doesn't qualify",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 0,
+				cfg: []*tolerationsConfig{{
+					qualifier:   "3-",
+					tolerations: []tolerationConfig{{Toleration: gpu}},
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "append new tolerations",
+			args: args{
+				spec: &corev1.PodSpec{
+					Tolerations: []corev1.Toleration{spot},
+				},
+				ordinal: 4,
+				cfg: []*tolerationsConfig{{
+					qualifier:   "3-",
+					tolerations: []tolerationConfig{{Toleration: gpu}},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Tolerations: []corev1.Toleration{spot, gpu},
+			},
+			wantErr: false,
+		},
+		{
+			name: "clear existing tolerations before appending",
+			args: args{
+				spec: &corev1.PodSpec{
+					Tolerations: []corev1.Toleration{spot, unreachable},
+				},
+				ordinal: 0,
+				cfg: []*tolerationsConfig{{
+					qualifier:   "0",
+					clear:       true,
+					tolerations: []tolerationConfig{{Toleration: gpu}},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Tolerations: []corev1.Toleration{gpu},
+			},
+			wantErr: false,
+		},
+		{
+			name: "clear all tolerations",
+			args: args{
+				spec: &corev1.PodSpec{
+					Tolerations: []corev1.Toleration{spot, unreachable},
+				},
+				ordinal: 0,
+				cfg: []*tolerationsConfig{{
+					qualifier: "0",
+					clear:     true,
+				}},
+			},
+			want:    &corev1.PodSpec{},
+			wantErr: false,
+		},
+		{
+			name: "keep tolerations of ordinals excluded from clearing",
+			args: args{
+				spec: &corev1.PodSpec{
+					Tolerations: []corev1.Toleration{spot},
+				},
+				ordinal: 1,
+				cfg: []*tolerationsConfig{{
+					qualifier: "0",
+					clear:     true,
+				}},
+			},
+			want: &corev1.PodSpec{
+				Tolerations: []corev1.Toleration{spot},
+			},
+			wantErr: false,
+		},
+		{
+			name: "skip duplicate tolerations",
+			args: args{
+				spec: &corev1.PodSpec{
+					Tolerations: []corev1.Toleration{gpu},
+				},
+				ordinal: 4,
+				cfg: []*tolerationsConfig{{
+					tolerations: []tolerationConfig{{Toleration: gpu}, {Toleration: spot}, {Toleration: spot}},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Tolerations: []corev1.Toleration{gpu, spot},
+			},
+			wantErr: false,
+		},
+		{
+			name: "scale toleration seconds with the ordinal",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 3,
+				cfg: []*tolerationsConfig{{
+					tolerations: []tolerationConfig{{
+						Toleration:        unreachable,
+						TolerationSeconds: &annotation.OrdinalScale{Base: 60, Step: 30},
+					}},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Tolerations: []corev1.Toleration{withSeconds(unreachable, 150)},
+			},
+			wantErr: false,
+		},
+		{
+			name: "clamp scaled toleration seconds to max",
+			args: args{
+				spec:    &corev1.PodSpec{},
+				ordinal: 10,
+				cfg: []*tolerationsConfig{{
+					tolerations: []tolerationConfig{{
+						Toleration:        unreachable,
+						TolerationSeconds: &annotation.OrdinalScale{Base: 60, Step: 30, Max: ptr.To[int64](300)},
+					}},
+				}},
+			},
+			want: &corev1.PodSpec{
+				Tolerations: []corev1.Toleration{withSeconds(unreachable, 300)},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &TolerationsHandler{}
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
+				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestTolerationsHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: Tolerations}:  `[{"key":"dedicated","operator":"Equal","value":"seed","effect":"NoSchedule"}]`,
+		{Qualifier: "1-", Name: Tolerations}: `[{"key":"dedicated","operator":"Equal","value":"replica","effect":"NoSchedule"}]`,
+	}
+
+	for ordinal, want := range map[int]string{0: "seed", 3: "replica"} {
+		spec, err := annotationtest.ApplyOrdinal(&TolerationsHandler{}, annotations, &corev1.PodSpec{}, ordinal)
+		if err != nil {
+			t.Fatalf("ApplyOrdinal() ordinal %d error = %v", ordinal, err)
+		}
+		if len(spec.Tolerations) != 1 || spec.Tolerations[0].Value != want {
+			t.Errorf("ApplyOrdinal() ordinal %d tolerations = %v, want a single %s toleration", ordinal, spec.Tolerations, want)
+		}
+	}
+}
+
+func Test_tolerationsParser_Parse(t *testing.T) {
+	type args struct {
+		annotations map[annotation.QualifiedName]string
+	}
+	tests := []struct {
+		name    string
+		p       annotation.ParserFunc
+		args    args
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "no expected annotation",
+			p:       tolerationsParser,
+			args:    args{annotations: map[annotation.QualifiedName]string{}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "valid config",
+			p:    tolerationsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Qualifier: "3-",
+					Name:      Tolerations,
+				}: `[{"key":"pool","operator":"Equal","value":"gpu","effect":"NoSchedule"}]`,
+			}},
+			want: []*tolerationsConfig{{
+				qualifier: "3-",
+				tolerations: []tolerationConfig{
+					{
+						Toleration: corev1.Toleration{
+							Key:      "pool",
+							Operator: corev1.TolerationOpEqual,
+							Value:    "gpu",
+							Effect:   corev1.TaintEffectNoSchedule,
+						},
+					},
+				},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "scaled toleration seconds",
+			p:    tolerationsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Tolerations}: `[{"key":"node.kubernetes.io/unreachable","operator":"Exists","effect":"NoExecute","tolerationSeconds":{"base":60,"step":30}}]`,
+			}},
+			want: []*tolerationsConfig{{
+				tolerations: []tolerationConfig{
+					{
+						Toleration: corev1.Toleration{
+							Key:      "node.kubernetes.io/unreachable",
+							Operator: corev1.TolerationOpExists,
+							Effect:   corev1.TaintEffectNoExecute,
+						},
+						TolerationSeconds: &annotation.OrdinalScale{Base: 60, Step: 30},
+					},
+				},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "fixed toleration seconds",
+			p:    tolerationsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Tolerations}: `[{"key":"spot","operator":"Exists","effect":"NoExecute","tolerationSeconds":120}]`,
+			}},
+			want: []*tolerationsConfig{{
+				tolerations: []tolerationConfig{
+					{
+						Toleration: corev1.Toleration{
+							Key:      "spot",
+							Operator: corev1.TolerationOpExists,
+							Effect:   corev1.TaintEffectNoExecute,
+						},
+						TolerationSeconds: &annotation.OrdinalScale{Base: 120},
+					},
+				},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "object form clearing tolerations",
+			p:    tolerationsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "0", Name: Tolerations}: `{"clear":true,"tolerations":[{"key":"spot","operator":"Exists"}]}`,
+			}},
+			want: []*tolerationsConfig{{
+				qualifier: "0",
+				clear:     true,
+				tolerations: []tolerationConfig{
+					{
+						Toleration: corev1.Toleration{
+							Key:      "spot",
+							Operator: corev1.TolerationOpExists,
+						},
+					},
+				},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "object form only clearing",
+			p:    tolerationsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Qualifier: "0", Name: Tolerations}: ` {"clear":true}`,
+			}},
+			want: []*tolerationsConfig{{
+				qualifier: "0",
+				clear:     true,
+			}},
+			wantErr: false,
+		},
+		{
+			name: "object form without anything to do",
+			p:    tolerationsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: Tolerations}: `{"clear":false}`,
+			}},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "invalid json",
+			p:    tolerationsParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Name: Tolerations,
+				}: `[{"key":`,
+			}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Parse(tt.args.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
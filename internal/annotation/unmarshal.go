@@ -0,0 +1,49 @@
+package annotation
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/yaml"
+)
+
+// unmarshalOptions controls how Unmarshal decodes annotation values
+type unmarshalOptions struct {
+	strict bool
+}
+
+// UnmarshalOption configures Unmarshal
+type UnmarshalOption func(*unmarshalOptions)
+
+// Strict makes Unmarshal reject fields the target type doesn't declare, so that
+// misspelled fields are reported instead of silently ignored
+func Strict() UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.strict = true
+	}
+}
+
+// Unmarshal decodes an annotation value into v. Values starting with "{" are
+// decoded as JSON, anything else as YAML, using the json tags of v either way.
+func Unmarshal(value string, v any, opts ...UnmarshalOption) error {
+	o := &unmarshalOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		if !o.strict {
+			return json.Unmarshal([]byte(value), v)
+		}
+		decoder := stdjson.NewDecoder(bytes.NewReader([]byte(value)))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(v)
+	}
+
+	if o.strict {
+		return yaml.UnmarshalStrict([]byte(value), v)
+	}
+	return yaml.Unmarshal([]byte(value), v)
+}
@@ -0,0 +1,116 @@
+package annotation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	type value struct {
+		Name  string   `json:"name"`
+		Ports []int32  `json:"ports"`
+		Tags  []string `json:"tags,omitempty"`
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    value
+		wantErr bool
+	}{
+		{
+			name:  "json",
+			value: `{"name":"web","ports":[80,443]}`,
+			want:  value{Name: "web", Ports: []int32{80, 443}},
+		},
+		{
+			name:  "json with leading whitespace",
+			value: "\n  {\"name\":\"web\"}",
+			want:  value{Name: "web"},
+		},
+		{
+			name:  "yaml",
+			value: "name: web\nports:\n- 80\n- 443\n",
+			want:  value{Name: "web", Ports: []int32{80, 443}},
+		},
+		{
+			name:    "invalid json",
+			value:   `{"name":`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			value:   "name: [web",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got value
+			err := Unmarshal(tt.value, &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_Strict(t *testing.T) {
+	type value struct {
+		ContainerPort int32 `json:"containerPort"`
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		opts    []UnmarshalOption
+		want    value
+		wantErr bool
+	}{
+		{
+			name:  "lenient json ignores unknown fields",
+			value: `{"contianerPort":8080}`,
+			want:  value{},
+		},
+		{
+			name:    "strict json rejects unknown fields",
+			value:   `{"contianerPort":8080}`,
+			opts:    []UnmarshalOption{Strict()},
+			wantErr: true,
+		},
+		{
+			name:  "strict json accepts known fields",
+			value: `{"containerPort":8080}`,
+			opts:  []UnmarshalOption{Strict()},
+			want:  value{ContainerPort: 8080},
+		},
+		{
+			name:  "lenient yaml ignores unknown fields",
+			value: "contianerPort: 8080",
+			want:  value{},
+		},
+		{
+			name:    "strict yaml rejects unknown fields",
+			value:   "contianerPort: 8080",
+			opts:    []UnmarshalOption{Strict()},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got value
+			err := Unmarshal(tt.value, &got, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,22 +1,34 @@
 package volumes
 
 import (
+	_ "embed"
 	"fmt"
-	"strconv"
+	"slices"
+	"strings"
 
+	"github.com/go-logr/logr"
 	"github.com/golem-base/spoditor/internal/annotation"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/json"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 const (
 	// MountVolume is the annotation key for volume mounting configuration
 	MountVolume = "mount-volume"
+
+	// NamePlaceholder is replaced with the original name in suffix templates
+	NamePlaceholder = "{{name}}"
+	// DefaultSuffixTemplate suffixes names with a dash and the ordinal
+	DefaultSuffixTemplate = NamePlaceholder + "-" + annotation.OrdinalPlaceholder
 )
 
 var log = logf.Log.WithName("mount_volume")
 
+// schema is the JSON Schema of the annotation value
+//
+//go:embed mount.schema.json
+var schema []byte
+
 // mountConfig holds the volume mounting configuration with its pod qualifier
 type mountConfig struct {
 	qualifier string            // Which pods this applies to
@@ -25,126 +37,349 @@ type mountConfig struct {
 
 // mountConfigValue represents the JSON structure of the volume mount configuration
 type mountConfigValue struct {
-	Volumes    []corev1.Volume    `json:"volumes"`    // Volumes to be added to the pod
-	Containers []corev1.Container `json:"containers"` // Container configurations for volume mounts
+	Volumes    []corev1.Volume    `json:"volumes"`             // Volumes to be added to the pod
+	Containers []corev1.Container `json:"containers"`          // Container configurations for volume mounts
+	Overrides  []volumeOverride   `json:"overrides,omitempty"` // Per-ordinal volume sources
+	// SuffixVolumeNames also suffixes the names of the volumes, and of the
+	// mounts referring to them, like referenced objects, e.g. data becomes
+	// data-2
+	SuffixVolumeNames bool `json:"suffixVolumeNames,omitempty"`
+	// SuffixTemplate renders the per-ordinal names of referenced objects,
+	// e.g. {{name}}.{{ordinal}}, DefaultSuffixTemplate when empty
+	SuffixTemplate string `json:"suffixTemplate,omitempty"`
+}
+
+// suffixed returns the per-ordinal variant of name rendered with the suffix
+// template
+func (c *mountConfigValue) suffixed(name string, ordinal int) string {
+	tmpl := c.SuffixTemplate
+	if tmpl == "" {
+		tmpl = DefaultSuffixTemplate
+	}
+	return annotation.SubstituteOrdinal(strings.ReplaceAll(tmpl, NamePlaceholder, name), ordinal)
+}
+
+// volumeOverride replaces the source of the named volumes for the pods whose
+// ordinal matches its qualifier, e.g. to give ordinal 0 a PVC and the others
+// an emptyDir
+type volumeOverride struct {
+	Qualifier string          `json:"qualifier"`
+	Volumes   []corev1.Volume `json:"volumes"`
+}
+
+// volumeFor returns the volume to add to the pod: the first override of the
+// same name whose qualifier matches, or v itself
+func (c *mountConfigValue) volumeFor(v corev1.Volume, mc annotation.MutationContext) corev1.Volume {
+	for _, o := range c.Overrides {
+		if !mc.Selects(o.Qualifier) {
+			continue
+		}
+		for _, ov := range o.Volumes {
+			if ov.Name == v.Name {
+				return ov
+			}
+		}
+	}
+	return v
+}
+
+// hasVolume reports whether the configuration adds a volume with the given name
+func (c *mountConfigValue) hasVolume(name string) bool {
+	return hasVolume(c.Volumes, name)
+}
+
+// validate checks that the suffix template tells names and ordinals apart,
+// that every override replaces a configured volume and that mounts propagate
+// in a mode the kubelet knows
+func (c *mountConfigValue) validate() error {
+	if c.SuffixTemplate != "" {
+		for _, placeholder := range []string{NamePlaceholder, annotation.OrdinalPlaceholder} {
+			if !strings.Contains(c.SuffixTemplate, placeholder) {
+				return fmt.Errorf("suffixTemplate %q must contain %s", c.SuffixTemplate, placeholder)
+			}
+		}
+	}
+	for _, o := range c.Overrides {
+		for _, ov := range o.Volumes {
+			if !c.hasVolume(ov.Name) {
+				return fmt.Errorf("override for ordinals %q names unknown volume %q", o.Qualifier, ov.Name)
+			}
+		}
+	}
+	for _, container := range c.Containers {
+		for _, mount := range container.VolumeMounts {
+			if mount.MountPropagation == nil {
+				continue
+			}
+			switch *mount.MountPropagation {
+			case corev1.MountPropagationNone, corev1.MountPropagationHostToContainer, corev1.MountPropagationBidirectional:
+			default:
+				return fmt.Errorf("container %q: mount %q: mountPropagation %q must be %q, %q or %q",
+					container.Name, mount.Name, *mount.MountPropagation,
+					corev1.MountPropagationNone, corev1.MountPropagationHostToContainer, corev1.MountPropagationBidirectional)
+			}
+		}
+	}
+	return nil
 }
 
 // Ensure MountHandler implements Handler interface
 var _ annotation.Handler = (*MountHandler)(nil)
 
 // MountHandler handles volume mount operations based on annotations
-type MountHandler struct{}
+type MountHandler struct {
+	// Strict rejects annotation values with fields the configuration doesn't
+	// know about, instead of ignoring them
+	Strict bool
+}
 
-// Mutate modifies the pod spec to add volumes and volume mounts as specified
-func (h *MountHandler) Mutate(spec *corev1.PodSpec, ordinal int, cfg any) error {
-	l := log.WithValues("ordinal", ordinal)
+// Mutate modifies the pod spec to add volumes and volume mounts as specified by
+// every configuration whose qualifier matches the pod ordinal
+func (h *MountHandler) Mutate(spec *corev1.PodSpec, mc annotation.MutationContext, cfg any) error {
+	l := log.WithValues("ordinal", mc.Ordinal)
 
 	// Type assertion for our config
-	m, ok := cfg.(*mountConfig)
+	configs, ok := cfg.([]*mountConfig)
 	if !ok {
-		return fmt.Errorf("unexpected config type %T, expected *mountConfig", cfg)
+		return fmt.Errorf("unexpected config type %T, expected []*mountConfig", cfg)
 	}
 
-	// Check if this pod matches the qualifier
-	if !annotation.CommonPodQualifier(ordinal, m.qualifier) {
-		l.Info("qualifier excludes this pod")
-		return nil
+	for _, m := range configs {
+		// Check if this pod matches the qualifier
+		if !mc.Selects(m.qualifier) {
+			l.Info("qualifier excludes this pod", "qualifier", m.qualifier)
+			continue
+		}
+		m.apply(spec, mc, l)
 	}
 
+	return nil
+}
+
+// apply adds the volumes and volume mounts of a single configuration
+func (m *mountConfig) apply(spec *corev1.PodSpec, mc annotation.MutationContext, l logr.Logger) {
 	l.Info("applying volume mounts to pod")
 
-	// Process volumes, adding ordinal suffix to ConfigMap and Secret references
+	// Process volumes, adding ordinal suffix to ConfigMap, Secret and ephemeral
+	// claim references and templating the ordinal into CSI volume attributes
+	// and image references
 	volumes := make([]corev1.Volume, len(m.cfg.Volumes))
+
+	// Per-ordinal names of ConfigMaps and Secrets, and of volumes if asked for
+	suffixed := func(name string) string {
+		return m.cfg.suffixed(name, mc.Ordinal)
+	}
+
 	for i, v := range m.cfg.Volumes {
-		// Create a deep copy to avoid modifying the original
-		volumes[i] = v
+		// Pick the volume source for this ordinal
+		v = m.cfg.volumeFor(v, mc)
 
-		// Suffix for ConfigMap and Secret names
-		ordinalSuffix := "-" + strconv.Itoa(ordinal)
+		// Create a deep copy to avoid modifying the original
+		volumes[i] = *v.DeepCopy()
 
 		// Handle ConfigMap references
 		if v.ConfigMap != nil {
 			originalName := v.ConfigMap.LocalObjectReference.Name
-			newName := originalName + ordinalSuffix
+			newName := suffixed(originalName)
 
 			l.Info("renaming configmap reference",
 				"volume", v.Name,
 				"from", originalName,
 				"to", newName)
 
-			volumes[i].ConfigMap = v.ConfigMap.DeepCopy()
 			volumes[i].ConfigMap.LocalObjectReference.Name = newName
 		}
 
 		// Handle Secret references
 		if v.Secret != nil {
 			originalName := v.Secret.SecretName
-			newName := originalName + ordinalSuffix
+			newName := suffixed(originalName)
 
 			l.Info("renaming secret reference",
 				"volume", v.Name,
 				"from", originalName,
 				"to", newName)
 
-			volumes[i].Secret = v.Secret.DeepCopy()
 			volumes[i].Secret.SecretName = newName
 		}
+
+		// Handle CSI volume attributes, templating the ordinal into their values
+		if v.CSI != nil {
+			for key, value := range v.CSI.VolumeAttributes {
+				templated := annotation.SubstituteOrdinal(value, mc.Ordinal)
+
+				l.Info("templating csi volume attribute",
+					"volume", v.Name,
+					"attribute", key,
+					"from", value,
+					"to", templated)
+
+				volumes[i].CSI.VolumeAttributes[key] = templated
+			}
+		}
+
+		// Handle generic ephemeral volumes, suffixing the objects the claim
+		// template refers to, e.g. a snapshot to restore per ordinal. The
+		// storage class is shared, and the claim itself is named after the pod.
+		if v.Ephemeral != nil && v.Ephemeral.VolumeClaimTemplate != nil {
+			claim := &volumes[i].Ephemeral.VolumeClaimTemplate.Spec
+			if claim.DataSource != nil {
+				claim.DataSource.Name = suffixed(claim.DataSource.Name)
+			}
+			if claim.DataSourceRef != nil {
+				claim.DataSourceRef.Name = suffixed(claim.DataSourceRef.Name)
+			}
+			if claim.VolumeName != "" {
+				claim.VolumeName = suffixed(claim.VolumeName)
+			}
+
+			l.Info("suffixing ephemeral volume claim references",
+				"volume", v.Name,
+				"ordinal", mc.Ordinal)
+		}
+
+		// Handle image volumes, templating the ordinal into the OCI reference
+		if v.Image != nil {
+			templated := annotation.SubstituteOrdinal(v.Image.Reference, mc.Ordinal)
+
+			l.Info("templating image volume reference",
+				"volume", v.Name,
+				"from", v.Image.Reference,
+				"to", templated)
+
+			volumes[i].Image.Reference = templated
+		}
+
+		if m.cfg.SuffixVolumeNames {
+			l.Info("suffixing volume name",
+				"volume", v.Name,
+				"to", suffixed(v.Name))
+
+			volumes[i].Name = suffixed(v.Name)
+		}
 	}
 
-	// Add processed volumes to the pod spec
-	spec.Volumes = append(spec.Volumes, volumes...)
+	// Add processed volumes to the pod spec, skipping those it already has,
+	// e.g. when the pod is admitted again
+	for _, v := range volumes {
+		if hasVolume(spec.Volumes, v.Name) {
+			l.Info("pod already has volume, skipping", "volume", v.Name)
+			continue
+		}
+		spec.Volumes = append(spec.Volumes, v)
+	}
 
-	// Add volume mounts to matching containers
+	// Add volume mounts to matching containers, all of them for the wildcard
 	for _, source := range m.cfg.Containers {
 		for i := range spec.Containers {
-			if source.Name == spec.Containers[i].Name {
+			if annotation.MatchesContainer(source.Name, spec.Containers[i].Name) {
 				l.Info("adding volume mounts to container",
 					"container", source.Name,
 					"mounts", len(source.VolumeMounts))
 
-				spec.Containers[i].VolumeMounts = append(
-					spec.Containers[i].VolumeMounts,
-					source.VolumeMounts...)
+				for _, mount := range source.VolumeMounts {
+					mount := *mount.DeepCopy()
+					// Keep mounts of the configured volumes pointing at their
+					// suffixed names, mounts of other volumes are left alone
+					if m.cfg.SuffixVolumeNames && m.cfg.hasVolume(mount.Name) {
+						mount.Name = suffixed(mount.Name)
+					}
+					if hasVolumeMount(spec.Containers[i].VolumeMounts, mount) {
+						l.Info("container already has volume mount, skipping",
+							"container", spec.Containers[i].Name,
+							"volume", mount.Name,
+							"mountPath", mount.MountPath)
+						continue
+					}
+					spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, mount)
+				}
 			}
 		}
 	}
+}
 
-	return nil
+// hasVolume reports whether volumes has one with the given name
+func hasVolume(volumes []corev1.Volume, name string) bool {
+	return slices.ContainsFunc(volumes, func(v corev1.Volume) bool { return v.Name == name })
+}
+
+// hasVolumeMount reports whether mounts already mounts the same volume at the
+// same path
+func hasVolumeMount(mounts []corev1.VolumeMount, mount corev1.VolumeMount) bool {
+	return slices.ContainsFunc(mounts, func(m corev1.VolumeMount) bool {
+		return m.Name == mount.Name && m.MountPath == mount.MountPath
+	})
+}
+
+// Name returns the annotation feature name this handler responds to
+func (h *MountHandler) Name() string {
+	return MountVolume
+}
+
+// Schema returns the JSON Schema of the annotation value
+func (h *MountHandler) Schema() []byte {
+	return schema
 }
 
 // GetParser returns the parser for volume mount annotations
 func (h *MountHandler) GetParser() annotation.Parser {
+	if h.Strict {
+		return strictVolumeMountParser
+	}
 	return volumeMountParser
 }
 
-// volumeMountParser parses volume mount annotations into a mountConfig
-var volumeMountParser annotation.ParserFunc = func(annotations map[annotation.QualifiedName]string) (any, error) {
-	for k, v := range annotations {
+var (
+	// volumeMountParser parses volume mount annotations into a mountConfig
+	volumeMountParser = newVolumeMountParser()
+	// strictVolumeMountParser is volumeMountParser rejecting unknown fields
+	strictVolumeMountParser = newVolumeMountParser(annotation.Strict())
+)
+
+// newVolumeMountParser creates a volume mount parser decoding with the given options
+func newVolumeMountParser(opts ...annotation.UnmarshalOption) annotation.ParserFunc {
+	return func(annotations map[annotation.QualifiedName]string) (any, error) {
+		return parseVolumeMount(annotations, opts...)
+	}
+}
+
+// parseVolumeMount parses every volume mount annotation, whatever its qualifier,
+// into a mountConfig, returning them in annotation key order
+func parseVolumeMount(annotations map[annotation.QualifiedName]string, opts ...annotation.UnmarshalOption) (any, error) {
+	var configs []*mountConfig
+	for _, k := range annotation.SortedKeys(annotations) {
 		if k.Name != MountVolume {
 			continue
 		}
+		v := annotations[k]
 
 		logger := log.WithValues("qualifiedName", k, "value", v)
 		logger.Info("parsing volume mount configuration")
 
 		// Attempt to unmarshal the JSON configuration
 		config := &mountConfigValue{}
-		if err := json.Unmarshal([]byte(v), config); err != nil {
+		if err := annotation.Unmarshal(v, config, opts...); err != nil {
 			logger.Error(err, "failed to parse volume mount configuration")
-			return nil, fmt.Errorf("invalid volume mount configuration: %w", err)
+			return nil, annotation.NewParseError(k, v, err)
 		}
 
 		// Validate the configuration
 		if len(config.Volumes) == 0 {
 			logger.Info("configuration has no volumes, skipping")
-			return nil, nil
+			continue
+		}
+		if err := config.validate(); err != nil {
+			return nil, annotation.NewParseError(k, v, err)
 		}
 
-		return &mountConfig{
+		configs = append(configs, &mountConfig{
 			qualifier: k.Qualifier,
 			cfg:       config,
-		}, nil
+		})
 	}
 
-	return nil, nil
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return configs, nil
 }
@@ -1,13 +1,17 @@
 package volumes
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/golem-base/spoditor/internal/annotation"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/utils/ptr"
 )
 
 func TestMountHandler_Mutate(t *testing.T) {
@@ -37,10 +41,10 @@ func TestMountHandler_Mutate(t *testing.T) {
 			args: args{
 				spec:    &v1.PodSpec{},
 				ordinal: 0,
-				cfg: &mountConfig{
+				cfg: []*mountConfig{{
 					qualifier: "1-2",
 					cfg:       nil,
-				},
+				}},
 			},
 			want:    &v1.PodSpec{},
 			wantErr: false,
@@ -56,7 +60,7 @@ func TestMountHandler_Mutate(t *testing.T) {
 					},
 				},
 				ordinal: 0,
-				cfg: &mountConfig{
+				cfg: []*mountConfig{{
 					qualifier: "",
 					cfg: &mountConfigValue{
 						Volumes: []v1.Volume{
@@ -83,7 +87,7 @@ func TestMountHandler_Mutate(t *testing.T) {
 							},
 						},
 					},
-				},
+				}},
 			},
 			want: &v1.PodSpec{
 				Containers: []v1.Container{
@@ -123,7 +127,7 @@ func TestMountHandler_Mutate(t *testing.T) {
 					},
 				},
 				ordinal: 0,
-				cfg: &mountConfig{
+				cfg: []*mountConfig{{
 					qualifier: "",
 					cfg: &mountConfigValue{
 						Volumes: []v1.Volume{
@@ -148,7 +152,7 @@ func TestMountHandler_Mutate(t *testing.T) {
 							},
 						},
 					},
-				},
+				}},
 			},
 			want: &v1.PodSpec{
 				Containers: []v1.Container{
@@ -175,11 +179,274 @@ func TestMountHandler_Mutate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "template ordinal into csi volume attributes",
+			args: args{
+				spec: &v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Name: "main-container",
+						},
+					},
+				},
+				ordinal: 3,
+				cfg: []*mountConfig{{
+					qualifier: "",
+					cfg: &mountConfigValue{
+						Volumes: []v1.Volume{
+							{
+								Name: "my-csi",
+								VolumeSource: v1.VolumeSource{
+									CSI: &v1.CSIVolumeSource{
+										Driver: "example.csi.k8s.io",
+										VolumeAttributes: map[string]string{
+											"subvolume": "shard-{{ordinal}}",
+											"pool":      "fast",
+										},
+									},
+								},
+							},
+						},
+						Containers: []v1.Container{
+							{
+								Name: "main-container",
+								VolumeMounts: []v1.VolumeMount{
+									{
+										Name:      "my-csi",
+										MountPath: "/data",
+									},
+								},
+							},
+						},
+					},
+				}},
+			},
+			want: &v1.PodSpec{
+				Containers: []v1.Container{
+					{
+						Name: "main-container",
+						VolumeMounts: []v1.VolumeMount{
+							{
+								Name:      "my-csi",
+								MountPath: "/data",
+							},
+						},
+					},
+				},
+				Volumes: []v1.Volume{
+					{
+						Name: "my-csi",
+						VolumeSource: v1.VolumeSource{
+							CSI: &v1.CSIVolumeSource{
+								Driver: "example.csi.k8s.io",
+								VolumeAttributes: map[string]string{
+									"subvolume": "shard-3",
+									"pool":      "fast",
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "template ordinal into image volume reference",
+			args: args{
+				spec: &v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Name: "main-container",
+						},
+					},
+				},
+				ordinal: 2,
+				cfg: []*mountConfig{{
+					qualifier: "",
+					cfg: &mountConfigValue{
+						Volumes: []v1.Volume{
+							{
+								Name: "model",
+								VolumeSource: v1.VolumeSource{
+									Image: &v1.ImageVolumeSource{
+										Reference:  "registry.example.com/models:shard-{{ordinal}}",
+										PullPolicy: v1.PullIfNotPresent,
+									},
+								},
+							},
+						},
+						Containers: []v1.Container{
+							{
+								Name: "main-container",
+								VolumeMounts: []v1.VolumeMount{
+									{
+										Name:      "model",
+										MountPath: "/model",
+									},
+								},
+							},
+						},
+					},
+				}},
+			},
+			want: &v1.PodSpec{
+				Containers: []v1.Container{
+					{
+						Name: "main-container",
+						VolumeMounts: []v1.VolumeMount{
+							{
+								Name:      "model",
+								MountPath: "/model",
+							},
+						},
+					},
+				},
+				Volumes: []v1.Volume{
+					{
+						Name: "model",
+						VolumeSource: v1.VolumeSource{
+							Image: &v1.ImageVolumeSource{
+								Reference:  "registry.example.com/models:shard-2",
+								PullPolicy: v1.PullIfNotPresent,
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "suffix ephemeral volume claim references",
+			args: args{
+				spec: &v1.PodSpec{
+					Containers: []v1.Container{{Name: "main-container"}},
+				},
+				ordinal: 2,
+				cfg: []*mountConfig{{
+					cfg: &mountConfigValue{
+						Volumes: []v1.Volume{
+							{
+								Name: "data",
+								VolumeSource: v1.VolumeSource{
+									Ephemeral: &v1.EphemeralVolumeSource{
+										VolumeClaimTemplate: &v1.PersistentVolumeClaimTemplate{
+											ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"type": "scratch"}},
+											Spec: v1.PersistentVolumeClaimSpec{
+												AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+												StorageClassName: ptr.To("fast"),
+												DataSource: &v1.TypedLocalObjectReference{
+													APIGroup: ptr.To("snapshot.storage.k8s.io"),
+													Kind:     "VolumeSnapshot",
+													Name:     "seed",
+												},
+												DataSourceRef: &v1.TypedObjectReference{
+													APIGroup: ptr.To("snapshot.storage.k8s.io"),
+													Kind:     "VolumeSnapshot",
+													Name:     "seed",
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+						Containers: []v1.Container{
+							{
+								Name:         "main-container",
+								VolumeMounts: []v1.VolumeMount{{Name: "data", MountPath: "/data"}},
+							},
+						},
+					},
+				}},
+			},
+			want: &v1.PodSpec{
+				Containers: []v1.Container{
+					{
+						Name:         "main-container",
+						VolumeMounts: []v1.VolumeMount{{Name: "data", MountPath: "/data"}},
+					},
+				},
+				Volumes: []v1.Volume{
+					{
+						Name: "data",
+						VolumeSource: v1.VolumeSource{
+							Ephemeral: &v1.EphemeralVolumeSource{
+								VolumeClaimTemplate: &v1.PersistentVolumeClaimTemplate{
+									ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"type": "scratch"}},
+									Spec: v1.PersistentVolumeClaimSpec{
+										AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+										StorageClassName: ptr.To("fast"),
+										DataSource: &v1.TypedLocalObjectReference{
+											APIGroup: ptr.To("snapshot.storage.k8s.io"),
+											Kind:     "VolumeSnapshot",
+											Name:     "seed-2",
+										},
+										DataSourceRef: &v1.TypedObjectReference{
+											APIGroup: ptr.To("snapshot.storage.k8s.io"),
+											Kind:     "VolumeSnapshot",
+											Name:     "seed-2",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "mount into every container through the wildcard",
+			args: args{
+				spec: &v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: "main-container"},
+						{Name: "sidecar"},
+					},
+				},
+				ordinal: 1,
+				cfg: []*mountConfig{{
+					cfg: &mountConfigValue{
+						Volumes: []v1.Volume{
+							{
+								Name:         "scratch",
+								VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+							},
+						},
+						Containers: []v1.Container{
+							{
+								Name:         "*",
+								VolumeMounts: []v1.VolumeMount{{Name: "scratch", MountPath: "/scratch"}},
+							},
+						},
+					},
+				}},
+			},
+			want: &v1.PodSpec{
+				Containers: []v1.Container{
+					{
+						Name:         "main-container",
+						VolumeMounts: []v1.VolumeMount{{Name: "scratch", MountPath: "/scratch"}},
+					},
+					{
+						Name:         "sidecar",
+						VolumeMounts: []v1.VolumeMount{{Name: "scratch", MountPath: "/scratch"}},
+					},
+				},
+				Volumes: []v1.Volume{
+					{
+						Name:         "scratch",
+						VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+					},
+				},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			h := &MountHandler{}
-			if err := h.Mutate(tt.args.spec, tt.args.ordinal, tt.args.cfg); (err != nil) != tt.wantErr {
+			if err := h.Mutate(tt.args.spec, annotation.MutationContext{Ordinal: tt.args.ordinal}, tt.args.cfg); (err != nil) != tt.wantErr {
 				t.Errorf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
 			} else if !reflect.DeepEqual(tt.args.spec, tt.want) {
 				t.Errorf("Mutate() = %v, want %v", tt.args.spec, tt.want)
@@ -188,6 +455,248 @@ func TestMountHandler_Mutate(t *testing.T) {
 	}
 }
 
+func TestMountHandler_Mutate_MultipleQualifiers(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0-2", Name: MountVolume}: `{"volumes":[{"name":"config","configMap":{"name":"low"}}],"containers":[{"name":"app","volumeMounts":[{"name":"config","mountPath":"/etc/low"}]}]}`,
+		{Qualifier: "3-5", Name: MountVolume}: `{"volumes":[{"name":"config","configMap":{"name":"high"}}],"containers":[{"name":"app","volumeMounts":[{"name":"config","mountPath":"/etc/high"}]}]}`,
+	}
+
+	h := &MountHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		ordinal   int
+		configMap string
+		mountPath string
+	}{
+		{ordinal: 1, configMap: "low-1", mountPath: "/etc/low"},
+		{ordinal: 4, configMap: "high-4", mountPath: "/etc/high"},
+	}
+	for _, tt := range tests {
+		spec := &v1.PodSpec{Containers: []v1.Container{{Name: "app"}}}
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: tt.ordinal}, cfg); err != nil {
+			t.Fatalf("Mutate() ordinal %d error = %v", tt.ordinal, err)
+		}
+
+		if len(spec.Volumes) != 1 || spec.Volumes[0].ConfigMap.Name != tt.configMap {
+			t.Errorf("Mutate() ordinal %d volumes = %v, want configmap %s", tt.ordinal, spec.Volumes, tt.configMap)
+		}
+		if mounts := spec.Containers[0].VolumeMounts; len(mounts) != 1 || mounts[0].MountPath != tt.mountPath {
+			t.Errorf("Mutate() ordinal %d mounts = %v, want %s", tt.ordinal, mounts, tt.mountPath)
+		}
+	}
+}
+
+func TestMountHandler_Mutate_Idempotent(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: MountVolume}: `{"volumes":[{"name":"config","configMap":{"name":"config"}}],"containers":[{"name":"app","volumeMounts":[{"name":"config","mountPath":"/etc/config"}]}]}`,
+	}
+
+	h := &MountHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	spec := &v1.PodSpec{Containers: []v1.Container{{Name: "app"}}}
+	for i := 0; i < 2; i++ {
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: 1}, cfg); err != nil {
+			t.Fatalf("Mutate() pass %d error = %v", i, err)
+		}
+	}
+
+	if len(spec.Volumes) != 1 || spec.Volumes[0].ConfigMap.Name != "config-1" {
+		t.Errorf("Mutate() volumes = %v, want a single config-1 configmap", spec.Volumes)
+	}
+	if mounts := spec.Containers[0].VolumeMounts; len(mounts) != 1 || mounts[0].MountPath != "/etc/config" {
+		t.Errorf("Mutate() mounts = %v, want a single mount at /etc/config", mounts)
+	}
+}
+
+func TestMountHandler_Mutate_OrdinalOverrides(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: MountVolume}: `{"volumes":[{"name":"data","emptyDir":{}}],` +
+			`"containers":[{"name":"app","volumeMounts":[{"name":"data","mountPath":"/data"}]}],` +
+			`"overrides":[{"qualifier":"0","volumes":[{"name":"data","persistentVolumeClaim":{"claimName":"data-leader"}}]}]}`,
+	}
+
+	h := &MountHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		ordinal int
+		want    v1.VolumeSource
+	}{
+		{ordinal: 0, want: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "data-leader"}}},
+		{ordinal: 1, want: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+		{ordinal: 2, want: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+	}
+	for _, tt := range tests {
+		spec := &v1.PodSpec{Containers: []v1.Container{{Name: "app"}}}
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: tt.ordinal}, cfg); err != nil {
+			t.Fatalf("Mutate() ordinal %d error = %v", tt.ordinal, err)
+		}
+
+		if len(spec.Volumes) != 1 || spec.Volumes[0].Name != "data" || !reflect.DeepEqual(spec.Volumes[0].VolumeSource, tt.want) {
+			t.Errorf("Mutate() ordinal %d volumes = %v, want source %v", tt.ordinal, spec.Volumes, tt.want)
+		}
+		if mounts := spec.Containers[0].VolumeMounts; len(mounts) != 1 || mounts[0].Name != "data" {
+			t.Errorf("Mutate() ordinal %d mounts = %v, want the data volume mounted", tt.ordinal, mounts)
+		}
+	}
+}
+
+func TestMountHandler_Mutate_SuffixVolumeNames(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		wantVolume string
+		wantMounts []string
+	}{
+		{
+			name: "names kept by default",
+			value: `{"volumes":[{"name":"data","emptyDir":{}}],` +
+				`"containers":[{"name":"app","volumeMounts":[{"name":"data","mountPath":"/data"}]}]}`,
+			wantVolume: "data",
+			wantMounts: []string{"data"},
+		},
+		{
+			name: "volume and its mount suffixed",
+			value: `{"suffixVolumeNames":true,"volumes":[{"name":"data","emptyDir":{}}],` +
+				`"containers":[{"name":"app","volumeMounts":[{"name":"data","mountPath":"/data"},{"name":"existing","mountPath":"/existing"}]}]}`,
+			wantVolume: "data-2",
+			wantMounts: []string{"data-2", "existing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &MountHandler{}
+			cfg, err := h.GetParser().Parse(map[annotation.QualifiedName]string{{Name: MountVolume}: tt.value})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			spec := &v1.PodSpec{Containers: []v1.Container{{Name: "app"}}}
+			if err := h.Mutate(spec, annotation.MutationContext{Ordinal: 2}, cfg); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+
+			if len(spec.Volumes) != 1 || spec.Volumes[0].Name != tt.wantVolume {
+				t.Errorf("Mutate() volumes = %v, want %s", spec.Volumes, tt.wantVolume)
+			}
+			var mounts []string
+			for _, m := range spec.Containers[0].VolumeMounts {
+				mounts = append(mounts, m.Name)
+			}
+			if !reflect.DeepEqual(mounts, tt.wantMounts) {
+				t.Errorf("Mutate() mounts = %v, want %v", mounts, tt.wantMounts)
+			}
+		})
+	}
+}
+
+func TestMountHandler_Mutate_SuffixTemplate(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		wantConfig string
+		wantSecret string
+		wantVolume string
+	}{
+		{name: "default dash", template: "", wantConfig: "app-config-0", wantSecret: "app-secret-0", wantVolume: "data-0"},
+		{name: "dot", template: "{{name}}.{{ordinal}}", wantConfig: "app-config.0", wantSecret: "app-secret.0", wantVolume: "data.0"},
+		{name: "custom", template: "{{name}}-rep{{ordinal}}", wantConfig: "app-config-rep0", wantSecret: "app-secret-rep0", wantVolume: "data-rep0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := fmt.Sprintf(`{"suffixTemplate":%q,"suffixVolumeNames":true,"volumes":[`+
+				`{"name":"config","configMap":{"name":"app-config"}},`+
+				`{"name":"secret","secret":{"secretName":"app-secret"}},`+
+				`{"name":"data","ephemeral":{"volumeClaimTemplate":{"spec":{"dataSource":{"kind":"VolumeSnapshot","name":"data"}}}}}]}`, tt.template)
+
+			h := &MountHandler{}
+			cfg, err := h.GetParser().Parse(map[annotation.QualifiedName]string{{Name: MountVolume}: value})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			spec := &v1.PodSpec{}
+			if err := h.Mutate(spec, annotation.MutationContext{Ordinal: 0}, cfg); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+
+			if got := spec.Volumes[0].ConfigMap.Name; got != tt.wantConfig {
+				t.Errorf("Mutate() configmap = %s, want %s", got, tt.wantConfig)
+			}
+			if got := spec.Volumes[1].Secret.SecretName; got != tt.wantSecret {
+				t.Errorf("Mutate() secret = %s, want %s", got, tt.wantSecret)
+			}
+			if got := spec.Volumes[2].Ephemeral.VolumeClaimTemplate.Spec.DataSource.Name; got != tt.wantVolume {
+				t.Errorf("Mutate() data source = %s, want %s", got, tt.wantVolume)
+			}
+			if got := spec.Volumes[2].Name; got != tt.wantVolume {
+				t.Errorf("Mutate() volume name = %s, want %s", got, tt.wantVolume)
+			}
+		})
+	}
+}
+
+func TestMountHandler_GetParser_SuffixTemplateWithoutOrdinal(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: MountVolume}: `{"suffixTemplate":"{{name}}-shared","volumes":[{"name":"config","configMap":{"name":"app"}}]}`,
+	}
+
+	_, err := (&MountHandler{}).GetParser().Parse(annotations)
+	if err == nil || !strings.Contains(err.Error(), "must contain {{ordinal}}") {
+		t.Errorf("Parse() error = %v, want it to require the ordinal placeholder", err)
+	}
+}
+
+func TestMountHandler_Mutate_MountPropagation(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0", Name: MountVolume}: `{"volumes":[{"name":"csi","hostPath":{"path":"/var/lib/csi"}}],` +
+			`"containers":[{"name":"driver","volumeMounts":[{"name":"csi","mountPath":"/csi","mountPropagation":"Bidirectional"}]}]}`,
+		{Qualifier: "1-", Name: MountVolume}: `{"volumes":[{"name":"csi","hostPath":{"path":"/var/lib/csi"}}],` +
+			`"containers":[{"name":"driver","volumeMounts":[{"name":"csi","mountPath":"/csi","mountPropagation":"HostToContainer"}]}]}`,
+	}
+
+	h := &MountHandler{}
+	cfg, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	for ordinal, want := range map[int]v1.MountPropagationMode{0: v1.MountPropagationBidirectional, 2: v1.MountPropagationHostToContainer} {
+		spec := &v1.PodSpec{Containers: []v1.Container{{Name: "driver"}}}
+		if err := h.Mutate(spec, annotation.MutationContext{Ordinal: ordinal}, cfg); err != nil {
+			t.Fatalf("Mutate() ordinal %d error = %v", ordinal, err)
+		}
+
+		mounts := spec.Containers[0].VolumeMounts
+		if len(mounts) != 1 || mounts[0].MountPropagation == nil || *mounts[0].MountPropagation != want {
+			t.Errorf("Mutate() ordinal %d mounts = %v, want propagation %s", ordinal, mounts, want)
+		}
+	}
+}
+
+func TestMountHandler_GetParser_UnknownMountPropagation(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: MountVolume}: `{"volumes":[{"name":"csi","hostPath":{"path":"/var/lib/csi"}}],` +
+			`"containers":[{"name":"driver","volumeMounts":[{"name":"csi","mountPath":"/csi","mountPropagation":"Shared"}]}]}`,
+	}
+
+	_, err := (&MountHandler{}).GetParser().Parse(annotations)
+	if err == nil || !strings.Contains(err.Error(), `mountPropagation "Shared"`) {
+		t.Errorf("Parse() error = %v, want it to reject the propagation mode", err)
+	}
+}
+
 func Test_parserFunc_Parse(t *testing.T) {
 	type args struct {
 		annotations map[annotation.QualifiedName]string
@@ -247,7 +756,7 @@ func Test_parserFunc_Parse(t *testing.T) {
 					return string(b)
 				}(),
 			}},
-			want:    c,
+			want:    []*mountConfig{c},
 			wantErr: false,
 		},
 		{
@@ -259,7 +768,7 @@ func Test_parserFunc_Parse(t *testing.T) {
 					Name:      MountVolume,
 				}: "{\"volumes\":[{\"name\": \"my-volume\", \"configMap\":{\"name\":\"my-configmap\"}}],\"containers\":[{\"name\":\"nginx\", \"volumeMounts\":[{\"name\":\"my-volume\",\"mountPath\":\"/etc/configmaps/my-volume\"}]}]}",
 			}},
-			want: &mountConfig{
+			want: []*mountConfig{{
 				qualifier: "1-2",
 				cfg: &mountConfigValue{
 					Volumes: []v1.Volume{
@@ -286,9 +795,40 @@ func Test_parserFunc_Parse(t *testing.T) {
 						},
 					},
 				},
-			},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "yaml",
+			p:    volumeMountParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{
+					Qualifier: "1-2",
+					Name:      MountVolume,
+				}: `
+volumes:
+- name: dummy-vol
+  configMap:
+    name: dummy-configmap
+containers:
+- name: dummy-container
+  volumeMounts:
+  - name: dummy-vol
+    mountPath: /etc/configmaps/dummy
+`,
+			}},
+			want:    []*mountConfig{c},
 			wantErr: false,
 		},
+		{
+			name: "invalid yaml",
+			p:    volumeMountParser,
+			args: args{annotations: map[annotation.QualifiedName]string{
+				{Name: MountVolume}: "volumes: [",
+			}},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -303,3 +843,87 @@ func Test_parserFunc_Parse(t *testing.T) {
 		})
 	}
 }
+
+func TestMountHandler_GetParser_UnknownOverride(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: MountVolume}: `{"volumes":[{"name":"data","emptyDir":{}}],` +
+			`"overrides":[{"qualifier":"0","volumes":[{"name":"cache","emptyDir":{}}]}]}`,
+	}
+
+	_, err := (&MountHandler{}).GetParser().Parse(annotations)
+	if err == nil || !strings.Contains(err.Error(), `unknown volume "cache"`) {
+		t.Errorf("Parse() error = %v, want it to name the unknown volume", err)
+	}
+}
+
+func TestMountHandler_GetParser_Strict(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Name: MountVolume}: "volumes:\n- name: v\n  secret:\n    secretName: s\ncontainers:\n- name: c\n  volumeMounts:\n  - name: v\n    mountPth: /v\n",
+	}
+
+	// Lenient parsing ignores the misspelled field
+	got, err := (&MountHandler{}).GetParser().Parse(annotations)
+	if err != nil {
+		t.Fatalf("lenient Parse() error = %v", err)
+	}
+	if mount := got.([]*mountConfig)[0].cfg.Containers[0].VolumeMounts[0]; mount.MountPath != "" {
+		t.Errorf("lenient Parse() mount = %+v", mount)
+	}
+
+	// Strict parsing names the annotation and the unknown field
+	_, err = (&MountHandler{Strict: true}).GetParser().Parse(annotations)
+	if err == nil {
+		t.Fatal("strict Parse() expected an error")
+	}
+	for _, want := range []string{"spoditor.io/mount-volume", `"mountPth"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("strict Parse() error = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestMountHandler_GetParser_Deterministic(t *testing.T) {
+	annotations := map[annotation.QualifiedName]string{
+		{Qualifier: "0-2", Name: MountVolume}: `{"volumes":[{"name":"config","configMap":{"name":"low"}}]}`,
+		{Qualifier: "3-5", Name: MountVolume}: `{"volumes":[{"name":"config","configMap":{"name":"high"}}]}`,
+	}
+
+	p := (&MountHandler{}).GetParser()
+	want, err := p.Parse(annotations)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		got, err := p.Parse(annotations)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Parse() run %d got = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestMountHandler_GetParser_ParseError(t *testing.T) {
+	k := annotation.QualifiedName{Qualifier: "1", Name: MountVolume}
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "malformed value", value: `{"volumes":`},
+		{name: "invalid configuration", value: `{"volumes":[{"name":"data","emptyDir":{}}],` +
+			`"overrides":[{"qualifier":"0","volumes":[{"name":"cache","emptyDir":{}}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&MountHandler{}).GetParser().Parse(map[annotation.QualifiedName]string{k: tt.value})
+			var perr *annotation.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("Parse() error = %v, want a *annotation.ParseError", err)
+			}
+			if perr.Name != k || perr.Value != tt.value || perr.Err == nil {
+				t.Errorf("Parse() error = %+v, want it to carry %v and its value", perr, k)
+			}
+		})
+	}
+}
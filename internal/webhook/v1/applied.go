@@ -0,0 +1,201 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Applied is the pod annotation name, under the collector's prefix, recording
+// what the webhook added to the pod spec, e.g. spoditor.io/applied
+const Applied = "applied"
+
+// appliedRecord lists the pod spec entries the handlers added on top of the
+// pod as submitted. On re-admission the entries are removed again before the
+// handlers run, so a changed configuration replaces what the previous one
+// added instead of appending to it. Fields the handlers overwrite in place,
+// such as the host port of a declared port, are simply set again.
+type appliedRecord struct {
+	Volumes          []string                   `json:"volumes,omitempty"`
+	InitContainers   []string                   `json:"initContainers,omitempty"`
+	Containers       []string                   `json:"containers,omitempty"`
+	ContainerItems   map[string]*containerItems `json:"containerItems,omitempty"`
+	Tolerations      []corev1.Toleration        `json:"tolerations,omitempty"`
+	HostAliases      []corev1.HostAlias         `json:"hostAliases,omitempty"`
+	ImagePullSecrets []string                   `json:"imagePullSecrets,omitempty"`
+}
+
+// containerItems lists the entries added to a container of the submitted pod
+type containerItems struct {
+	Env          []string `json:"env,omitempty"`
+	VolumeMounts []string `json:"volumeMounts,omitempty"`
+	Ports        []string `json:"ports,omitempty"`
+}
+
+// isEmpty reports whether nothing was added to the container
+func (c *containerItems) isEmpty() bool {
+	return len(c.Env) == 0 && len(c.VolumeMounts) == 0 && len(c.Ports) == 0
+}
+
+// isEmpty reports whether nothing was added to the pod spec
+func (r *appliedRecord) isEmpty() bool {
+	return len(r.Volumes) == 0 && len(r.InitContainers) == 0 && len(r.Containers) == 0 &&
+		len(r.ContainerItems) == 0 && len(r.Tolerations) == 0 && len(r.HostAliases) == 0 &&
+		len(r.ImagePullSecrets) == 0
+}
+
+// portKey identifies a container port by number and protocol
+func portKey(p corev1.ContainerPort) string {
+	protocol := p.Protocol
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	return fmt.Sprintf("%d/%s", p.ContainerPort, protocol)
+}
+
+// recordAdditions compares the pod spec before and after the handlers ran and
+// records the entries that were added
+func recordAdditions(before, after *corev1.PodSpec) *appliedRecord {
+	r := &appliedRecord{ContainerItems: map[string]*containerItems{}}
+
+	r.Volumes = added(before.Volumes, after.Volumes, func(v corev1.Volume) string { return v.Name })
+	r.InitContainers = added(before.InitContainers, after.InitContainers, func(c corev1.Container) string { return c.Name })
+	r.Containers = added(before.Containers, after.Containers, func(c corev1.Container) string { return c.Name })
+	r.ImagePullSecrets = added(before.ImagePullSecrets, after.ImagePullSecrets, func(s corev1.LocalObjectReference) string { return s.Name })
+
+	for _, pair := range [][2][]corev1.Container{
+		{before.InitContainers, after.InitContainers},
+		{before.Containers, after.Containers},
+	} {
+		for _, prev := range pair[0] {
+			i := slices.IndexFunc(pair[1], func(c corev1.Container) bool { return c.Name == prev.Name })
+			if i < 0 {
+				continue
+			}
+			curr := pair[1][i]
+			items := &containerItems{
+				Env:          added(prev.Env, curr.Env, func(e corev1.EnvVar) string { return e.Name }),
+				VolumeMounts: added(prev.VolumeMounts, curr.VolumeMounts, func(m corev1.VolumeMount) string { return m.MountPath }),
+				Ports:        added(prev.Ports, curr.Ports, portKey),
+			}
+			if !items.isEmpty() {
+				r.ContainerItems[prev.Name] = items
+			}
+		}
+	}
+
+	for _, t := range after.Tolerations {
+		if !slices.ContainsFunc(before.Tolerations, func(o corev1.Toleration) bool { return o.MatchToleration(&t) }) {
+			r.Tolerations = append(r.Tolerations, t)
+		}
+	}
+
+	for _, a := range after.HostAliases {
+		var hostnames []string
+		i := slices.IndexFunc(before.HostAliases, func(o corev1.HostAlias) bool { return o.IP == a.IP })
+		for _, h := range a.Hostnames {
+			if i < 0 || !slices.Contains(before.HostAliases[i].Hostnames, h) {
+				hostnames = append(hostnames, h)
+			}
+		}
+		if len(hostnames) > 0 {
+			r.HostAliases = append(r.HostAliases, corev1.HostAlias{IP: a.IP, Hostnames: hostnames})
+		}
+	}
+
+	if len(r.ContainerItems) == 0 {
+		r.ContainerItems = nil
+	}
+	return r
+}
+
+// added returns the keys of the items in after that aren't in before
+func added[T any](before, after []T, key func(T) string) []string {
+	var keys []string
+	for _, a := range after {
+		k := key(a)
+		if !slices.ContainsFunc(before, func(b T) bool { return key(b) == k }) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// strip removes the recorded entries from the pod spec, restoring it to what
+// was submitted before the webhook first mutated it
+func (r *appliedRecord) strip(spec *corev1.PodSpec) {
+	spec.Volumes = remove(spec.Volumes, r.Volumes, func(v corev1.Volume) string { return v.Name })
+	spec.InitContainers = remove(spec.InitContainers, r.InitContainers, func(c corev1.Container) string { return c.Name })
+	spec.Containers = remove(spec.Containers, r.Containers, func(c corev1.Container) string { return c.Name })
+	spec.ImagePullSecrets = remove(spec.ImagePullSecrets, r.ImagePullSecrets, func(s corev1.LocalObjectReference) string { return s.Name })
+
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			items, ok := r.ContainerItems[containers[i].Name]
+			if !ok {
+				continue
+			}
+			c := &containers[i]
+			c.Env = remove(c.Env, items.Env, func(e corev1.EnvVar) string { return e.Name })
+			c.VolumeMounts = remove(c.VolumeMounts, items.VolumeMounts, func(m corev1.VolumeMount) string { return m.MountPath })
+			c.Ports = remove(c.Ports, items.Ports, portKey)
+		}
+	}
+
+	spec.Tolerations = slices.DeleteFunc(spec.Tolerations, func(t corev1.Toleration) bool {
+		return slices.ContainsFunc(r.Tolerations, func(o corev1.Toleration) bool { return o.MatchToleration(&t) })
+	})
+	if len(spec.Tolerations) == 0 {
+		spec.Tolerations = nil
+	}
+
+	for _, a := range r.HostAliases {
+		i := slices.IndexFunc(spec.HostAliases, func(o corev1.HostAlias) bool { return o.IP == a.IP })
+		if i < 0 {
+			continue
+		}
+		hostnames := slices.DeleteFunc(spec.HostAliases[i].Hostnames, func(h string) bool {
+			return slices.Contains(a.Hostnames, h)
+		})
+		if len(hostnames) > 0 {
+			spec.HostAliases[i].Hostnames = hostnames
+			continue
+		}
+		// The webhook added the whole entry
+		spec.HostAliases = slices.Delete(spec.HostAliases, i, i+1)
+	}
+	if len(spec.HostAliases) == 0 {
+		spec.HostAliases = nil
+	}
+}
+
+// remove deletes the items whose key is listed in keys
+func remove[T any](items []T, keys []string, key func(T) string) []T {
+	if len(keys) == 0 {
+		return items
+	}
+	items = slices.DeleteFunc(items, func(item T) bool {
+		return slices.Contains(keys, key(item))
+	})
+	if len(items) == 0 {
+		return nil
+	}
+	return items
+}
+
+// parseAppliedRecord decodes the value of the applied annotation
+func parseAppliedRecord(v string) (*appliedRecord, error) {
+	r := &appliedRecord{}
+	if err := json.Unmarshal([]byte(v), r); err != nil {
+		return nil, fmt.Errorf("invalid applied record: %w", err)
+	}
+	return r, nil
+}
+
+// String encodes the record as the value of the applied annotation
+func (r *appliedRecord) String() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
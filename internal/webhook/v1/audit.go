@@ -0,0 +1,56 @@
+package v1
+
+import (
+	"strings"
+	"time"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// MutatedAt is the pod annotation name, under the collector's prefix,
+	// recording when the webhook last mutated the pod in RFC 3339 format,
+	// e.g. spoditor.io/mutated-at
+	MutatedAt = "mutated-at"
+	// MutatedBy is the pod annotation name, under the collector's prefix,
+	// listing the handlers applied by the last mutation, e.g.
+	// spoditor.io/mutated-by
+	MutatedBy = "mutated-by"
+)
+
+// webhookRecords are the annotations the webhook writes on the pods it
+// mutates, which are records rather than configuration
+var webhookRecords = []string{Applied, MutatedAt, MutatedBy}
+
+// deleteRecords removes the webhook's own records from the collected
+// annotations
+func deleteRecords(annotations map[annotation.QualifiedName]string) {
+	for _, name := range webhookRecords {
+		delete(annotations, annotation.QualifiedName{Name: name})
+	}
+}
+
+// stampMutation records on the pod when it was mutated and by which handlers.
+// A pod no handler applied to keeps no stale stamp from an earlier admission.
+// The time only moves forward when the mutation changed the spec, so
+// re-admitting a pod with the same configuration keeps the time of the
+// mutation that did.
+func (m *PodMutator) stampMutation(pod *corev1.Pod, applied []string, changed bool, now time.Time) {
+	atKey := annotation.KeyOf(m.collector, annotation.QualifiedName{Name: MutatedAt})
+	byKey := annotation.KeyOf(m.collector, annotation.QualifiedName{Name: MutatedBy})
+
+	if len(applied) == 0 {
+		delete(pod.Annotations, atKey)
+		delete(pod.Annotations, byKey)
+		return
+	}
+
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	if _, ok := pod.Annotations[atKey]; changed || !ok {
+		pod.Annotations[atKey] = now.UTC().Format(time.RFC3339)
+	}
+	pod.Annotations[byKey] = strings.Join(applied, ",")
+}
@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"sort"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"k8s.io/utils/lru"
+)
+
+// defaultConfigCacheSize bounds the number of parsed configurations kept
+const defaultConfigCacheSize = 1024
+
+// configCache remembers parsed handler configurations keyed by the content of
+// the annotations they were parsed from, so pods of the same StatefulSet don't
+// re-parse identical annotations on every admission. Cached configurations are
+// shared between admissions, so handlers must treat them as read-only.
+type configCache struct {
+	entries *lru.Cache
+}
+
+// configCacheKey identifies a configuration parsed by a handler from annotations
+// with a given digest
+type configCacheKey struct {
+	handler annotation.Handler
+	digest  [sha256.Size]byte
+}
+
+// newConfigCache creates a cache holding up to size parsed configurations
+func newConfigCache(size int) *configCache {
+	return &configCache{entries: lru.New(size)}
+}
+
+// digestAnnotations hashes the annotations in key order
+func digestAnnotations(annotations map[annotation.QualifiedName]string) [sha256.Size]byte {
+	keys := make([]string, 0, len(annotations))
+	values := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		key := k.Key()
+		keys = append(keys, key)
+		values[key] = v
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		// Separate entries with NUL, which can't appear in annotation keys
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(values[k]))
+		h.Write([]byte{0})
+	}
+
+	var digest [sha256.Size]byte
+	h.Sum(digest[:0])
+	return digest
+}
+
+// parse returns the configuration the handler parses from the annotations with
+// the given digest, parsing and caching it on a miss. Parse errors aren't
+// cached. A nil cache always parses.
+func (c *configCache) parse(h annotation.Handler, digest [sha256.Size]byte, annotations map[annotation.QualifiedName]string) (any, error) {
+	if c == nil {
+		return h.GetParser().Parse(annotations)
+	}
+
+	key := configCacheKey{handler: h, digest: digest}
+	if config, ok := c.entries.Get(key); ok {
+		return config, nil
+	}
+
+	config, err := h.GetParser().Parse(annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	c.entries.Add(key, config)
+	return config, nil
+}
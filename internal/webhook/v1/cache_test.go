@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/volumes"
+	"github.com/golem-base/spoditor/internal/identifier"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const cachedMountVolume = `{
+	"volumes": [{"name": "config-volume", "configMap": {"name": "test-config"}}],
+	"containers": [{"name": "test-container", "volumeMounts": [{"name": "config-volume", "mountPath": "/etc/config"}]}]
+}`
+
+var _ = Describe("Config cache", func() {
+	var (
+		cache   *configCache
+		handler annotation.Handler
+	)
+
+	BeforeEach(func() {
+		cache = newConfigCache(defaultConfigCacheSize)
+		handler = &volumes.MountHandler{}
+	})
+
+	annotationsWith := func(value string) map[annotation.QualifiedName]string {
+		return map[annotation.QualifiedName]string{{Name: volumes.MountVolume}: value}
+	}
+
+	It("Should return the same parsed object for identical annotations", func() {
+		first := annotationsWith(cachedMountVolume)
+		second := annotationsWith(cachedMountVolume)
+
+		a, err := cache.parse(handler, digestAnnotations(first), first)
+		Expect(err).NotTo(HaveOccurred())
+		b, err := cache.parse(handler, digestAnnotations(second), second)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(a).NotTo(BeNil())
+		Expect(reflect.ValueOf(b).Pointer()).To(Equal(reflect.ValueOf(a).Pointer()))
+	})
+
+	It("Should parse again when the annotation value changes", func() {
+		first := annotationsWith(cachedMountVolume)
+		changed := annotationsWith(`{"volumes":[{"name":"other","secret":{"secretName":"s"}}]}`)
+
+		a, err := cache.parse(handler, digestAnnotations(first), first)
+		Expect(err).NotTo(HaveOccurred())
+		b, err := cache.parse(handler, digestAnnotations(changed), changed)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(reflect.ValueOf(b).Pointer()).NotTo(Equal(reflect.ValueOf(a).Pointer()))
+	})
+
+	It("Should keep configurations of different handlers apart", func() {
+		annotations := annotationsWith(cachedMountVolume)
+		digest := digestAnnotations(annotations)
+
+		_, err := cache.parse(handler, digest, annotations)
+		Expect(err).NotTo(HaveOccurred())
+
+		other := &volumes.MountHandler{Strict: true}
+		config, err := cache.parse(other, digest, annotationsWith(`{"volumes":[{"name":"v","sekret":{}}]}`))
+		Expect(err).To(HaveOccurred())
+		Expect(config).To(BeNil())
+	})
+
+	It("Should not cache parse errors", func() {
+		annotations := annotationsWith(`{"volumes":`)
+		digest := digestAnnotations(annotations)
+
+		_, err := cache.parse(handler, digest, annotations)
+		Expect(err).To(HaveOccurred())
+		_, err = cache.parse(handler, digest, annotations)
+		Expect(err).To(HaveOccurred())
+		Expect(cache.entries.Len()).To(Equal(0))
+	})
+
+	It("Should not let mutated pods change the cached configuration", func() {
+		mutator := &PodMutator{
+			ssPodId:   identifier.LabelSSPodIdentifier,
+			collector: annotation.Collector,
+			handlers:  []annotation.Handler{handler},
+			cache:     cache,
+		}
+
+		for ordinal := 0; ordinal < 2; ordinal++ {
+			pod := newCachedPod(ordinal)
+			Expect(mutator.Default(context.Background(), pod)).To(Succeed())
+			// Tamper with what the pod got to catch state shared with the cache
+			pod.Spec.Volumes[0].ConfigMap.Name = "tampered"
+			pod.Spec.Containers[0].VolumeMounts[0].MountPath = "/tampered"
+		}
+
+		third := newCachedPod(2)
+		Expect(mutator.Default(context.Background(), third)).To(Succeed())
+		Expect(third.Spec.Volumes[0].ConfigMap.Name).To(Equal("test-config-2"))
+		Expect(third.Spec.Containers[0].VolumeMounts[0].MountPath).To(Equal("/etc/config"))
+		Expect(cache.entries.Len()).To(Equal(1))
+	})
+})
+
+// newCachedPod creates a StatefulSet pod annotated with cachedMountVolume
+func newCachedPod(ordinal int) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("test-statefulset-%d", ordinal),
+			Namespace: "default",
+			Labels: map[string]string{
+				"statefulset.kubernetes.io/pod-name": fmt.Sprintf("test-statefulset-%d", ordinal),
+			},
+			Annotations: map[string]string{
+				"spoditor.io/mount-volume": cachedMountVolume,
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "test-container", Image: "nginx"}},
+		},
+	}
+}
+
+func BenchmarkApplyHandlers(b *testing.B) {
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cached=%t", cached), func(b *testing.B) {
+			mutator := &PodMutator{
+				ssPodId:   identifier.LabelSSPodIdentifier,
+				collector: annotation.Collector,
+				handlers:  builtinHandlers(),
+			}
+			if cached {
+				mutator.cache = newConfigCache(defaultConfigCacheSize)
+			}
+			pod := newCachedPod(1)
+			pod.Annotations["spoditor.io/host-port"] = `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`
+			pod.Annotations["spoditor.io/tolerations_1-"] = `[{"key":"pool","operator":"Exists"}]`
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := mutator.Default(context.Background(), pod.DeepCopy()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package v1
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// sortHandlers returns the handlers in the order they run: ascending priority,
+// keeping registration order among handlers of equal priority
+func sortHandlers(handlers []annotation.Handler) []annotation.Handler {
+	sorted := slices.Clone(handlers)
+	slices.SortStableFunc(sorted, func(a, b annotation.Handler) int {
+		return cmp.Compare(annotation.HandlerPriority(a), annotation.HandlerPriority(b))
+	})
+	return sorted
+}
+
+// fieldConflict describes a field changed by a handler after an earlier handler
+// had already set it
+type fieldConflict struct {
+	Field    string
+	Previous string
+	Handler  string
+}
+
+// fieldOwners tracks which handler last changed each watched field of a pod
+type fieldOwners map[string]string
+
+// watchedFields snapshots the pod spec fields handlers are known to contend
+// for, keyed by their field path
+func watchedFields(spec *corev1.PodSpec) map[string]string {
+	fields := make(map[string]string)
+	add := func(path string, containers []corev1.Container) {
+		for _, c := range containers {
+			for _, p := range c.Ports {
+				protocol := p.Protocol
+				if protocol == "" {
+					protocol = corev1.ProtocolTCP
+				}
+				key := fmt.Sprintf("%s[%s].ports[%d/%s].hostPort", path, c.Name, p.ContainerPort, protocol)
+				fields[key] = fmt.Sprint(p.HostPort)
+			}
+		}
+	}
+	add("spec.initContainers", spec.InitContainers)
+	add("spec.containers", spec.Containers)
+	return fields
+}
+
+// record attributes the fields that differ between before and after to handler
+// and returns those previously changed by another handler
+func (o fieldOwners) record(handler string, before, after map[string]string) []fieldConflict {
+	var conflicts []fieldConflict
+	for field, value := range after {
+		if old, ok := before[field]; ok && old == value {
+			continue
+		}
+		if previous, ok := o[field]; ok && previous != handler {
+			conflicts = append(conflicts, fieldConflict{Field: field, Previous: previous, Handler: handler})
+		}
+		o[field] = handler
+	}
+	slices.SortFunc(conflicts, func(a, b fieldConflict) int {
+		return cmp.Compare(a.Field, b.Field)
+	})
+	return conflicts
+}
@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// RequireFullCoverage is the StatefulSet annotation name, under the
+// collector's prefix, asking the validating webhook to reject pod template
+// annotations leaving some ordinals of the StatefulSet without a
+// configuration, e.g. spoditor.io/require-full-coverage: "true" for every
+// feature used, or spoditor.io/require-full-coverage: mount-volume,host-port
+// for the listed ones
+const RequireFullCoverage = "require-full-coverage"
+
+// validateCoverage checks that the annotations of every feature asked for by
+// the RequireFullCoverage annotation together cover all ordinals of the
+// StatefulSet. Qualifier sets are resolved with sets.
+func (v *StatefulSetValidator) validateCoverage(sts *appsv1.StatefulSet, annotations map[annotation.QualifiedName]string, sets map[string]string) field.ErrorList {
+	key := annotation.KeyOf(v.collector, annotation.QualifiedName{Name: RequireFullCoverage})
+	value, ok := sts.Annotations[key]
+	if !ok {
+		return nil
+	}
+	path := field.NewPath("metadata", "annotations").Key(key)
+
+	if value == "" {
+		return nil
+	}
+
+	// A boolean, spelled as strconv.ParseBool accepts it, covers every feature
+	// used or none, anything else lists the features to cover
+	var features []string
+	if all, err := strconv.ParseBool(value); err == nil {
+		if !all {
+			return nil
+		}
+		for k := range annotations {
+			if !slices.Contains(features, k.Name) {
+				features = append(features, k.Name)
+			}
+		}
+		slices.Sort(features)
+	} else {
+		for _, f := range strings.Split(value, ",") {
+			features = append(features, strings.TrimSpace(f))
+		}
+	}
+
+	// Ordinals run from the start ordinal over all replicas, one by default
+	start, replicas := 0, 1
+	if sts.Spec.Ordinals != nil {
+		start = int(sts.Spec.Ordinals.Start)
+	}
+	if sts.Spec.Replicas != nil {
+		replicas = int(*sts.Spec.Replicas)
+	}
+
+	var errs field.ErrorList
+	for _, feature := range features {
+		var uncovered []string
+		for ordinal := start; ordinal < start+replicas; ordinal++ {
+			mc := annotation.MutationContext{Ordinal: ordinal, SSName: sts.Name, StatefulSet: sts}
+			if !covers(annotations, sets, feature, mc) {
+				uncovered = append(uncovered, strconv.Itoa(ordinal))
+			}
+		}
+		if len(uncovered) > 0 {
+			errs = append(errs, field.Invalid(path, value,
+				fmt.Sprintf("no %q annotation covers ordinals %s", feature, strings.Join(uncovered, ","))))
+		}
+	}
+	return errs
+}
+
+// covers reports whether an annotation of the feature applies to the ordinal
+func covers(annotations map[annotation.QualifiedName]string, sets map[string]string, feature string, mc annotation.MutationContext) bool {
+	for k := range annotations {
+		if k.Name != feature {
+			continue
+		}
+		qualifier := k.Qualifier
+		if resolved, ok := sets[qualifier]; ok {
+			qualifier = resolved
+		}
+		if mc.Selects(qualifier) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package v1
+
+import (
+	"context"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultMutationQueueTimeout is how long a mutation waits for a free slot
+// before it is turned away
+const defaultMutationQueueTimeout = 5 * time.Second
+
+// concurrencyLimiter bounds the number of pods mutated at once. Mutations over
+// the limit queue for a free slot, and are rejected with a retryable error when
+// none frees up in time, so create storms don't overwhelm the webhook.
+type concurrencyLimiter struct {
+	slots   chan struct{}
+	timeout time.Duration
+}
+
+// newConcurrencyLimiter creates a limiter allowing max concurrent mutations,
+// each waiting up to timeout for a slot. A non-positive max disables limiting.
+func newConcurrencyLimiter(max int, timeout time.Duration) *concurrencyLimiter {
+	if max <= 0 {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultMutationQueueTimeout
+	}
+	return &concurrencyLimiter{
+		slots:   make(chan struct{}, max),
+		timeout: timeout,
+	}
+}
+
+// acquire waits for a free slot and returns the function releasing it. It
+// fails with a TooManyRequests error when no slot frees up before the timeout
+// or the context is done. A nil limiter never blocks.
+func (c *concurrencyLimiter) acquire(ctx context.Context) (func(), error) {
+	if c == nil {
+		return func() {}, nil
+	}
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case c.slots <- struct{}{}:
+		return func() { <-c.slots }, nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	retryAfter := int(c.timeout / time.Second)
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	return nil, apierrors.NewTooManyRequests("too many concurrent pod mutations, please retry", retryAfter)
+}
@@ -0,0 +1,135 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/identifier"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Concurrency limiter", func() {
+	var (
+		handler *blockingHandler
+		mutator *PodMutator
+	)
+
+	newPod := func(ordinal int) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("test-statefulset-%d", ordinal),
+				Namespace: "default",
+				Labels: map[string]string{
+					"statefulset.kubernetes.io/pod-name": fmt.Sprintf("test-statefulset-%d", ordinal),
+				},
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test-container"}}},
+		}
+	}
+
+	BeforeEach(func() {
+		handler = &blockingHandler{
+			entered: make(chan struct{}, 10),
+			release: make(chan struct{}),
+		}
+		mutator = &PodMutator{
+			ssPodId:   identifier.LabelSSPodIdentifier,
+			collector: annotation.Collector,
+			handlers:  []annotation.Handler{handler},
+			limiter:   newConcurrencyLimiter(2, 50*time.Millisecond),
+		}
+	})
+
+	It("Should reject mutations over the limit with a retryable error", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(ordinal int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(mutator.Default(context.Background(), newPod(ordinal))).To(Succeed())
+			}(i)
+		}
+		Eventually(handler.entered).Should(Receive())
+		Eventually(handler.entered).Should(Receive())
+
+		// Both slots are taken, the third pod times out in the queue
+		err := mutator.Default(context.Background(), newPod(2))
+		Expect(apierrors.IsTooManyRequests(err)).To(BeTrue())
+		_, retry := apierrors.SuggestsClientDelay(err)
+		Expect(retry).To(BeTrue())
+
+		close(handler.release)
+		wg.Wait()
+
+		Expect(mutator.Default(context.Background(), newPod(3))).To(Succeed())
+		Expect(handler.maxActive.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("Should let queued mutations through once a slot frees up", func() {
+		mutator.limiter = newConcurrencyLimiter(1, 5*time.Second)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(ordinal int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(mutator.Default(context.Background(), newPod(ordinal))).To(Succeed())
+			}(i)
+		}
+
+		Eventually(handler.entered).Should(Receive())
+		close(handler.release)
+		wg.Wait()
+
+		Expect(handler.calls.Load()).To(BeEquivalentTo(5))
+		Expect(handler.maxActive.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("Should not limit mutations when disabled", func() {
+		Expect(newConcurrencyLimiter(0, time.Second)).To(BeNil())
+	})
+})
+
+// blockingHandler blocks each mutation until release is closed, tracking how
+// many mutations run at once
+type blockingHandler struct {
+	entered   chan struct{}
+	release   chan struct{}
+	active    atomic.Int32
+	maxActive atomic.Int32
+	calls     atomic.Int32
+}
+
+func (h *blockingHandler) Mutate(_ *corev1.PodSpec, _ annotation.MutationContext, _ any) error {
+	active := h.active.Add(1)
+	defer h.active.Add(-1)
+	for {
+		peak := h.maxActive.Load()
+		if active <= peak || h.maxActive.CompareAndSwap(peak, active) {
+			break
+		}
+	}
+	h.calls.Add(1)
+
+	h.entered <- struct{}{}
+	<-h.release
+	return nil
+}
+
+func (h *blockingHandler) GetParser() annotation.Parser {
+	return annotation.ParserFunc(func(map[annotation.QualifiedName]string) (any, error) {
+		return struct{}{}, nil
+	})
+}
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/identifier"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadHandlerConfig reads a handler config file for local development. The
+// file is a YAML or JSON object mapping annotation keys without their prefix,
+// e.g. "mount-volume" or "host-port_0", to annotation values, given either as
+// strings or as nested objects.
+func LoadHandlerConfig(path string) (map[annotation.QualifiedName]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]any{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid handler config %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if s, ok := v.(string); ok {
+			values[k] = s
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid handler config %s: key %s: %w", path, k, err)
+		}
+		values[k] = string(b)
+	}
+
+	// Split the keys into feature names and qualifiers the way annotations are
+	collector := &annotation.PrefixedCollector{}
+	configs := collector.Collect(&metav1.ObjectMeta{Annotations: values})
+	if len(configs) != len(values) {
+		return nil, fmt.Errorf("invalid handler config %s: malformed keys", path)
+	}
+	return configs, nil
+}
+
+// ApplyHandlerConfig mutates a StatefulSet pod with the given handler
+// configs, as loaded by LoadHandlerConfig, without a cluster. The pod's own
+// annotations take precedence over the configs. Only the named handlers are
+// enabled; an empty list enables all built-in handlers. It returns the names
+// of the applied handlers.
+func ApplyHandlerConfig(pod *corev1.Pod, configs map[annotation.QualifiedName]string, enabled []string) ([]string, error) {
+	handlers, err := enabledHandlers(enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	ss, ordinal, err := identifier.LabelSSPodIdentifier.Extract(pod)
+	if err != nil {
+		return nil, fmt.Errorf("not a StatefulSet pod: %w", err)
+	}
+
+	annotations := make(map[annotation.QualifiedName]string, len(configs))
+	for k, v := range configs {
+		annotations[k] = v
+	}
+	for k, v := range annotation.Collector.Collect(pod) {
+		annotations[k] = v
+	}
+	deleteRecords(annotations)
+
+	m := &PodMutator{
+		ssPodId:   identifier.LabelSSPodIdentifier,
+		collector: annotation.Collector,
+		handlers:  handlers,
+	}
+	l := podlog.WithValues("name", pod.Name, "statefulset", ss, "ordinal", ordinal)
+	return m.applyHandlers(pod, annotation.MutationContext{Ordinal: ordinal, SSName: ss}, annotations, false, l)
+}
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/deadline"
+	"github.com/golem-base/spoditor/internal/annotation/ports"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Handler config file", func() {
+	var path string
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "handlers.yaml")
+		Expect(os.WriteFile(path, []byte(`
+host-port_2:
+  containers:
+  - name: test-container
+    ports:
+    - name: http
+      containerPort: 8080
+      hostPort: 30000
+active-deadline: '{"base":600}'
+`), 0o600)).To(Succeed())
+	})
+
+	fixture := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-statefulset-2",
+				Namespace: "default",
+				Labels: map[string]string{
+					"statefulset.kubernetes.io/pod-name": "test-statefulset-2",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "test-container", Image: "nginx"}},
+			},
+		}
+	}
+
+	It("Should load nested and string values by qualified name", func() {
+		configs, err := LoadHandlerConfig(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configs).To(HaveLen(2))
+		Expect(configs).To(HaveKey(annotation.QualifiedName{Name: ports.HostPort, Qualifier: "2"}))
+		Expect(configs).To(HaveKeyWithValue(annotation.QualifiedName{Name: deadline.ActiveDeadline}, `{"base":600}`))
+	})
+
+	It("Should reject malformed keys", func() {
+		Expect(os.WriteFile(path, []byte(`host-port_: '{}'`), 0o600)).To(Succeed())
+		_, err := LoadHandlerConfig(path)
+		Expect(err).To(MatchError(ContainSubstring("malformed keys")))
+	})
+
+	It("Should apply the configs to a fixture pod", func() {
+		configs, err := LoadHandlerConfig(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		pod := fixture()
+		applied, err := ApplyHandlerConfig(pod, configs, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(ConsistOf(ports.HostPort, deadline.ActiveDeadline))
+		Expect(pod.Spec.Containers[0].Ports).To(ConsistOf(corev1.ContainerPort{
+			Name: "http", ContainerPort: 8080, HostPort: 30002,
+		}))
+		Expect(pod.Spec.ActiveDeadlineSeconds).NotTo(BeNil())
+		Expect(*pod.Spec.ActiveDeadlineSeconds).To(BeEquivalentTo(600))
+	})
+
+	It("Should prefer the pod's own annotations", func() {
+		configs, err := LoadHandlerConfig(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		pod := fixture()
+		pod.Annotations = map[string]string{"spoditor.io/active-deadline": `{"base":60}`}
+		_, err = ApplyHandlerConfig(pod, configs, []string{deadline.ActiveDeadline})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*pod.Spec.ActiveDeadlineSeconds).To(BeEquivalentTo(60))
+		Expect(pod.Spec.Containers[0].Ports).To(BeEmpty())
+	})
+})
@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Reasons a pod is admitted without being mutated
+const (
+	// ignoreNoLabel is a pod without the StatefulSet pod name label
+	ignoreNoLabel = "no_label"
+	// ignoreNotStatefulSet is a pod whose labels don't identify a StatefulSet pod
+	ignoreNotStatefulSet = "not_statefulset"
+	// ignoreNoAnnotations is a StatefulSet pod without spoditor annotations
+	ignoreNoAnnotations = "no_annotations"
+	// ignoreQualifierExcluded is a StatefulSet pod whose ordinal no annotation
+	// qualifier selects
+	ignoreQualifierExcluded = "qualifier_excluded"
+	// ignoreOrdinalTooLarge is a StatefulSet pod whose ordinal exceeds the
+	// configured maximum
+	ignoreOrdinalTooLarge = "ordinal_too_large"
+	// ignoreDisabled is a StatefulSet pod annotated to be left alone
+	ignoreDisabled = "disabled"
+	// ignoreImageNotAllowed is a StatefulSet pod whose primary container image
+	// matches no allowed pattern
+	ignoreImageNotAllowed = "image_not_allowed"
+)
+
+// Results of a handler run on a pod with a configuration for it
+const (
+	// resultChanged is a handler that modified the pod
+	resultChanged = "changed"
+	// resultSkipped is a handler that left the pod unchanged
+	resultSkipped = "skipped"
+)
+
+// podsIgnored counts the pods the webhook left alone, by reason
+var podsIgnored = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "spoditor_pods_ignored_total",
+		Help: "Number of pods admitted without mutation, by reason",
+	},
+	[]string{"reason"},
+)
+
+// handlerResults counts the handler runs on pods with a configuration for the
+// handler, by handler and result
+var handlerResults = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "spoditor_handler_results_total",
+		Help: "Number of handler runs on configured pods, by handler and result",
+	},
+	[]string{"handler", "result"},
+)
+
+// parseFailures counts the annotation values a handler failed to parse, by the
+// feature the handler is named after
+var parseFailures = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "spoditor_parse_failures_total",
+		Help: "Number of annotation parse failures, by feature",
+	},
+	[]string{"feature"},
+)
+
+func init() {
+	// Served on the manager's metrics endpoint
+	metrics.Registry.MustRegister(podsIgnored, handlerResults, parseFailures)
+}
@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/identifier"
+)
+
+// PodMutatorOptions configures the webhooks at setup time. The zero value
+// enables all built-in handlers with the default annotation prefix and
+// leaves every optional behavior off.
+type PodMutatorOptions struct {
+	// Enabled names the handlers to run; empty enables all built-in handlers
+	Enabled []string
+	// HandlersConfigMap, when set, names a ConfigMap whose handler list
+	// replaces Enabled
+	HandlersConfigMap types.NamespacedName
+	// Handlers are custom handlers run after the built-in ones
+	Handlers []annotation.Handler
+
+	// Collector reads the annotations, annotation.Collector when nil
+	Collector annotation.QualifiedAnnotationCollector
+	// Identifier tells the StatefulSet pods and their ordinals apart,
+	// identifier.LabelSSPodIdentifier when nil
+	Identifier identifier.SSPodIdentifier
+
+	// MaxConcurrent bounds the pods mutated at once, others waiting up to
+	// QueueTimeout for their turn; non-positive disables the limit
+	MaxConcurrent int
+	QueueTimeout  time.Duration
+
+	// The options below set the PodMutator fields of the same name
+	DryRun                 bool
+	MaxOrdinal             int
+	StatefulSetAnnotations bool
+	AllowedImages          []string
+	DefaultQualifier       string
+}
+
+// collector returns the configured collector or the default one.
+func (o PodMutatorOptions) collector() annotation.QualifiedAnnotationCollector {
+	if o.Collector == nil {
+		return annotation.Collector
+	}
+	return o.Collector
+}
+
+// handlers resolves the configured handlers, reading the handler ConfigMap
+// through reader if one is set.
+func (o PodMutatorOptions) handlers(ctx context.Context, reader client.Reader) ([]annotation.Handler, error) {
+	handlers, err := configuredHandlers(ctx, reader, o.HandlersConfigMap, o.Enabled)
+	if err != nil {
+		return nil, err
+	}
+	return withCustomHandlers(handlers, o.Handlers)
+}
+
+// NewPodMutator creates a PodMutator configured with opts, reading
+// StatefulSets and the handler ConfigMap through reader.
+func NewPodMutator(reader client.Reader, opts PodMutatorOptions) (*PodMutator, error) {
+	handlers, err := opts.handlers(context.Background(), reader)
+	if err != nil {
+		return nil, err
+	}
+	ssPodId := opts.Identifier
+	if ssPodId == nil {
+		ssPodId = identifier.LabelSSPodIdentifier
+	}
+
+	return &PodMutator{
+		ssPodId:                ssPodId,
+		collector:              opts.collector(),
+		handlers:               handlers,
+		client:                 reader,
+		cache:                  newConfigCache(defaultConfigCacheSize),
+		limiter:                newConcurrencyLimiter(opts.MaxConcurrent, opts.QueueTimeout),
+		DryRun:                 opts.DryRun,
+		MaxOrdinal:             opts.MaxOrdinal,
+		StatefulSetAnnotations: opts.StatefulSetAnnotations,
+		AllowedImages:          opts.AllowedImages,
+		DefaultQualifier:       opts.DefaultQualifier,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package v1
+
+import (
+	"context"
+	"time"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/ports"
+	"github.com/golem-base/spoditor/internal/annotation/volumes"
+	"github.com/golem-base/spoditor/internal/identifier"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("PodMutatorOptions", func() {
+	var reader = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+
+	newPod := func(podName string, annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      podName,
+				Namespace: "default",
+				Labels: map[string]string{
+					"statefulset.kubernetes.io/pod-name": podName,
+				},
+				Annotations: annotations,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "test-container", Image: "nginx"}},
+			},
+		}
+	}
+	hostPort := `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`
+
+	It("Should default to the current behavior", func() {
+		mutator, err := NewPodMutator(reader, PodMutatorOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mutator.handlers).To(HaveLen(len(builtinHandlers())))
+		Expect(mutator.collector).To(Equal(annotation.Collector))
+		Expect(mutator.client).To(Equal(reader))
+		Expect(mutator.cache).NotTo(BeNil())
+		Expect(mutator.limiter).To(BeNil())
+		Expect(mutator.DryRun).To(BeFalse())
+		Expect(mutator.MaxOrdinal).To(BeZero())
+
+		pod := newPod("test-statefulset-2", map[string]string{"spoditor.io/host-port": hostPort})
+		Expect(mutator.Default(context.Background(), pod)).To(Succeed())
+		Expect(pod.Spec.Containers[0].Ports).To(HaveLen(1))
+		Expect(pod.Spec.Containers[0].Ports[0].HostPort).To(Equal(int32(30002)))
+	})
+
+	It("Should apply non-default options", func() {
+		mutator, err := NewPodMutator(reader, PodMutatorOptions{
+			Enabled:          []string{ports.HostPort},
+			Collector:        &annotation.PrefixedCollector{Prefix: "example.com/", Separator: "_"},
+			MaxConcurrent:    2,
+			QueueTimeout:     time.Second,
+			MaxOrdinal:       3,
+			AllowedImages:    []string{"nginx*"},
+			DefaultQualifier: "0-2",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mutator.handlers).To(HaveLen(1))
+		Expect(annotation.HandlerName(mutator.handlers[0])).To(Equal(ports.HostPort))
+		Expect(mutator.limiter).NotTo(BeNil())
+		Expect(mutator.MaxOrdinal).To(Equal(3))
+		Expect(mutator.AllowedImages).To(Equal([]string{"nginx*"}))
+		Expect(mutator.DefaultQualifier).To(Equal("0-2"))
+
+		By("reading annotations with the custom prefix")
+		pod := newPod("test-statefulset-1", map[string]string{"example.com/host-port": hostPort})
+		Expect(mutator.Default(context.Background(), pod)).To(Succeed())
+		Expect(pod.Spec.Containers[0].Ports).To(HaveLen(1))
+		Expect(pod.Spec.Containers[0].Ports[0].HostPort).To(Equal(int32(30001)))
+
+		By("ignoring annotations with the default prefix")
+		pod = newPod("test-statefulset-1", map[string]string{"spoditor.io/host-port": hostPort})
+		Expect(mutator.Default(context.Background(), pod)).To(Succeed())
+		Expect(pod.Spec.Containers[0].Ports).To(BeEmpty())
+
+		By("leaving pods past the max ordinal untouched")
+		pod = newPod("test-statefulset-4", map[string]string{"example.com/host-port": hostPort})
+		Expect(mutator.Default(context.Background(), pod)).To(Succeed())
+		Expect(pod.Spec.Containers[0].Ports).To(BeEmpty())
+	})
+
+	It("Should leave pods untouched in dry-run", func() {
+		mutator, err := NewPodMutator(reader, PodMutatorOptions{DryRun: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		pod := newPod("test-statefulset-1", map[string]string{"spoditor.io/host-port": hostPort})
+		Expect(mutator.Default(context.Background(), pod)).To(Succeed())
+		Expect(pod.Spec.Containers[0].Ports).To(BeEmpty())
+	})
+
+	It("Should identify pods with a custom identifier", func() {
+		mutator, err := NewPodMutator(reader, PodMutatorOptions{
+			Identifier: identifier.SSPodIdentifierFunc(func(metav1.ObjectMetaAccessor) (string, int, error) {
+				return "custom", 5, nil
+			}),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		pod := newPod("test-statefulset-1", map[string]string{"spoditor.io/host-port": hostPort})
+		Expect(mutator.Default(context.Background(), pod)).To(Succeed())
+		Expect(pod.Spec.Containers[0].Ports).To(HaveLen(1))
+		Expect(pod.Spec.Containers[0].Ports[0].HostPort).To(Equal(int32(30005)))
+	})
+
+	It("Should append custom handlers and reject clashing ones", func() {
+		mutator, err := NewPodMutator(reader, PodMutatorOptions{
+			Enabled:  []string{volumes.MountVolume},
+			Handlers: []annotation.Handler{&panickingHandler{}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mutator.handlers).To(HaveLen(2))
+
+		_, err = NewPodMutator(reader, PodMutatorOptions{
+			Handlers: []annotation.Handler{&ports.HostPortHandler{}},
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
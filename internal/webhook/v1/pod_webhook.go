@@ -2,52 +2,108 @@ package v1
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/golem-base/spoditor/internal/annotation"
-	"github.com/golem-base/spoditor/internal/annotation/ports"
-	"github.com/golem-base/spoditor/internal/annotation/volumes"
 	"github.com/golem-base/spoditor/internal/identifier"
+	"gomodules.xyz/jsonpatch/v2"
 
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 // log is for logging in this package.
 var podlog = logf.Log.WithName("pod-webhook")
 
-// SetupPodWebhookWithManager registers the webhook for Pod in the manager.
-func SetupPodWebhookWithManager(mgr ctrl.Manager) error {
+// SetupPodWebhookWithManager registers the webhook for Pod in the manager,
+// configured with opts.
+func SetupPodWebhookWithManager(mgr ctrl.Manager, opts PodMutatorOptions) error {
 	podlog.Info("Setting up pod mutating webhook")
 
-	// Create a new Pod mutator
-	mutator := &PodMutator{
-		ssPodId:   identifier.LabelSSPodIdentifier,
-		collector: annotation.Collector,
-		handlers: []annotation.Handler{
-			&volumes.MountHandler{},
-			&ports.HostPortHandler{},
-		},
-	}
-
-	// Set up the webhook server
-	return ctrl.NewWebhookManagedBy(mgr).
-		For(&corev1.Pod{}).
-		WithDefaulter(mutator).
-		Complete()
+	// Read StatefulSets straight from the API server so no cache or watch is needed
+	mutator, err := NewPodMutator(mgr.GetAPIReader(), opts)
+	if err != nil {
+		return err
+	}
+
+	// Report a misconfigured handler list through readiness at boot
+	if err := mgr.AddReadyzCheck(handlersCheckName, mutator.checkHandlers); err != nil {
+		return err
+	}
+
+	// Set up the webhook server, summarizing the applied mutations in the
+	// admission responses
+	mgr.GetWebhookServer().Register(podMutatePath, newPodWebhook(mgr.GetScheme(), mutator))
+	return nil
 }
 
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get
 //+kubebuilder:webhook:path=/mutate--v1-pod,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create;update,versions=v1,name=mpod.spoditor.io,admissionReviewVersions=v1
 
+// Disabled is the pod annotation name, under the collector's prefix, which
+// when "true" makes the webhook leave the pod alone whatever else it is
+// annotated with, e.g. spoditor.io/disabled: "true" on a pod template while
+// debugging its StatefulSet
+const Disabled = "disabled"
+
 // PodMutator mutates Pods
 type PodMutator struct {
 	ssPodId   identifier.SSPodIdentifier
 	handlers  []annotation.Handler
 	collector annotation.QualifiedAnnotationCollector
+	// client reads the pod's StatefulSet for its qualifier sets, if set
+	client client.Reader
+	// cache holds parsed configurations, if set
+	cache *configCache
+	// limiter bounds concurrent mutations, if set
+	limiter *concurrencyLimiter
+
+	// OnMutate, when set, is called after each successful mutation with the
+	// mutated pod, its ordinal and the names of the handlers that were applied
+	OnMutate func(pod *corev1.Pod, ordinal int, applied []string)
+
+	// DryRun computes mutations against a copy of the pod and logs the JSON
+	// patch they would produce, leaving the admitted pod untouched
+	DryRun bool
+
+	// MaxOrdinal, when positive, bounds the ordinals of the pods mutated.
+	// Pods above it, e.g. from a misconfigured StatefulSet or a spoofed pod
+	// name, are left untouched rather than fed to ordinal arithmetic.
+	MaxOrdinal int
+
+	// StatefulSetAnnotations also applies the spoditor annotations on the
+	// owning StatefulSet itself, for users annotating it rather than its pod
+	// template. The pod's own annotations take precedence.
+	StatefulSetAnnotations bool
+
+	// AllowedImages, when set, limits mutation to pods whose primary
+	// container, the first one, runs an image matching one of the patterns,
+	// in which * matches any run of characters, e.g. registry.example.com/*
+	AllowedImages []string
+
+	// DefaultQualifier, when set, is the qualifier of unqualified annotations,
+	// e.g. "0-2" for them to select the first three pods only. Explicitly
+	// qualified annotations keep their own qualifier.
+	DefaultQualifier string
 }
 
 var _ webhook.CustomDefaulter = &PodMutator{}
@@ -69,35 +125,385 @@ func (m *PodMutator) Default(ctx context.Context, obj runtime.Object) error {
 	ss, ordinal, err := m.ssPodId.Extract(pod)
 	if err != nil {
 		l.Info("Not a StatefulSet pod, skipping mutation", "error", err)
+		if errors.Is(err, identifier.ErrMissingLabel) {
+			podsIgnored.WithLabelValues(ignoreNoLabel).Inc()
+		} else {
+			podsIgnored.WithLabelValues(ignoreNotStatefulSet).Inc()
+		}
 		return nil
 	}
 
 	l = l.WithValues("statefulset", ss, "ordinal", ordinal)
 	l.Info("Found StatefulSet pod")
 
+	if m.disabled(pod) {
+		l.Info("Spoditor disabled for this pod, skipping mutation")
+		podsIgnored.WithLabelValues(ignoreDisabled).Inc()
+		return nil
+	}
+
+	if !m.imageAllowed(pod) {
+		l.Info("Primary container image not allowed, skipping mutation", "allowedImages", m.AllowedImages)
+		podsIgnored.WithLabelValues(ignoreImageNotAllowed).Inc()
+		return nil
+	}
+
+	if m.MaxOrdinal > 0 && ordinal > m.MaxOrdinal {
+		// Logged as an error so it stands out, the pod is still admitted
+		l.Error(nil, "Ordinal exceeds the maximum, skipping mutation", "maxOrdinal", m.MaxOrdinal)
+		podsIgnored.WithLabelValues(ignoreOrdinalTooLarge).Inc()
+		return nil
+	}
+
+	// Wait for a free slot, turning the pod away with a retryable error when
+	// the webhook stays saturated
+	release, err := m.limiter.acquire(ctx)
+	if err != nil {
+		l.Info("Too many concurrent mutations, rejecting pod")
+		return err
+	}
+	defer release()
+
+	// Read the owning StatefulSet once, for its qualifier sets and for the
+	// handlers needing it
+	sts := m.statefulSet(ctx, pod, ss, l)
+
+	// Collect annotations once for all handlers, resolving named qualifier sets
+	update := updating(ctx)
+	sets := m.qualifierSets(sts, l)
+	annotations := m.collector.Collect(pod)
+	for k, v := range m.statefulSetAnnotations(sts, update) {
+		if _, ok := annotations[k]; !ok {
+			annotations[k] = v
+		}
+	}
+	if resolved, err := annotation.ResolveQualifierSets(annotations, sets); err != nil {
+		l.Error(err, "Ignoring conflicting qualifier sets")
+	} else {
+		annotations = resolved
+	}
+	annotations = annotation.ApplyDefaultQualifier(annotations, m.DefaultQualifier)
+	// The records of a previous mutation aren't configuration, nor is the
+	// switch turning mutation off
+	deleteRecords(annotations)
+	delete(annotations, annotation.QualifiedName{Name: Disabled})
+
+	mc := annotation.MutationContext{Ordinal: ordinal, SSName: ss, StatefulSet: sts}
+
+	// Handlers still run without matching annotations, undoing what an
+	// earlier configuration added
+	if reason := ignoreReason(annotations, mc); reason != "" {
+		l.Info("No annotation applies to this pod", "reason", reason)
+		podsIgnored.WithLabelValues(reason).Inc()
+	}
+
+	// In dry-run mode the handlers mutate a copy, so the real pod is untouched
+	target := pod
+	if m.DryRun {
+		target = pod.DeepCopy()
+	}
+	incoming := pod.Spec.DeepCopy()
+
 	// Apply all registered handlers
-	if err := m.applyHandlers(pod, ordinal, l); err != nil {
+	applied, err := m.applyHandlers(target, mc, annotations, update, l)
+	if err != nil {
 		l.Error(err, "Failed to apply handlers")
 		return err
 	}
 
-	l.Info("Successfully processed pod")
+	if m.DryRun {
+		patch, err := mutationPatch(pod, target)
+		if err != nil {
+			l.Error(err, "Failed to compute dry-run patch")
+			return nil
+		}
+		l.Info("Dry run, leaving pod unchanged", "applied", applied, "patch", patch)
+		setSummary(ctx, summarize(applied, ordinal, true))
+		return nil
+	}
+
+	// The stamps record the mutation of the spec, which updates leave alone
+	if !update {
+		m.stampMutation(pod, applied, !equality.Semantic.DeepEqual(incoming, &pod.Spec), time.Now())
+	}
+	l.Info("Successfully processed pod", "applied", applied)
+	setSummary(ctx, summarize(applied, ordinal, false))
+	if m.OnMutate != nil {
+		m.OnMutate(pod, ordinal, applied)
+	}
 	return nil
 }
 
-// applyHandlers processes all registered handlers against the pod
-func (m *PodMutator) applyHandlers(pod *corev1.Pod, ordinal int, ll logr.Logger) error {
-	// Collect annotations once for all handlers
-	annotations := m.collector.Collect(pod)
+// updating reports whether the pod is admitted for an update rather than for
+// its creation
+func updating(ctx context.Context) bool {
+	req, err := admission.RequestFromContext(ctx)
+	return err == nil && req.Operation == admissionv1.Update
+}
+
+// disabled reports whether the pod asks to be left alone
+func (m *PodMutator) disabled(pod *corev1.Pod) bool {
+	v, ok := pod.Annotations[annotation.KeyOf(m.collector, annotation.QualifiedName{Name: Disabled})]
+	if !ok {
+		return false
+	}
+	disabled, err := strconv.ParseBool(v)
+	return err == nil && disabled
+}
+
+// imageAllowed reports whether the image of the pod's primary container
+// matches an allowed pattern, or no patterns are configured
+func (m *PodMutator) imageAllowed(pod *corev1.Pod) bool {
+	if len(m.AllowedImages) == 0 {
+		return true
+	}
+	if len(pod.Spec.Containers) == 0 {
+		return false
+	}
+	image := pod.Spec.Containers[0].Image
+	return slices.ContainsFunc(m.AllowedImages, func(pattern string) bool {
+		return matchImage(pattern, image)
+	})
+}
+
+// matchImage reports whether image matches pattern, in which * matches any
+// run of characters, slashes and colons included
+func matchImage(pattern, image string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == image
+	}
+
+	// The image must start with the first part, end with the last one and
+	// contain the others in between, in order
+	if !strings.HasPrefix(image, parts[0]) {
+		return false
+	}
+	image = image[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(image, part)
+		if i < 0 {
+			return false
+		}
+		image = image[i+len(part):]
+	}
+	return len(image) >= len(last) && strings.HasSuffix(image, last)
+}
+
+// ignoreReason returns why none of the annotations applies to the pod being
+// mutated, or an empty string when some do
+func ignoreReason(annotations map[annotation.QualifiedName]string, mc annotation.MutationContext) string {
+	if len(annotations) == 0 {
+		return ignoreNoAnnotations
+	}
+	for k := range annotations {
+		if mc.Selects(k.Qualifier) {
+			return ""
+		}
+	}
+	return ignoreQualifierExcluded
+}
+
+// mutationPatch renders the JSON patch turning original into mutated
+func mutationPatch(original, mutated *corev1.Pod) (string, error) {
+	before, err := json.Marshal(original)
+	if err != nil {
+		return "", err
+	}
+	after, err := json.Marshal(mutated)
+	if err != nil {
+		return "", err
+	}
+	ops, err := jsonpatch.CreatePatch(before, after)
+	if err != nil {
+		return "", err
+	}
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return "", err
+	}
+	return string(patch), nil
+}
+
+// statefulSet returns the pod's StatefulSet, or nil when it can't be read.
+// Lookup failures are logged, so they never block admission.
+func (m *PodMutator) statefulSet(ctx context.Context, pod *corev1.Pod, ss string, l logr.Logger) *appsv1.StatefulSet {
+	if m.client == nil {
+		return nil
+	}
 
-	for i, handler := range m.handlers {
+	// Pods being created may not have their namespace set yet
+	namespace := pod.Namespace
+	if namespace == "" {
+		if req, err := admission.RequestFromContext(ctx); err == nil {
+			namespace = req.Namespace
+		}
+	}
+
+	sts := &appsv1.StatefulSet{}
+	if err := m.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ss}, sts); err != nil {
+		if !apierrors.IsNotFound(err) {
+			l.Error(err, "Failed to get StatefulSet")
+		}
+		return nil
+	}
+	return sts
+}
+
+// qualifierSets returns the named qualifier sets defined on the StatefulSet.
+// Invalid sets are logged and treated as no sets, so they never block admission.
+func (m *PodMutator) qualifierSets(sts *appsv1.StatefulSet, l logr.Logger) map[string]string {
+	if sts == nil {
+		return nil
+	}
+
+	v, ok := sts.Annotations[annotation.KeyOf(m.collector, annotation.QualifiedName{Name: annotation.QualifierSets})]
+	if !ok {
+		return nil
+	}
+
+	sets, err := annotation.ParseQualifierSets(v, annotation.SeparatorOf(m.collector))
+	if err != nil {
+		l.Error(err, "Ignoring invalid qualifier sets")
+		return nil
+	}
+
+	return sets
+}
+
+// statefulSetAnnotations returns the spoditor annotations on the StatefulSet
+// itself when they are to be applied, leaving out those configuring the
+// webhook rather than the pods. Without the StatefulSet, e.g. when it can't be
+// read, only the pod's annotations apply. They only apply to pods being
+// created, so annotating the StatefulSet leaves its existing pods alone.
+func (m *PodMutator) statefulSetAnnotations(sts *appsv1.StatefulSet, update bool) map[annotation.QualifiedName]string {
+	if !m.StatefulSetAnnotations || sts == nil || update {
+		return nil
+	}
+
+	annotations := m.collector.Collect(sts)
+	delete(annotations, annotation.QualifiedName{Name: annotation.QualifierSets})
+	delete(annotations, annotation.QualifiedName{Name: RequireFullCoverage})
+	deleteRecords(annotations)
+	return annotations
+}
+
+// runHandler mutates the pod spec with the handler, and the pod metadata for
+// handlers mutating it as well. A panicking handler is recovered and reported
+// as an error, so a bad handler fails the admission of the pods configured for
+// it instead of taking the webhook down.
+func runHandler(handler annotation.Handler, pod *corev1.Pod, mc annotation.MutationContext, config any, l logr.Logger) (result annotation.Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.Error(nil, "Handler panicked", "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panicked: %v", r)
+		}
+	}()
+
+	result, err = annotation.MutateWithResult(handler, &pod.Spec, mc, config)
+	if err != nil {
+		l.Error(err, "Handler failed to mutate pod")
+		return result, fmt.Errorf("mutation error: %w", err)
+	}
+
+	// Handlers that also mutate metadata get the pod's ObjectMeta as well
+	if mh, ok := handler.(annotation.MetadataHandler); ok {
+		meta := pod.ObjectMeta.DeepCopy()
+		if err := mh.MutateMeta(&pod.ObjectMeta, mc, config); err != nil {
+			l.Error(err, "Handler failed to mutate pod metadata")
+			return result, fmt.Errorf("metadata mutation error: %w", err)
+		}
+		if !equality.Semantic.DeepEqual(meta, &pod.ObjectMeta) {
+			result.Changed, result.Skipped = true, false
+			result.Fields = append(result.Fields, "metadata")
+		}
+	}
+	return result, nil
+}
+
+// applyHandlers processes all registered handlers against the pod in priority
+// order and returns the names of the handlers that found a configuration and
+// changed the pod. Handlers left out, e.g. by their qualifier, are skipped. A
+// handler changing a field an earlier handler already set is logged as a
+// conflict, the later handler wins. What the handlers add to the pod spec is
+// recorded in the applied annotation, and removed again before the handlers
+// run on a later admission of the same pod, so re-admissions apply the
+// difference between the previous and the current configuration.
+//
+// The spec of an existing pod is all but immutable, so on update the handlers
+// run against a scratch copy of it and only their metadata changes are kept.
+func (m *PodMutator) applyHandlers(pod *corev1.Pod, mc annotation.MutationContext, annotations map[annotation.QualifiedName]string, update bool, ll logr.Logger) ([]string, error) {
+	if update {
+		target := &corev1.Pod{ObjectMeta: pod.ObjectMeta, Spec: *pod.Spec.DeepCopy()}
+		applied, err := m.runHandlers(target, mc, annotations, true, ll)
+		if err != nil {
+			return nil, err
+		}
+		pod.ObjectMeta = target.ObjectMeta
+		return applied, nil
+	}
+
+	// Undo what an earlier admission of this pod added, so the handlers
+	// reconcile the pod with the current configuration instead of appending
+	// to the previous one
+	appliedKey := annotation.KeyOf(m.collector, annotation.QualifiedName{Name: Applied})
+	if v, ok := pod.Annotations[appliedKey]; ok {
+		if previous, err := parseAppliedRecord(v); err != nil {
+			ll.Error(err, "Ignoring unreadable record of a previous mutation")
+		} else {
+			ll.Info("Removing what a previous mutation added", "applied", v)
+			previous.strip(&pod.Spec)
+		}
+	}
+	submitted := pod.Spec.DeepCopy()
+
+	applied, err := m.runHandlers(pod, mc, annotations, false, ll)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record what was added for the next admission to reconcile against
+	if record := recordAdditions(submitted, &pod.Spec); !record.isEmpty() {
+		if pod.Annotations == nil {
+			pod.Annotations = make(map[string]string)
+		}
+		pod.Annotations[appliedKey] = record.String()
+	} else {
+		delete(pod.Annotations, appliedKey)
+	}
+
+	return applied, nil
+}
+
+// runHandlers runs the handlers against the pod in priority order and returns
+// the names of those that changed it, or only changed its metadata when
+// metaOnly is set
+func (m *PodMutator) runHandlers(pod *corev1.Pod, mc annotation.MutationContext, annotations map[annotation.QualifiedName]string, metaOnly bool, ll logr.Logger) ([]string, error) {
+	var digest [sha256.Size]byte
+	if m.cache != nil {
+		digest = digestAnnotations(annotations)
+	}
+
+	var applied []string
+	owners := fieldOwners{}
+	for i, handler := range sortHandlers(m.handlers) {
 		l := ll.WithValues("handlerIndex", i, "handlerType", fmt.Sprintf("%T", handler))
 
-		// Parse the configuration for this handler
-		config, err := handler.GetParser().Parse(annotations)
+		// A handler without a parser can't find its configuration, skip it
+		// rather than failing the admission of every pod
+		if !hasParser(handler) {
+			l.Error(nil, "Handler has no parser, skipping")
+			continue
+		}
+
+		// Parse the configuration for this handler, reusing a cached one for
+		// identical annotations
+		config, err := m.cache.parse(handler, digest, annotations)
 		if err != nil {
 			l.Error(err, "Failed to parse configuration")
-			return fmt.Errorf("handler %T at index %d: parse error: %w", handler, i, err)
+			parseFailures.WithLabelValues(annotation.HandlerName(handler)).Inc()
+			return nil, fmt.Errorf("handler %T at index %d: parse error: %w", handler, i, err)
 		}
 
 		// Skip if no configuration was found for this handler
@@ -107,13 +513,29 @@ func (m *PodMutator) applyHandlers(pod *corev1.Pod, ordinal int, ll logr.Logger)
 		}
 
 		l.Info("Parsed mutation configuration", "config", config)
-		if err := handler.Mutate(&pod.Spec, ordinal, config); err != nil {
-			l.Error(err, "Handler failed to mutate pod")
-			return fmt.Errorf("handler %d: mutation error: %w", i, err)
+		before := watchedFields(&pod.Spec)
+		result, err := runHandler(handler, pod, mc, config, l)
+		if err != nil {
+			return nil, fmt.Errorf("handler %d: %w", i, err)
+		}
+
+		name := annotation.HandlerName(handler)
+		for _, c := range owners.record(name, before, watchedFields(&pod.Spec)) {
+			l.Info("Handler overrode a field set by another handler", "field", c.Field, "previousHandler", c.Previous)
 		}
 
-		l.Info("Successfully applied handler")
+		if metaOnly && !slices.Contains(result.Fields, "metadata") {
+			result.Skipped = true
+		}
+		if result.Skipped {
+			l.Info("Handler changed nothing")
+			handlerResults.WithLabelValues(name, resultSkipped).Inc()
+			continue
+		}
+		l.Info("Successfully applied handler", "fields", result.Fields)
+		handlerResults.WithLabelValues(name, resultChanged).Inc()
+		applied = append(applied, name)
 	}
 
-	return nil
+	return applied, nil
 }
@@ -2,17 +2,32 @@ package v1
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
 	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/imagepullsecrets"
+	"github.com/golem-base/spoditor/internal/annotation/labels"
 	"github.com/golem-base/spoditor/internal/annotation/ports"
 	"github.com/golem-base/spoditor/internal/annotation/volumes"
 	"github.com/golem-base/spoditor/internal/identifier"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 var _ = Describe("Pod Webhook", func() {
@@ -164,6 +179,42 @@ var _ = Describe("Pod Webhook", func() {
 			Expect(hasPortVar).To(BeTrue(), "PORT_http environment variable should be set")
 		})
 
+		It("Should apply the default qualifier to unqualified annotations", func() {
+			mutator.DefaultQualifier = "0-2"
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-5",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/mount-volume": `{"volumes":[{"name":"config","configMap":{"name":"config"}}]}`,
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pod.Spec.Volumes).To(BeEmpty())
+
+			// Ordinals the default qualifier selects are mutated
+			pod.ObjectMeta.Labels["statefulset.kubernetes.io/pod-name"] = "test-statefulset-1"
+			err = mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pod.Spec.Volumes).To(HaveLen(1))
+			Expect(pod.Spec.Volumes[0].ConfigMap.Name).To(Equal("config-1"))
+		})
+
+		It("Should let an explicit qualifier override the default one", func() {
+			mutator.DefaultQualifier = "0-2"
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-5",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/mount-volume_5": `{"volumes":[{"name":"config","configMap":{"name":"config"}}]}`,
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pod.Spec.Volumes).To(HaveLen(1))
+			Expect(pod.Spec.Volumes[0].ConfigMap.Name).To(Equal("config-5"))
+		})
+
 		It("Should respect pod ordinal qualifiers in annotations", func() {
 			// Create a StatefulSet pod with a qualified annotation
 			pod.ObjectMeta.Labels = map[string]string{
@@ -213,5 +264,945 @@ var _ = Describe("Pod Webhook", func() {
 			Expect(pod.Spec.Volumes[0].Name).To(Equal("qualified-volume"))
 			Expect(pod.Spec.Volumes[0].ConfigMap.Name).To(Equal("qualified-config-1"))
 		})
+
+		It("Should let metadata handlers label the pod", func() {
+			mutator.handlers = append(mutator.handlers, &labels.OrdinalLabelHandler{})
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-3",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/inject-ordinal-label": "pod-ordinal",
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(pod.ObjectMeta.Labels).To(HaveKeyWithValue("pod-ordinal", "3"))
+		})
+
+		It("Should run the metadata path of handlers alongside the spec path", func() {
+			handler := &annotatingHandler{}
+			mutator.handlers = []annotation.Handler{handler}
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/annotate": "touched",
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(handler.specMutated).To(BeTrue())
+			Expect(pod.ObjectMeta.Annotations).To(HaveKeyWithValue("example.com/ordinal-1", "touched"))
+		})
+
+		It("Should count parse failures by feature", func() {
+			failures := parseFailures.WithLabelValues(volumes.MountVolume)
+			before := testutil.ToFloat64(failures)
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/mount-volume": `{"volumes":[`,
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).To(HaveOccurred())
+			Expect(testutil.ToFloat64(failures)).To(Equal(before + 1))
+			Expect(testutil.ToFloat64(parseFailures.WithLabelValues(ports.HostPort))).To(BeZero())
+		})
+
+		It("Should skip handlers without a parser and run the others", func() {
+			handler := &annotatingHandler{}
+			mutator.handlers = []annotation.Handler{&nilParserHandler{}, &nilParserHandler{typed: true}, handler}
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/annotate": "touched",
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(handler.specMutated).To(BeTrue())
+			Expect(pod.ObjectMeta.Annotations).To(HaveKeyWithValue("example.com/ordinal-1", "touched"))
+		})
+
+		It("Should turn a handler panic into an error without affecting other pods", func() {
+			handler := &annotatingHandler{}
+			mutator.handlers = []annotation.Handler{&panickingHandler{}, handler}
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/panic":    "now",
+				"spoditor.io/annotate": "touched",
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).To(MatchError(ContainSubstring("panicked: malformed configuration")))
+
+			// A pod the panicking handler isn't configured for is mutated as usual
+			delete(pod.ObjectMeta.Annotations, "spoditor.io/panic")
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			Expect(handler.specMutated).To(BeTrue())
+			Expect(pod.ObjectMeta.Annotations).To(HaveKeyWithValue("example.com/ordinal-1", "touched"))
+		})
+
+		It("Should pass the StatefulSet name and ordinal to handlers", func() {
+			handler := &annotatingHandler{}
+			mutator.handlers = []annotation.Handler{handler}
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-4",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/annotate": "touched",
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(handler.mc).To(Equal(annotation.MutationContext{Ordinal: 4, SSName: "test-statefulset"}))
+		})
+
+		It("Should report the applied handlers to the OnMutate callback", func() {
+			var (
+				calls      int
+				gotPod     *corev1.Pod
+				gotOrdinal int
+				gotApplied []string
+			)
+			mutator.OnMutate = func(p *corev1.Pod, ordinal int, applied []string) {
+				calls++
+				gotPod, gotOrdinal, gotApplied = p, ordinal, applied
+			}
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-2",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port": `{"containers":[{"name":"test-container","ports":[{"containerPort":8080,"hostPort":30000}]}]}`,
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(calls).To(Equal(1))
+			Expect(gotPod).To(BeIdenticalTo(pod))
+			Expect(gotOrdinal).To(Equal(2))
+			Expect(gotApplied).To(Equal([]string{ports.HostPort}))
+		})
+
+		It("Should leave handlers excluded by their qualifier out of the applied ones", func() {
+			var gotApplied []string
+			mutator.OnMutate = func(_ *corev1.Pod, _ int, applied []string) {
+				gotApplied = applied
+			}
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-2",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port":      `{"containers":[{"name":"test-container","ports":[{"containerPort":8080,"hostPort":30000}]}]}`,
+				"spoditor.io/mount-volume_5": `{"volumes":[{"name":"data","emptyDir":{}}]}`,
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(pod.Spec.Volumes).To(BeEmpty())
+			Expect(gotApplied).To(Equal([]string{ports.HostPort}))
+			Expect(pod.Annotations).To(HaveKeyWithValue("spoditor.io/mutated-by", ports.HostPort))
+		})
+
+		It("Should not call OnMutate for non-StatefulSet pods", func() {
+			called := false
+			mutator.OnMutate = func(*corev1.Pod, int, []string) { called = true }
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(called).To(BeFalse())
+		})
+
+		It("Should resolve qualifier sets defined on the StatefulSet", func() {
+			sts := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-statefulset",
+					Namespace: "default",
+					Annotations: map[string]string{
+						"spoditor.io/qualifier-sets": `{"canary":"0,2","bulk":"3-"}`,
+					},
+				},
+			}
+			mutator.client = fake.NewClientBuilder().
+				WithScheme(clientgoscheme.Scheme).
+				WithObjects(sts).
+				Build()
+
+			hostPortApplied := func(ordinal int) bool {
+				p := pod.DeepCopy()
+				p.ObjectMeta.Labels = map[string]string{
+					"statefulset.kubernetes.io/pod-name": fmt.Sprintf("test-statefulset-%d", ordinal),
+				}
+				p.ObjectMeta.Annotations = map[string]string{
+					"spoditor.io/host-port_canary": `{"containers":[{"name":"test-container","ports":[{"containerPort":8080,"hostPort":30000}]}]}`,
+				}
+				Expect(mutator.Default(ctx, p)).To(Succeed())
+				return len(p.Spec.Containers[0].Ports) == 1
+			}
+
+			Expect(hostPortApplied(0)).To(BeTrue())
+			Expect(hostPortApplied(1)).To(BeFalse())
+			Expect(hostPortApplied(2)).To(BeTrue())
+			Expect(hostPortApplied(3)).To(BeFalse())
+		})
+
+		It("Should ignore qualifier sets colliding with an explicit qualifier", func() {
+			mutator.client = fake.NewClientBuilder().
+				WithScheme(clientgoscheme.Scheme).
+				WithObjects(&appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-statefulset",
+						Namespace: "default",
+						Annotations: map[string]string{
+							"spoditor.io/qualifier-sets": `{"first":"0"}`,
+						},
+					},
+				}).
+				Build()
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-0",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port_0":     `{"containers":[{"name":"test-container","ports":[{"containerPort":8080,"hostPort":30000}]}]}`,
+				"spoditor.io/host-port_first": `{"containers":[{"name":"test-container","ports":[{"containerPort":9090,"hostPort":31000}]}]}`,
+			}
+
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			Expect(pod.Spec.Containers[0].Ports).To(HaveLen(1))
+			Expect(pod.Spec.Containers[0].Ports[0].HostPort).To(Equal(int32(30000)))
+		})
+
+		It("Should propagate StatefulSet labels onto the pod", func() {
+			mutator.handlers = []annotation.Handler{&labels.StatefulSetLabelsHandler{}}
+			mutator.client = fake.NewClientBuilder().
+				WithScheme(clientgoscheme.Scheme).
+				WithObjects(&appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-statefulset",
+						Namespace: "default",
+						Labels: map[string]string{
+							"team":                "storage",
+							"example.com/monitor": "true",
+						},
+					},
+				}).
+				Build()
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/statefulset-labels": `["team","example.com/monitor"]`,
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pod.Labels).To(HaveKeyWithValue("team", "storage"))
+			Expect(pod.Labels).To(HaveKeyWithValue("example.com/monitor", "true"))
+		})
+
+		Context("With StatefulSet annotations", func() {
+			BeforeEach(func() {
+				mutator.StatefulSetAnnotations = true
+				mutator.client = fake.NewClientBuilder().
+					WithScheme(clientgoscheme.Scheme).
+					WithObjects(&appsv1.StatefulSet{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test-statefulset",
+							Namespace: "default",
+							Annotations: map[string]string{
+								"spoditor.io/host-port":           `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+								"spoditor.io/mount-volume_canary": `{"volumes":[{"name":"config","configMap":{"name":"canary"}}],"containers":[{"name":"test-container","volumeMounts":[{"name":"config","mountPath":"/etc/config"}]}]}`,
+								"spoditor.io/qualifier-sets":      `{"canary":"1"}`,
+							},
+						},
+					}).
+					Build()
+				pod.ObjectMeta.Labels = map[string]string{
+					"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+				}
+			})
+
+			It("Should apply the annotations of the StatefulSet", func() {
+				Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+				Expect(pod.Spec.Containers[0].Ports).To(HaveLen(1))
+				Expect(pod.Spec.Containers[0].Ports[0].HostPort).To(Equal(int32(30001)))
+				Expect(pod.Spec.Volumes).To(HaveLen(1))
+				Expect(pod.Spec.Volumes[0].ConfigMap.Name).To(Equal("canary-1"))
+			})
+
+			It("Should prefer the annotations of the pod", func() {
+				pod.ObjectMeta.Annotations = map[string]string{
+					"spoditor.io/host-port": `{"containers":[{"name":"test-container","ports":[{"name":"admin","containerPort":9090,"hostPort":31000}]}]}`,
+				}
+
+				Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+				Expect(pod.Spec.Containers[0].Ports).To(HaveLen(1))
+				Expect(pod.Spec.Containers[0].Ports[0].Name).To(Equal("admin"))
+			})
+
+			It("Should fall back to the pod's annotations when the StatefulSet is missing", func() {
+				mutator.client = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+				pod.ObjectMeta.Annotations = map[string]string{
+					"spoditor.io/host-port": `{"containers":[{"name":"test-container","ports":[{"name":"admin","containerPort":9090,"hostPort":31000}]}]}`,
+				}
+
+				Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+				Expect(pod.Spec.Containers[0].Ports).To(HaveLen(1))
+				Expect(pod.Spec.Containers[0].Ports[0].Name).To(Equal("admin"))
+				Expect(pod.Spec.Volumes).To(BeEmpty())
+			})
+
+			It("Should only apply the annotations of the StatefulSet to pods being created", func() {
+				mutator.handlers = append(mutator.handlers, &labels.OrdinalLabelHandler{})
+				mutator.client = fake.NewClientBuilder().
+					WithScheme(clientgoscheme.Scheme).
+					WithObjects(&appsv1.StatefulSet{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:        "test-statefulset",
+							Namespace:   "default",
+							Annotations: map[string]string{"spoditor.io/inject-ordinal-label": "pod-ordinal"},
+						},
+					}).
+					Build()
+				updateCtx := admission.NewContextWithRequest(ctx, admission.Request{
+					AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Update},
+				})
+
+				Expect(mutator.Default(updateCtx, pod)).To(Succeed())
+				Expect(pod.Labels).NotTo(HaveKey("pod-ordinal"))
+
+				Expect(mutator.Default(ctx, pod)).To(Succeed())
+				Expect(pod.Labels).To(HaveKeyWithValue("pod-ordinal", "1"))
+			})
+
+			It("Should ignore the annotations of the StatefulSet unless enabled", func() {
+				mutator.StatefulSetAnnotations = false
+
+				Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+				Expect(pod.Spec.Containers[0].Ports).To(BeEmpty())
+				Expect(pod.Spec.Volumes).To(BeEmpty())
+			})
+		})
+
+		It("Should leave named qualifiers unresolved when the StatefulSet is missing", func() {
+			mutator.client = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-0",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port_canary": `{"containers":[{"name":"test-container","ports":[{"containerPort":8080,"hostPort":30000}]}]}`,
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pod.Spec.Containers[0].Ports).To(BeEmpty())
+		})
+
+		It("Should only respond to annotations under a custom prefix", func() {
+			mutator.collector = &annotation.PrefixedCollector{Prefix: "acme.example.com/"}
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"acme.example.com/host-port": `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+				"spoditor.io/host-port":      `{"containers":[{"name":"test-container","ports":[{"name":"admin","containerPort":9090,"hostPort":31000}]}]}`,
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(pod.Spec.Containers[0].Ports).To(HaveLen(1))
+			Expect(pod.Spec.Containers[0].Ports[0].Name).To(Equal("http"))
+			Expect(pod.Spec.Containers[0].Ports[0].HostPort).To(Equal(int32(30001)))
+		})
+
+		It("Should read qualifier sets under a custom prefix", func() {
+			mutator.collector = &annotation.PrefixedCollector{Prefix: "acme.example.com/"}
+			mutator.client = fake.NewClientBuilder().
+				WithScheme(clientgoscheme.Scheme).
+				WithObjects(&appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-statefulset",
+						Namespace: "default",
+						Annotations: map[string]string{
+							"acme.example.com/qualifier-sets": `{"canary":"1"}`,
+						},
+					},
+				}).
+				Build()
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"acme.example.com/host-port_canary": `{"containers":[{"name":"test-container","ports":[{"containerPort":8080,"hostPort":30000}]}]}`,
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pod.Spec.Containers[0].Ports).To(HaveLen(1))
+		})
+
+		It("Should record what the handlers added to the pod spec", func() {
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port": `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}
+
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+			Expect(pod.Annotations).To(HaveKey("spoditor.io/applied"))
+			record, err := parseAppliedRecord(pod.Annotations["spoditor.io/applied"])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record.ContainerItems).To(HaveKey("test-container"))
+			Expect(record.ContainerItems["test-container"].Ports).To(Equal([]string{"8080/TCP"}))
+			Expect(record.ContainerItems["test-container"].Env).To(ConsistOf("POD_ORDINAL", "PORT_http"))
+		})
+
+		It("Should stamp when and by which handlers the pod was mutated", func() {
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port": `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}
+
+			before := time.Now().Add(-time.Second)
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+			Expect(pod.Annotations).To(HaveKey("spoditor.io/mutated-at"))
+			at, err := time.Parse(time.RFC3339, pod.Annotations["spoditor.io/mutated-at"])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(at).To(BeTemporally(">=", before.Truncate(time.Second)))
+			Expect(pod.Annotations).To(HaveKeyWithValue("spoditor.io/mutated-by", ports.HostPort))
+
+			// The stamps aren't mistaken for configuration on re-admission
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			Expect(pod.Annotations).To(HaveKeyWithValue("spoditor.io/mutated-by", ports.HostPort))
+		})
+
+		It("Should only restamp the time when the spec changed", func() {
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port": `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+			// Admitted again with the same configuration, the stamp is kept
+			earlier := "2020-01-01T00:00:00Z"
+			pod.Annotations["spoditor.io/mutated-at"] = earlier
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			Expect(pod.Annotations).To(HaveKeyWithValue("spoditor.io/mutated-at", earlier))
+
+			// A changed configuration changes the spec, and the stamp with it
+			pod.Annotations["spoditor.io/host-port"] = `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":31000}]}]}`
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			Expect(pod.Annotations["spoditor.io/mutated-at"]).NotTo(Equal(earlier))
+		})
+
+		It("Should not stamp a pod no handler applied to", func() {
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			Expect(pod.Annotations).NotTo(HaveKey("spoditor.io/mutated-at"))
+			Expect(pod.Annotations).NotTo(HaveKey("spoditor.io/mutated-by"))
+		})
+
+		It("Should replace what a previous configuration added on re-admission", func() {
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "USER_VAR", Value: "kept"}}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port":    `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+				"spoditor.io/mount-volume": `{"volumes":[{"name":"old-config","configMap":{"name":"old"}}],"containers":[{"name":"test-container","volumeMounts":[{"name":"old-config","mountPath":"/etc/old"}]}]}`,
+			}
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+			// Update the configuration of the mutated pod and admit it again
+			pod.ObjectMeta.Annotations["spoditor.io/host-port"] = `{"containers":[{"name":"test-container","ports":[{"name":"admin","containerPort":9090,"hostPort":31000}]}]}`
+			pod.ObjectMeta.Annotations["spoditor.io/mount-volume"] = `{"volumes":[{"name":"new-config","configMap":{"name":"new"}}],"containers":[{"name":"test-container","volumeMounts":[{"name":"new-config","mountPath":"/etc/new"}]}]}`
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+			container := pod.Spec.Containers[0]
+			Expect(container.Ports).To(HaveLen(1))
+			Expect(container.Ports[0].Name).To(Equal("admin"))
+			Expect(container.Ports[0].HostPort).To(Equal(int32(31001)))
+			Expect(container.Env).To(ConsistOf(
+				corev1.EnvVar{Name: "USER_VAR", Value: "kept"},
+				corev1.EnvVar{Name: "POD_ORDINAL", Value: "1"},
+				corev1.EnvVar{Name: "PORT_admin", Value: "31001"},
+			))
+			Expect(pod.Spec.Volumes).To(HaveLen(1))
+			Expect(pod.Spec.Volumes[0].Name).To(Equal("new-config"))
+			Expect(pod.Spec.Volumes[0].ConfigMap.Name).To(Equal("new-1"))
+			Expect(container.VolumeMounts).To(HaveLen(1))
+			Expect(container.VolumeMounts[0].MountPath).To(Equal("/etc/new"))
+		})
+
+		It("Should only reconcile the metadata of a pod on update", func() {
+			mutator.handlers = append(mutator.handlers, &labels.OrdinalLabelHandler{})
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port":            `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+				"spoditor.io/inject-ordinal-label": "pod-ordinal",
+			}
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			created := pod.DeepCopy()
+
+			// Change the configuration and update the existing pod
+			pod.ObjectMeta.Annotations["spoditor.io/host-port"] = `{"containers":[{"name":"test-container","ports":[{"name":"admin","containerPort":9090,"hostPort":31000}]}]}`
+			pod.ObjectMeta.Annotations["spoditor.io/inject-ordinal-label"] = "ordinal"
+			updateCtx := admission.NewContextWithRequest(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Update},
+			})
+			Expect(mutator.Default(updateCtx, pod)).To(Succeed())
+
+			Expect(pod.Spec).To(Equal(created.Spec))
+			Expect(pod.Labels).To(HaveKeyWithValue("ordinal", "1"))
+			for _, key := range []string{"spoditor.io/applied", "spoditor.io/mutated-at", "spoditor.io/mutated-by"} {
+				Expect(pod.Annotations).To(HaveKeyWithValue(key, created.Annotations[key]))
+			}
+		})
+
+		It("Should not change a pod admitted again with the same configuration", func() {
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-2",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port":    `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+				"spoditor.io/mount-volume": `{"volumes":[{"name":"config","configMap":{"name":"config"}}],"containers":[{"name":"test-container","volumeMounts":[{"name":"config","mountPath":"/etc/config"}]}]}`,
+			}
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			first := pod.DeepCopy()
+
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			Expect(pod).To(Equal(first))
+		})
+
+		It("Should not duplicate volumes when the applied record is lost", func() {
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-2",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/mount-volume": `{"volumes":[{"name":"config","configMap":{"name":"config"}}],"containers":[{"name":"test-container","volumeMounts":[{"name":"config","mountPath":"/etc/config"}]}]}`,
+			}
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+			// Admit the pod again without the record of what was added
+			delete(pod.ObjectMeta.Annotations, "spoditor.io/applied")
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+			Expect(pod.Spec.Volumes).To(HaveLen(1))
+			Expect(pod.Spec.Volumes[0].ConfigMap.Name).To(Equal("config-2"))
+			Expect(pod.Spec.Containers[0].VolumeMounts).To(HaveLen(1))
+			Expect(pod.Spec.Containers[0].VolumeMounts[0].MountPath).To(Equal("/etc/config"))
+		})
+
+		It("Should drop the record once nothing is added anymore", func() {
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port": `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+			delete(pod.ObjectMeta.Annotations, "spoditor.io/host-port")
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+			Expect(pod.Spec.Containers[0].Ports).To(BeEmpty())
+			Expect(pod.Spec.Containers[0].Env).To(BeEmpty())
+			Expect(pod.Annotations).NotTo(HaveKey("spoditor.io/applied"))
+		})
+
+		It("Should replace image pull secrets a previous configuration added", func() {
+			mutator.handlers = []annotation.Handler{&imagepullsecrets.ImagePullSecretsHandler{}}
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "user"}}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/image-pull-secrets": `{"secrets":["old"]}`,
+			}
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			Expect(pod.Spec.ImagePullSecrets).To(Equal([]corev1.LocalObjectReference{{Name: "user"}, {Name: "old"}}))
+
+			pod.ObjectMeta.Annotations["spoditor.io/image-pull-secrets"] = `{"secrets":["new"]}`
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			Expect(pod.Spec.ImagePullSecrets).To(Equal([]corev1.LocalObjectReference{{Name: "user"}, {Name: "new"}}))
+		})
+
+		It("Should leave the pod unchanged and log the intended patch in dry-run mode", func() {
+			var messages []string
+			defer func(l logr.Logger) { podlog = l }(podlog)
+			podlog = funcr.New(func(_, args string) {
+				messages = append(messages, args)
+			}, funcr.Options{})
+
+			mutator.DryRun = true
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port": `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}
+			original := pod.DeepCopy()
+
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+
+			Expect(pod).To(Equal(original))
+			Expect(messages).To(ContainElement(SatisfyAll(
+				ContainSubstring("Dry run, leaving pod unchanged"),
+				ContainSubstring(`/spec/containers/0/ports`),
+				ContainSubstring(`/metadata/annotations/spoditor.io~1applied`),
+			)))
+		})
+
+		It("Should summarize the applied handlers in the admission response", func() {
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-2",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port":    `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+				"spoditor.io/mount-volume": `{"volumes":[{"name":"data","secret":{"secretName":"data"}}],"containers":[{"name":"test-container","volumeMounts":[{"name":"data","mountPath":"/data"}]}]}`,
+			}
+			raw, err := json.Marshal(pod)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp := newPodWebhook(clientgoscheme.Scheme, mutator).Handle(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+
+			Expect(resp.Allowed).To(BeTrue())
+			Expect(resp.Patches).NotTo(BeEmpty())
+			Expect(resp.Result.Message).To(Equal("applied mount-volume, host-port for ordinal 2"))
+		})
+
+		It("Should not summarize non-StatefulSet pods", func() {
+			raw, err := json.Marshal(pod)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp := newPodWebhook(clientgoscheme.Scheme, mutator).Handle(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+
+			Expect(resp.Allowed).To(BeTrue())
+			if resp.Result != nil {
+				Expect(resp.Result.Message).To(BeEmpty())
+			}
+		})
+
+		It("Should count ignored pods by reason", func() {
+			before := map[string]float64{}
+			for _, reason := range []string{ignoreNoLabel, ignoreNotStatefulSet, ignoreNoAnnotations, ignoreQualifierExcluded} {
+				before[reason] = ignoredPods(reason)
+			}
+
+			// No StatefulSet pod name label
+			Expect(mutator.Default(ctx, pod.DeepCopy())).To(Succeed())
+
+			// A label that names no StatefulSet pod
+			notSS := pod.DeepCopy()
+			notSS.Labels = map[string]string{"statefulset.kubernetes.io/pod-name": "standalone"}
+			Expect(mutator.Default(ctx, notSS)).To(Succeed())
+
+			// A StatefulSet pod without annotations
+			pod.Labels = map[string]string{"statefulset.kubernetes.io/pod-name": "test-statefulset-1"}
+			Expect(mutator.Default(ctx, pod.DeepCopy())).To(Succeed())
+
+			// A StatefulSet pod no qualifier selects, twice
+			excluded := pod.DeepCopy()
+			excluded.Annotations = map[string]string{
+				"spoditor.io/host-port_3-": `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}
+			Expect(mutator.Default(ctx, excluded.DeepCopy())).To(Succeed())
+			Expect(mutator.Default(ctx, excluded.DeepCopy())).To(Succeed())
+
+			// A StatefulSet pod that gets mutated isn't counted
+			mutated := pod.DeepCopy()
+			mutated.Annotations = map[string]string{
+				"spoditor.io/host-port_0-2": `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}
+			Expect(mutator.Default(ctx, mutated)).To(Succeed())
+
+			Expect(ignoredPods(ignoreNoLabel) - before[ignoreNoLabel]).To(BeEquivalentTo(1))
+			Expect(ignoredPods(ignoreNotStatefulSet) - before[ignoreNotStatefulSet]).To(BeEquivalentTo(1))
+			Expect(ignoredPods(ignoreNoAnnotations) - before[ignoreNoAnnotations]).To(BeEquivalentTo(1))
+			Expect(ignoredPods(ignoreQualifierExcluded) - before[ignoreQualifierExcluded]).To(BeEquivalentTo(2))
+		})
+
+		It("Should leave disabled pods unmodified", func() {
+			before := ignoredPods(ignoreDisabled)
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/disabled":     "true",
+				"spoditor.io/host-port":    `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+				"spoditor.io/mount-volume": `{"volumes":[{"name":"config","configMap":{"name":"app"}}],"containers":[{"name":"test-container","volumeMounts":[{"name":"config","mountPath":"/etc/config"}]}]}`,
+			}
+			original := pod.DeepCopy()
+
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			Expect(pod).To(Equal(original))
+			Expect(ignoredPods(ignoreDisabled) - before).To(BeEquivalentTo(1))
+
+			// Any other value leaves mutation on
+			pod.ObjectMeta.Annotations["spoditor.io/disabled"] = "false"
+			Expect(mutator.Default(ctx, pod)).To(Succeed())
+			Expect(pod.Spec.Containers[0].Ports).To(HaveLen(1))
+			Expect(pod.Spec.Volumes).To(HaveLen(1))
+		})
+
+		It("Should only mutate pods whose primary image is allowed", func() {
+			mutator.AllowedImages = []string{"registry.example.com/*", "nginx"}
+			before := ignoredPods(ignoreImageNotAllowed)
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-1",
+			}
+			pod.ObjectMeta.Annotations = map[string]string{
+				"spoditor.io/host-port": `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}
+
+			allowed := pod.DeepCopy()
+			allowed.Spec.Containers[0].Image = "registry.example.com/team/app:1.0"
+			Expect(mutator.Default(ctx, allowed)).To(Succeed())
+			Expect(allowed.Spec.Containers[0].Ports).To(HaveLen(1))
+
+			exact := pod.DeepCopy()
+			Expect(mutator.Default(ctx, exact)).To(Succeed())
+			Expect(exact.Spec.Containers[0].Ports).To(HaveLen(1))
+
+			disallowed := pod.DeepCopy()
+			disallowed.Spec.Containers[0].Image = "docker.io/library/nginx:1.27"
+			original := disallowed.DeepCopy()
+			Expect(mutator.Default(ctx, disallowed)).To(Succeed())
+			Expect(disallowed).To(Equal(original))
+			Expect(ignoredPods(ignoreImageNotAllowed) - before).To(BeEquivalentTo(1))
+		})
+
+		It("Should leave pods with an ordinal above the maximum unmutated", func() {
+			mutator.MaxOrdinal = 10
+			before := ignoredPods(ignoreOrdinalTooLarge)
+			annotations := map[string]string{
+				"spoditor.io/host-port": `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+			}
+
+			large := pod.DeepCopy()
+			large.Labels = map[string]string{"statefulset.kubernetes.io/pod-name": "test-statefulset-4294967295"}
+			large.Annotations = annotations
+			Expect(mutator.Default(ctx, large)).To(Succeed())
+			Expect(large.Spec).To(Equal(pod.Spec))
+			Expect(large.Annotations).To(Equal(annotations))
+			Expect(ignoredPods(ignoreOrdinalTooLarge) - before).To(BeEquivalentTo(1))
+
+			// The bound itself is still mutated
+			bound := pod.DeepCopy()
+			bound.Labels = map[string]string{"statefulset.kubernetes.io/pod-name": "test-statefulset-10"}
+			bound.Annotations = annotations
+			Expect(mutator.Default(ctx, bound)).To(Succeed())
+			Expect(bound.Spec.Containers[0].Ports[0].HostPort).To(BeEquivalentTo(30010))
+		})
+
+		It("Should run handlers in priority order", func() {
+			var order []string
+			mutator.handlers = []annotation.Handler{
+				&hostPortSetter{name: "late", priority: 10, hostPort: 30001, order: &order},
+				&hostPortSetter{name: "early", priority: -10, hostPort: 30000, order: &order},
+				&hostPortSetter{name: "default", hostPort: 30000, order: &order},
+			}
+			pod.ObjectMeta.Labels = map[string]string{
+				"statefulset.kubernetes.io/pod-name": "test-statefulset-0",
+			}
+
+			err := mutator.Default(ctx, pod)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(order).To(Equal([]string{"early", "default", "late"}))
+			Expect(pod.Spec.Containers[0].Ports[0].HostPort).To(Equal(int32(30001)))
+		})
+
+		It("Should log a conflict when two handlers set the same host port", func() {
+			var messages []string
+			logger := funcr.New(func(_, args string) {
+				messages = append(messages, args)
+			}, funcr.Options{})
+
+			mutator.handlers = []annotation.Handler{
+				&hostPortSetter{name: "first", hostPort: 30000},
+				&hostPortSetter{name: "second", hostPort: 30001},
+			}
+			annotations := map[annotation.QualifiedName]string{}
+			mc := annotation.MutationContext{Ordinal: 0, SSName: "test-statefulset"}
+
+			applied, err := mutator.applyHandlers(pod, mc, annotations, false, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(applied).To(Equal([]string{"first", "second"}))
+
+			Expect(messages).To(ContainElement(SatisfyAll(
+				ContainSubstring("Handler overrode a field set by another handler"),
+				ContainSubstring(`"field"="spec.containers[test-container].ports[8080/TCP].hostPort"`),
+				ContainSubstring(`"previousHandler"="first"`),
+			)))
+		})
+
+		It("Should not report a conflict when a later handler sets the same value", func() {
+			var messages []string
+			logger := funcr.New(func(_, args string) {
+				messages = append(messages, args)
+			}, funcr.Options{})
+
+			mutator.handlers = []annotation.Handler{
+				&hostPortSetter{name: "first", hostPort: 30000},
+				&hostPortSetter{name: "second", hostPort: 30000},
+			}
+
+			_, err := mutator.applyHandlers(pod, annotation.MutationContext{}, map[annotation.QualifiedName]string{}, false, logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messages).NotTo(ContainElement(ContainSubstring("Handler overrode a field")))
+		})
 	})
 })
+
+// annotatingHandler is a MetadataHandler that records the context of its spec
+// mutation and annotates the pod with the configured value
+type annotatingHandler struct {
+	specMutated bool
+	mc          annotation.MutationContext
+}
+
+func (h *annotatingHandler) Mutate(_ *corev1.PodSpec, mc annotation.MutationContext, _ any) error {
+	h.specMutated = true
+	h.mc = mc
+	return nil
+}
+
+func (h *annotatingHandler) MutateMeta(meta *metav1.ObjectMeta, mc annotation.MutationContext, cfg any) error {
+	meta.Annotations[fmt.Sprintf("example.com/ordinal-%d", mc.Ordinal)] = cfg.(string)
+	return nil
+}
+
+func (h *annotatingHandler) GetParser() annotation.Parser {
+	return annotation.ParserFunc(func(annotations map[annotation.QualifiedName]string) (any, error) {
+		if v, ok := annotations[annotation.QualifiedName{Name: "annotate"}]; ok {
+			return v, nil
+		}
+		return nil, nil
+	})
+}
+
+// panickingHandler is a handler that panics when configured
+type panickingHandler struct{}
+
+func (h *panickingHandler) Mutate(*corev1.PodSpec, annotation.MutationContext, any) error {
+	panic("malformed configuration")
+}
+
+func (h *panickingHandler) GetParser() annotation.Parser {
+	return annotation.ParserFunc(func(annotations map[annotation.QualifiedName]string) (any, error) {
+		if v, ok := annotations[annotation.QualifiedName{Name: "panic"}]; ok {
+			return v, nil
+		}
+		return nil, nil
+	})
+}
+
+// hostPortSetter sets the host port of the first container's 8080 port, always
+// finding a configuration, and records its name in order when it runs
+type hostPortSetter struct {
+	name     string
+	priority int
+	hostPort int32
+	order    *[]string
+}
+
+func (h *hostPortSetter) Mutate(spec *corev1.PodSpec, _ annotation.MutationContext, _ any) error {
+	if h.order != nil {
+		*h.order = append(*h.order, h.name)
+	}
+	c := &spec.Containers[0]
+	for i := range c.Ports {
+		if c.Ports[i].ContainerPort == 8080 {
+			c.Ports[i].HostPort = h.hostPort
+			return nil
+		}
+	}
+	c.Ports = append(c.Ports, corev1.ContainerPort{ContainerPort: 8080, HostPort: h.hostPort})
+	return nil
+}
+
+func (h *hostPortSetter) GetParser() annotation.Parser {
+	return annotation.ParserFunc(func(map[annotation.QualifiedName]string) (any, error) {
+		return struct{}{}, nil
+	})
+}
+
+func (h *hostPortSetter) Name() string {
+	return h.name
+}
+
+func (h *hostPortSetter) Priority() int {
+	return h.priority
+}
+
+var _ = DescribeTable("Matching images against allowed patterns",
+	func(pattern, image string, want bool) {
+		Expect(matchImage(pattern, image)).To(Equal(want))
+	},
+	Entry("exact image", "nginx", "nginx", true),
+	Entry("exact image with another tag", "nginx", "nginx:1.27", false),
+	Entry("registry prefix across path segments", "registry.example.com/*", "registry.example.com/team/app:1.0", true),
+	Entry("other registry", "registry.example.com/*", "docker.io/library/nginx", false),
+	Entry("any tag", "docker.io/library/nginx:*", "docker.io/library/nginx:1.27", true),
+	Entry("several wildcards", "*/team/*:v*", "registry.example.com/team/app:v2", true),
+	Entry("several wildcards out of order", "*/team/*:v*", "registry.example.com/app/team:latest", false),
+	Entry("overlapping prefix and suffix", "ab*ba", "aba", false),
+)
+
+// ignoredPods scrapes the ignored pods counter for a reason from the metrics registry
+func ignoredPods(reason string) float64 {
+	families, err := metrics.Registry.Gather()
+	Expect(err).NotTo(HaveOccurred())
+	for _, f := range families {
+		if f.GetName() != "spoditor_pods_ignored_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "reason" && l.GetValue() == reason {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
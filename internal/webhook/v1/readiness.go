@@ -0,0 +1,42 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+)
+
+// handlersCheckName names the readiness check of the registered handlers
+const handlersCheckName = "handlers"
+
+// checkHandlers is a readiness check failing while the mutator has no
+// handlers, or a handler without a parser, so a misconfigured handler list
+// shows at boot rather than when a pod fails to mutate
+func (m *PodMutator) checkHandlers(_ *http.Request) error {
+	if len(m.handlers) == 0 {
+		return errors.New("no handlers registered")
+	}
+
+	var errs []error
+	for _, h := range m.handlers {
+		if !hasParser(h) {
+			errs = append(errs, fmt.Errorf("handler %s has no parser", annotation.HandlerName(h)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hasParser reports whether the handler returns a usable parser, which a nil
+// ParserFunc wrapped in the Parser interface isn't
+func hasParser(h annotation.Handler) bool {
+	p := h.GetParser()
+	if p == nil {
+		return false
+	}
+	if f, ok := p.(annotation.ParserFunc); ok && f == nil {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"github.com/golem-base/spoditor/internal/annotation"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Handler readiness check", func() {
+	It("Should report ready with the built-in handlers", func() {
+		mutator := &PodMutator{handlers: builtinHandlers()}
+		Expect(mutator.checkHandlers(nil)).To(Succeed())
+	})
+
+	It("Should report not ready without handlers", func() {
+		mutator := &PodMutator{}
+		Expect(mutator.checkHandlers(nil)).To(MatchError(ContainSubstring("no handlers")))
+	})
+
+	It("Should report not ready with a handler without a parser", func() {
+		mutator := &PodMutator{handlers: []annotation.Handler{&nilParserHandler{}}}
+		Expect(mutator.checkHandlers(nil)).To(MatchError(ContainSubstring("nil-parser")))
+	})
+
+	It("Should report not ready with a handler returning a nil parser function", func() {
+		mutator := &PodMutator{handlers: []annotation.Handler{&nilParserHandler{typed: true}}}
+		Expect(mutator.checkHandlers(nil)).To(MatchError(ContainSubstring("has no parser")))
+	})
+})
+
+// nilParserHandler is a handler whose parser is missing, either as a nil
+// interface or as a nil ParserFunc
+type nilParserHandler struct {
+	typed bool
+}
+
+func (h *nilParserHandler) Mutate(*corev1.PodSpec, annotation.MutationContext, any) error {
+	return nil
+}
+
+func (h *nilParserHandler) GetParser() annotation.Parser {
+	if h.typed {
+		var f annotation.ParserFunc
+		return f
+	}
+	return nil
+}
+
+func (h *nilParserHandler) Name() string {
+	return "nil-parser"
+}
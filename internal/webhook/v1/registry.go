@@ -0,0 +1,196 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/affinity"
+	"github.com/golem-base/spoditor/internal/annotation/deadline"
+	"github.com/golem-base/spoditor/internal/annotation/dnsconfig"
+	"github.com/golem-base/spoditor/internal/annotation/downwardenv"
+	"github.com/golem-base/spoditor/internal/annotation/env"
+	"github.com/golem-base/spoditor/internal/annotation/envremove"
+	"github.com/golem-base/spoditor/internal/annotation/goruntime"
+	"github.com/golem-base/spoditor/internal/annotation/hostaliases"
+	"github.com/golem-base/spoditor/internal/annotation/imagepullsecrets"
+	"github.com/golem-base/spoditor/internal/annotation/labels"
+	"github.com/golem-base/spoditor/internal/annotation/orderedstart"
+	"github.com/golem-base/spoditor/internal/annotation/ports"
+	"github.com/golem-base/spoditor/internal/annotation/priority"
+	"github.com/golem-base/spoditor/internal/annotation/probes"
+	"github.com/golem-base/spoditor/internal/annotation/resourceclaims"
+	"github.com/golem-base/spoditor/internal/annotation/resources"
+	"github.com/golem-base/spoditor/internal/annotation/securitycontext"
+	"github.com/golem-base/spoditor/internal/annotation/serviceaccount"
+	"github.com/golem-base/spoditor/internal/annotation/sidecars"
+	"github.com/golem-base/spoditor/internal/annotation/tolerations"
+	"github.com/golem-base/spoditor/internal/annotation/volumes"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// HandlersConfigMapKey is the key of the handler ConfigMap data listing the
+// names of the enabled handlers in the order they are applied
+const HandlersConfigMapKey = "handlers"
+
+// builtinHandlers returns all handlers shipped with spoditor in the order they are applied
+func builtinHandlers() []annotation.Handler {
+	return []annotation.Handler{
+		&volumes.MountHandler{},
+		&ports.HostPortHandler{},
+		&affinity.NodeAffinityHandler{},
+		&affinity.LeaderAffinityHandler{},
+		&tolerations.TolerationsHandler{},
+		&hostaliases.HostAliasesHandler{},
+		&labels.OrdinalLabelHandler{},
+		&probes.ProbesHandler{},
+		&probes.StartupProbeHandler{},
+		&sidecars.SidecarsHandler{},
+		&downwardenv.DownwardEnvHandler{},
+		&env.EnvHandler{},
+		&deadline.ActiveDeadlineHandler{},
+		&resources.ResourcesHandler{},
+		&securitycontext.SecurityContextHandler{},
+		&imagepullsecrets.ImagePullSecretsHandler{},
+		&priority.PriorityTierHandler{},
+		&priority.PriorityClassHandler{},
+		&dnsconfig.DNSConfigHandler{},
+		&serviceaccount.ServiceAccountHandler{},
+		&labels.StatefulSetLabelsHandler{},
+		&goruntime.GoRuntimeHandler{},
+		&downwardenv.OrdinalFileHandler{},
+		&resourceclaims.ResourceClaimsHandler{},
+		&orderedstart.OrderedStartHandler{},
+		&envremove.EnvRemoveHandler{},
+	}
+}
+
+// enabledHandlers filters the built-in handlers down to the named ones, keeping
+// the built-in order. An empty list enables every built-in handler.
+func enabledHandlers(names []string) ([]annotation.Handler, error) {
+	all := builtinHandlers()
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	enabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		enabled[name] = true
+	}
+
+	var result []annotation.Handler
+	for _, h := range all {
+		name := annotation.HandlerName(h)
+		if !enabled[name] {
+			podlog.Info("Handler not enabled, skipping registration", "handler", name)
+			continue
+		}
+		delete(enabled, name)
+		result = append(result, h)
+	}
+
+	// Anything left over doesn't name a built-in handler
+	for _, name := range names {
+		if enabled[name] {
+			return nil, fmt.Errorf("unknown handler %q", name)
+		}
+	}
+
+	return result, nil
+}
+
+// withCustomHandlers appends custom handlers, e.g. of a program embedding the
+// webhook, to the enabled ones. A custom handler named like one already
+// registered is rejected, since the same annotations would configure both.
+func withCustomHandlers(handlers, custom []annotation.Handler) ([]annotation.Handler, error) {
+	if len(custom) == 0 {
+		return handlers, nil
+	}
+
+	names := make(map[string]bool, len(handlers)+len(custom))
+	for _, h := range handlers {
+		names[annotation.HandlerName(h)] = true
+	}
+
+	result := slices.Clone(handlers)
+	for _, h := range custom {
+		if h == nil {
+			return nil, fmt.Errorf("custom handler must not be nil")
+		}
+		name := annotation.HandlerName(h)
+		if names[name] {
+			return nil, fmt.Errorf("custom handler %q is already registered", name)
+		}
+		names[name] = true
+		podlog.Info("Registering custom handler", "handler", name)
+		result = append(result, h)
+	}
+	return result, nil
+}
+
+// configuredHandlers returns the handlers listed in the handler ConfigMap, in
+// the listed order. Without a ConfigMap, or when it doesn't exist, it falls
+// back to the handlers enabled by name.
+func configuredHandlers(ctx context.Context, reader client.Reader, configMap types.NamespacedName, enabled []string) ([]annotation.Handler, error) {
+	if configMap.Name == "" {
+		return enabledHandlers(enabled)
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := reader.Get(ctx, configMap, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			podlog.Info("Handler ConfigMap not found, using the default handlers", "configMap", configMap)
+			return enabledHandlers(enabled)
+		}
+		return nil, fmt.Errorf("reading handler ConfigMap %s: %w", configMap, err)
+	}
+
+	// Names may be separated by commas or newlines
+	var names []string
+	for _, name := range strings.FieldsFunc(cm.Data[HandlersConfigMapKey], func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("handler ConfigMap %s lists no handlers under %q", configMap, HandlersConfigMapKey)
+	}
+
+	handlers, err := orderedHandlers(names)
+	if err != nil {
+		return nil, fmt.Errorf("handler ConfigMap %s: %w", configMap, err)
+	}
+	podlog.Info("Using the handlers of the handler ConfigMap", "configMap", configMap, "handlers", names)
+	return handlers, nil
+}
+
+// orderedHandlers returns the named built-in handlers in the given order
+func orderedHandlers(names []string) ([]annotation.Handler, error) {
+	builtin := make(map[string]annotation.Handler)
+	for _, h := range builtinHandlers() {
+		builtin[annotation.HandlerName(h)] = h
+	}
+
+	result := make([]annotation.Handler, 0, len(names))
+	for _, name := range names {
+		h, ok := builtin[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown handler %q", name)
+		}
+		if h == nil {
+			return nil, fmt.Errorf("handler %q listed twice", name)
+		}
+		// Mark the handler as taken
+		builtin[name] = nil
+		result = append(result, h)
+	}
+	return result, nil
+}
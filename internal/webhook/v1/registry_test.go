@@ -0,0 +1,200 @@
+package v1
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+	"github.com/golem-base/spoditor/internal/annotation/ports"
+	"github.com/golem-base/spoditor/internal/annotation/probes"
+	"github.com/golem-base/spoditor/internal/annotation/volumes"
+	"github.com/golem-base/spoditor/internal/identifier"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Handler registry", func() {
+	It("Should enable all built-in handlers when no names are given", func() {
+		handlers, err := enabledHandlers(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handlers).To(HaveLen(len(builtinHandlers())))
+	})
+
+	It("Should only enable the named handlers in built-in order", func() {
+		handlers, err := enabledHandlers([]string{ports.HostPort, volumes.MountVolume})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handlers).To(HaveLen(2))
+		Expect(annotation.HandlerName(handlers[0])).To(Equal(volumes.MountVolume))
+		Expect(annotation.HandlerName(handlers[1])).To(Equal(ports.HostPort))
+	})
+
+	It("Should reject unknown handler names", func() {
+		_, err := enabledHandlers([]string{"no-such-handler"})
+		Expect(err).To(MatchError(ContainSubstring("no-such-handler")))
+	})
+
+	It("Should not apply excluded handlers", func() {
+		handlers, err := enabledHandlers([]string{volumes.MountVolume})
+		Expect(err).NotTo(HaveOccurred())
+
+		mutator := &PodMutator{
+			ssPodId:   identifier.LabelSSPodIdentifier,
+			collector: annotation.Collector,
+			handlers:  handlers,
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-pod",
+				Namespace: "default",
+				Labels: map[string]string{
+					"statefulset.kubernetes.io/pod-name": "test-statefulset-2",
+				},
+				Annotations: map[string]string{
+					"spoditor.io/host-port": `{"containers":[{"name":"test-container","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`,
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "test-container", Image: "nginx"}},
+			},
+		}
+
+		Expect(mutator.Default(context.Background(), pod)).To(Succeed())
+		Expect(pod.Spec.Containers[0].Ports).To(BeEmpty())
+		Expect(pod.Spec.Containers[0].Env).To(BeEmpty())
+	})
+	It("Should register every handler package under the module path", func() {
+		goMod, err := os.ReadFile(filepath.Join("..", "..", "..", "go.mod"))
+		Expect(err).NotTo(HaveOccurred())
+		first, _, _ := strings.Cut(string(goMod), "\n")
+		module, ok := strings.CutPrefix(first, "module ")
+		Expect(ok).To(BeTrue(), "go.mod doesn't start with the module path")
+
+		file, err := parser.ParseFile(token.NewFileSet(), "registry.go", nil, parser.ImportsOnly)
+		Expect(err).NotTo(HaveOccurred())
+		imported := map[string]bool{}
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			Expect(err).NotTo(HaveOccurred())
+			imported[path] = true
+		}
+
+		// Every handler package is a subdirectory of internal/annotation,
+		// except the test helpers
+		entries, err := os.ReadDir(filepath.Join("..", "..", "annotation"))
+		Expect(err).NotTo(HaveOccurred())
+		for _, e := range entries {
+			if !e.IsDir() || e.Name() == "annotationtest" {
+				continue
+			}
+			Expect(imported).To(HaveKey(module+"/internal/annotation/"+e.Name()),
+				"handler package %s is not registered", e.Name())
+		}
+	})
+
+	It("Should run custom handlers after the built-in ones", func() {
+		builtin, err := enabledHandlers([]string{volumes.MountVolume})
+		Expect(err).NotTo(HaveOccurred())
+		custom := &annotatingHandler{}
+		handlers, err := withCustomHandlers(builtin, []annotation.Handler{custom})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handlers).To(HaveLen(2))
+		Expect(handlers[1]).To(BeIdenticalTo(custom))
+
+		mutator := &PodMutator{
+			ssPodId:   identifier.LabelSSPodIdentifier,
+			collector: annotation.Collector,
+			handlers:  handlers,
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-pod",
+				Namespace: "default",
+				Labels: map[string]string{
+					"statefulset.kubernetes.io/pod-name": "test-statefulset-2",
+				},
+				Annotations: map[string]string{
+					"spoditor.io/annotate": "custom",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "test-container", Image: "nginx"}},
+			},
+		}
+
+		Expect(mutator.Default(context.Background(), pod)).To(Succeed())
+		Expect(custom.specMutated).To(BeTrue())
+		Expect(pod.Annotations).To(HaveKeyWithValue("example.com/ordinal-2", "custom"))
+	})
+
+	It("Should reject custom handlers clashing with registered ones", func() {
+		builtin, err := enabledHandlers(nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = withCustomHandlers(builtin, []annotation.Handler{&volumes.MountHandler{}})
+		Expect(err).To(MatchError(ContainSubstring(volumes.MountVolume)))
+
+		_, err = withCustomHandlers(builtin, []annotation.Handler{nil})
+		Expect(err).To(MatchError(ContainSubstring("must not be nil")))
+	})
+
+	Context("With a handler ConfigMap", func() {
+		key := types.NamespacedName{Namespace: "spoditor-system", Name: "spoditor-handlers"}
+
+		readerWith := func(data map[string]string) client.Reader {
+			return fake.NewClientBuilder().
+				WithScheme(clientgoscheme.Scheme).
+				WithObjects(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+					Data:       data,
+				}).
+				Build()
+		}
+		names := func(handlers []annotation.Handler) []string {
+			var result []string
+			for _, h := range handlers {
+				result = append(result, annotation.HandlerName(h))
+			}
+			return result
+		}
+
+		It("Should enable the listed handlers in the listed order", func() {
+			reader := readerWith(map[string]string{HandlersConfigMapKey: "probes\nhost-port, mount-volume\n"})
+			handlers, err := configuredHandlers(context.Background(), reader, key, []string{ports.HostPort})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(handlers)).To(Equal([]string{probes.Probes, ports.HostPort, volumes.MountVolume}))
+		})
+
+		It("Should fall back to the enabled handlers when the ConfigMap doesn't exist", func() {
+			reader := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+			handlers, err := configuredHandlers(context.Background(), reader, key, []string{ports.HostPort})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(handlers)).To(Equal([]string{ports.HostPort}))
+		})
+
+		It("Should reject unknown and duplicate handlers", func() {
+			_, err := configuredHandlers(context.Background(), readerWith(map[string]string{HandlersConfigMapKey: "no-such-handler"}), key, nil)
+			Expect(err).To(MatchError(ContainSubstring("no-such-handler")))
+
+			_, err = configuredHandlers(context.Background(), readerWith(map[string]string{HandlersConfigMapKey: "probes,probes"}), key, nil)
+			Expect(err).To(MatchError(ContainSubstring("listed twice")))
+		})
+
+		It("Should reject a ConfigMap listing no handlers", func() {
+			_, err := configuredHandlers(context.Background(), readerWith(nil), key, nil)
+			Expect(err).To(MatchError(ContainSubstring("lists no handlers")))
+		})
+	})
+})
@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"encoding/json"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+var _ = Describe("Handler schemas", func() {
+	// schemaOf returns the parsed JSON Schema of the named built-in handler
+	schemaOf := func(name string) *spec.Schema {
+		for _, h := range builtinHandlers() {
+			if annotation.HandlerName(h) != name {
+				continue
+			}
+			sh, ok := h.(annotation.SchemaHandler)
+			Expect(ok).To(BeTrue(), "%s has no schema", name)
+
+			schema := &spec.Schema{}
+			Expect(json.Unmarshal(sh.Schema(), schema)).To(Succeed())
+			return schema
+		}
+		Fail("no built-in handler named " + name)
+		return nil
+	}
+
+	It("Should expose a valid JSON Schema for every built-in handler", func() {
+		for _, h := range builtinHandlers() {
+			name := annotation.HandlerName(h)
+			sh, ok := h.(annotation.SchemaHandler)
+			Expect(ok).To(BeTrue(), "%s has no schema", name)
+
+			var doc map[string]any
+			Expect(json.Unmarshal(sh.Schema(), &doc)).To(Succeed(), "%s schema is not JSON", name)
+			Expect(doc).To(HaveKeyWithValue("$schema", "http://json-schema.org/draft-04/schema#"))
+			Expect(doc).To(HaveKeyWithValue("title", name))
+
+			schema := &spec.Schema{}
+			Expect(json.Unmarshal(sh.Schema(), schema)).To(Succeed(), "%s schema is not a JSON Schema", name)
+			// A value taking several forms lists each with its own type
+			if len(schema.OneOf) > 0 {
+				Expect(schema.Type).To(BeEmpty(), "%s schema has a type besides its forms", name)
+				for _, form := range schema.OneOf {
+					Expect(form.Type).To(HaveLen(1), "%s schema has a form without a single type", name)
+				}
+				continue
+			}
+			Expect(schema.Type).To(HaveLen(1), "%s schema has no single type", name)
+		}
+	})
+
+	DescribeTable("Validating sample payloads",
+		func(name, payload string, valid bool) {
+			var data any
+			Expect(json.Unmarshal([]byte(payload), &data)).To(Succeed())
+
+			err := validate.AgainstSchema(schemaOf(name), data, strfmt.Default)
+			if valid {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+		Entry("mount-volume accepts volumes and mounts", "mount-volume",
+			`{"volumes":[{"name":"v","secret":{"secretName":"s"}}],"containers":[{"name":"c","volumeMounts":[{"name":"v","mountPath":"/v"}]}]}`, true),
+		Entry("mount-volume rejects a mount without a path", "mount-volume",
+			`{"containers":[{"name":"c","volumeMounts":[{"name":"v"}]}]}`, false),
+		Entry("mount-volume accepts ordinal overrides", "mount-volume",
+			`{"volumes":[{"name":"v","emptyDir":{}}],"overrides":[{"qualifier":"0","volumes":[{"name":"v","persistentVolumeClaim":{"claimName":"c"}}]}]}`, true),
+		Entry("mount-volume rejects an override without a qualifier", "mount-volume",
+			`{"volumes":[{"name":"v","emptyDir":{}}],"overrides":[{"volumes":[{"name":"v","emptyDir":{}}]}]}`, false),
+		Entry("mount-volume accepts a mount propagation mode", "mount-volume",
+			`{"containers":[{"name":"c","volumeMounts":[{"name":"v","mountPath":"/v","mountPropagation":"Bidirectional"}]}]}`, true),
+		Entry("mount-volume rejects an unknown mount propagation mode", "mount-volume",
+			`{"containers":[{"name":"c","volumeMounts":[{"name":"v","mountPath":"/v","mountPropagation":"Shared"}]}]}`, false),
+		Entry("host-port accepts ports", "host-port",
+			`{"containers":[{"name":"c","ports":[{"name":"http","containerPort":8080,"hostPort":30000}]}]}`, true),
+		Entry("host-port rejects an out of range port", "host-port",
+			`{"containers":[{"name":"c","ports":[{"containerPort":70000}]}]}`, false),
+		Entry("host-port accepts a port env prefix", "host-port", `{"portEnvPrefix":"SVC_PORT_","containers":[]}`, true),
+		Entry("host-port rejects an invalid port env prefix", "host-port", `{"portEnvPrefix":"SVC-PORT-"}`, false),
+		Entry("host-port rejects the container wildcard", "host-port", `{"containers":[{"name":"*"}]}`, false),
+		Entry("host-port accepts an ordinal port map", "host-port", `{"ordinalPortMap":{"0":30000,"1":30100}}`, true),
+		Entry("host-port rejects an ordinal port map keyed by name", "host-port", `{"ordinalPortMap":{"leader":30000}}`, false),
+		Entry("host-port accepts a host port range", "host-port", `{"containers":[{"name":"media","ports":[{"name":"rtp","containerPort":20000,"hostPort":40000,"hostPortRangeSize":10}]}]}`, true),
+		Entry("host-port accepts offset container ports", "host-port", `{"containers":[{"name":"web","offsetContainerPort":true,"ports":[{"name":"http","containerPort":8080}]}]}`, true),
+		Entry("host-port rejects an empty host port range", "host-port", `{"containers":[{"name":"media","ports":[{"name":"rtp","containerPort":20000,"hostPort":40000,"hostPortRangeSize":0}]}]}`, false),
+		Entry("ordinal-node-affinity accepts a label key", "ordinal-node-affinity", `"example.com/shard"`, true),
+		Entry("ordinal-node-affinity rejects a blank label key", "ordinal-node-affinity", `" "`, false),
+		Entry("leader-affinity accepts a weight", "leader-affinity", `{"weight":50}`, true),
+		Entry("leader-affinity rejects a weight over 100", "leader-affinity", `{"weight":150}`, false),
+		Entry("tolerations accepts a toleration", "tolerations", `[{"key":"pool","operator":"Exists"}]`, true),
+		Entry("tolerations rejects an unknown operator", "tolerations", `[{"key":"pool","operator":"Maybe"}]`, false),
+		Entry("tolerations accepts scaled toleration seconds", "tolerations", `[{"key":"pool","operator":"Exists","tolerationSeconds":{"base":60,"step":30}}]`, true),
+		Entry("tolerations rejects a scale without base", "tolerations", `[{"key":"pool","operator":"Exists","tolerationSeconds":{"step":30}}]`, false),
+		Entry("tolerations accepts clearing", "tolerations", `{"clear":true,"tolerations":[{"key":"pool","operator":"Exists"}]}`, true),
+		Entry("tolerations rejects an unknown operator when clearing", "tolerations", `{"clear":true,"tolerations":[{"key":"pool","operator":"Maybe"}]}`, false),
+		Entry("host-aliases accepts an alias", "host-aliases", `[{"ip":"10.0.0.1","hostnames":["peer"]}]`, true),
+		Entry("host-aliases rejects an alias without ip", "host-aliases", `[{"hostnames":["peer"]}]`, false),
+		Entry("inject-ordinal-label accepts a label key", "inject-ordinal-label", `"pod-ordinal"`, true),
+		Entry("inject-ordinal-label rejects an object", "inject-ordinal-label", `{}`, false),
+		Entry("probes accepts a scaled initial delay", "probes",
+			`{"containers":[{"name":"c","readinessProbe":{"initialDelaySeconds":{"base":5,"step":10}}}]}`, true),
+		Entry("probes rejects a scale without base", "probes",
+			`{"containers":[{"name":"c","readinessProbe":{"initialDelaySeconds":{"step":10}}}]}`, false),
+		Entry("probes accepts a startup probe with a scaled failure threshold", "probes",
+			`{"containers":[{"name":"c","startupProbe":{"httpGet":{"path":"/","port":80},"failureThreshold":{"base":30,"step":10}}}]}`, true),
+		Entry("sidecars accepts a container", "sidecars", `[{"name":"logger","image":"fluent-bit"}]`, true),
+		Entry("sidecars rejects a container without name", "sidecars", `[{"image":"fluent-bit"}]`, false),
+		Entry("downward-env accepts container names", "downward-env", `"app,sidecar"`, true),
+		Entry("downward-env rejects an empty list", "downward-env", `" , "`, false),
+		Entry("env accepts env vars", "env", `{"containers":[{"name":"c","env":[{"name":"N","value":"node-{{ordinal}}"}]}]}`, true),
+		Entry("env rejects an env var without name", "env", `{"containers":[{"name":"c","env":[{"value":"v"}]}]}`, false),
+		Entry("active-deadline accepts a scale", "active-deadline", `{"base":3600,"step":600,"min":900}`, true),
+		Entry("active-deadline rejects a missing base", "active-deadline", `{"step":600}`, false),
+		Entry("resources accepts guaranteed", "resources", `{"guaranteed":true,"containers":["app"]}`, true),
+		Entry("resources rejects no option", "resources", `{"guaranteed":false}`, false),
+		Entry("resources accepts limit expressions", "resources", `{"limits":{"cpu":"500m + {{ordinal}} * 250m"}}`, true),
+		Entry("security-context accepts pod and container contexts", "security-context", `{"pod":{"fsGroup":2000},"containers":[{"name":"app","securityContext":{"runAsUser":1000}}]}`, true),
+		Entry("security-context rejects a container without a context", "security-context", `{"containers":[{"name":"app"}]}`, false),
+		Entry("security-context accepts ordinal-scaled IDs", "security-context", `{"pod":{"runAsUser":{"base":10000,"step":1}}}`, true),
+		Entry("security-context rejects a scaled ID without a base", "security-context", `{"pod":{"fsGroup":{"step":1}}}`, false),
+		Entry("image-pull-secrets accepts secret names", "image-pull-secrets", `{"secrets":["regcred"],"ordinalSuffix":true}`, true),
+		Entry("image-pull-secrets rejects an empty secret name", "image-pull-secrets", `{"secrets":[""]}`, false),
+		Entry("priority-tier accepts tiers", "priority-tier", `{"tiers":[{"ordinals":"0-2","priorityClassName":"high"},{"ordinals":"3-","priorityClassName":"normal"}]}`, true),
+		Entry("priority-tier rejects a tier without a class", "priority-tier", `{"tiers":[{"ordinals":"0-2"}]}`, false),
+		Entry("priority-class accepts a class", "priority-class", `{"priorityClassName":"high-priority","override":true}`, true),
+		Entry("priority-class rejects a missing class", "priority-class", `{"override":true}`, false),
+		Entry("dns-config accepts templated searches", "dns-config", `{"searches":["{{ssName}}.default.svc.cluster.local"],"options":[{"name":"ndots","value":"2"}]}`, true),
+		Entry("dns-config rejects an option without a name", "dns-config", `{"options":[{"value":"2"}]}`, false),
+		Entry("service-account accepts an account", "service-account", `{"serviceAccountName":"worker","ordinalSuffix":true}`, true),
+		Entry("service-account rejects a missing account", "service-account", `{"override":true}`, false),
+		Entry("statefulset-labels accepts label keys", "statefulset-labels", `["team","example.com/monitor"]`, true),
+		Entry("statefulset-labels rejects an empty list", "statefulset-labels", `[]`, false),
+		Entry("go-runtime accepts containers", "go-runtime", `{"containers":["app"],"memoryLimitPercent":90}`, true),
+		Entry("go-runtime rejects a memory share above 100", "go-runtime", `{"containers":["app"],"memoryLimitPercent":120}`, false),
+		Entry("ordinal-file accepts containers", "ordinal-file", `{"containers":["app"],"mountPath":"/etc/pod"}`, true),
+		Entry("ordinal-file rejects a relative mount path", "ordinal-file", `{"containers":["app"],"mountPath":"etc/pod"}`, false),
+		Entry("resource-claims accepts claims", "resource-claims", `{"claims":[{"name":"gpu","resourceClaimName":"gpu-{{ordinal}}"}],"containers":[{"name":"app","claims":[{"name":"gpu"}]}]}`, true),
+		Entry("resource-claims rejects a claim without a name", "resource-claims", `{"claims":[{"resourceClaimName":"gpu"}]}`, false),
+		Entry("ordered-start accepts a service name", "ordered-start", `{"serviceName":"db-headless","intervalSeconds":5}`, true),
+		Entry("ordered-start rejects a zero interval", "ordered-start", `{"intervalSeconds":0}`, false),
+		Entry("env-remove accepts env var names", "env-remove", `{"containers":[{"name":"app","env":["TERM"]}]}`, true),
+		Entry("env-remove rejects a container without env vars", "env-remove", `{"containers":[{"name":"app","env":[]}]}`, false),
+		Entry("startup-probe accepts a scaled failure threshold", "startup-probe", `{"containers":[{"name":"app","startupProbe":{"failureThreshold":{"base":60,"step":-30,"min":10}}}]}`, true),
+		Entry("startup-probe rejects a container without a probe", "startup-probe", `{"containers":[{"name":"app"}]}`, false),
+	)
+})
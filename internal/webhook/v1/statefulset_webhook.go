@@ -0,0 +1,161 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// stslog is for logging in the statefulset webhook.
+var stslog = logf.Log.WithName("statefulset-webhook")
+
+// SetupStatefulSetWebhookWithManager registers the validating webhook for StatefulSet in the manager.
+// Annotations are validated against the handlers and read with the collector
+// opts configure, the same options the pod webhook is set up with.
+func SetupStatefulSetWebhookWithManager(mgr ctrl.Manager, opts PodMutatorOptions) error {
+	stslog.Info("Setting up statefulset validating webhook")
+
+	handlers, err := opts.handlers(context.Background(), mgr.GetAPIReader())
+	if err != nil {
+		return err
+	}
+
+	validator := &StatefulSetValidator{
+		collector: opts.collector(),
+		handlers:  handlers,
+	}
+
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&appsv1.StatefulSet{}).
+		WithValidator(validator).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-apps-v1-statefulset,mutating=false,failurePolicy=ignore,sideEffects=None,groups=apps,resources=statefulsets,verbs=create;update,versions=v1,name=vstatefulset.spoditor.io,admissionReviewVersions=v1
+
+// StatefulSetValidator rejects StatefulSets whose pod template carries spoditor
+// annotations that no handler understands, or that leave ordinals uncovered
+// when full coverage is required
+type StatefulSetValidator struct {
+	handlers  []annotation.Handler
+	collector annotation.QualifiedAnnotationCollector
+}
+
+var _ webhook.CustomValidator = &StatefulSetValidator{}
+
+// ValidateCreate implements webhook.CustomValidator
+func (v *StatefulSetValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(obj)
+}
+
+// ValidateUpdate implements webhook.CustomValidator
+func (v *StatefulSetValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator
+func (v *StatefulSetValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate checks every spoditor annotation on the pod template names a known
+// feature, has a valid qualifier and parses, reporting the offending annotation
+// with a suggested fix
+func (v *StatefulSetValidator) validate(obj runtime.Object) error {
+	sts, ok := obj.(*appsv1.StatefulSet)
+	if !ok {
+		return fmt.Errorf("expected a StatefulSet but got %T", obj)
+	}
+
+	l := stslog.WithValues("namespace", sts.Namespace, "name", sts.Name)
+
+	var errs field.ErrorList
+
+	// Qualifier sets live on the StatefulSet itself rather than its pod template
+	var sets map[string]string
+	setsKey := annotation.KeyOf(v.collector, annotation.QualifiedName{Name: annotation.QualifierSets})
+	if value, ok := sts.Annotations[setsKey]; ok {
+		var err error
+		if sets, err = annotation.ParseQualifierSets(value, annotation.SeparatorOf(v.collector)); err != nil {
+			path := field.NewPath("metadata", "annotations").Key(setsKey)
+			errs = append(errs, field.Invalid(path, value, err.Error()))
+		}
+	}
+
+	annotations := v.collector.Collect(&sts.Spec.Template)
+	// Turning mutation off is no feature
+	delete(annotations, annotation.QualifiedName{Name: Disabled})
+
+	features := make([]string, 0, len(v.handlers))
+	byName := make(map[string]annotation.Handler, len(v.handlers))
+	for _, h := range v.handlers {
+		name := annotation.HandlerName(h)
+		features = append(features, name)
+		byName[name] = h
+	}
+
+	// Visit annotations in key order so the reported errors are stable
+	keys := annotation.SortedKeys(annotations)
+
+	annotationsPath := field.NewPath("spec", "template", "metadata", "annotations")
+
+	// Annotations of a feature resolving to the same qualifier can't both apply
+	resolvedFrom := make(map[annotation.QualifiedName]annotation.QualifiedName, len(keys))
+
+	for _, k := range keys {
+		value := annotations[k]
+		path := annotationsPath.Key(annotation.KeyOf(v.collector, k))
+
+		h, ok := byName[k.Name]
+		if !ok {
+			msg := fmt.Sprintf("unknown spoditor feature %q", k.Name)
+			if suggestion, ok := annotation.Suggest(k.Name, features); ok {
+				msg = fmt.Sprintf("%s, did you mean %q?", msg, suggestion)
+			}
+			errs = append(errs, field.Invalid(path, value, msg))
+			continue
+		}
+
+		// Named qualifier sets are checked by what they stand for
+		qualifier := k.Qualifier
+		if resolved, ok := sets[qualifier]; ok {
+			qualifier = resolved
+		}
+		if err := annotation.ValidateQualifier(qualifier); err != nil {
+			errs = append(errs, field.Invalid(path, value, err.Error()))
+			continue
+		}
+		resolved := annotation.QualifiedName{Name: k.Name, Qualifier: qualifier}
+		if other, ok := resolvedFrom[resolved]; ok {
+			msg := fmt.Sprintf("resolves to qualifier %q like %q", qualifier, annotation.KeyOf(v.collector, other))
+			errs = append(errs, field.Invalid(path, value, msg))
+			continue
+		}
+		resolvedFrom[resolved] = k
+
+		// Parse each annotation on its own so every bad one gets reported
+		single := map[annotation.QualifiedName]string{k: value}
+		if _, err := h.GetParser().Parse(single); err != nil {
+			errs = append(errs, field.Invalid(path, value, err.Error()))
+		}
+	}
+
+	errs = append(errs, v.validateCoverage(sts, annotations, sets)...)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	l.Info("Rejecting StatefulSet with invalid annotations", "errors", errs.ToAggregate().Error())
+	return apierrors.NewInvalid(appsv1.SchemeGroupVersion.WithKind("StatefulSet").GroupKind(), sts.Name, errs)
+}
@@ -0,0 +1,236 @@
+package v1
+
+import (
+	"context"
+
+	"github.com/golem-base/spoditor/internal/annotation"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+var _ = Describe("StatefulSet Webhook", func() {
+	var (
+		ctx       context.Context
+		validator *StatefulSetValidator
+		sts       *appsv1.StatefulSet
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		validator = &StatefulSetValidator{
+			collector: annotation.Collector,
+			handlers:  builtinHandlers(),
+		}
+		sts = &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web",
+				Namespace: "default",
+			},
+		}
+	})
+
+	Context("When validating StatefulSets", func() {
+		It("Should admit StatefulSets without spoditor annotations", func() {
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should admit valid annotations", func() {
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/tolerations_3-": `[{"key":"pool","operator":"Exists"}]`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should admit the annotation disabling mutation", func() {
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/disabled":       "true",
+				"spoditor.io/tolerations_3-": `[{"key":"pool","operator":"Exists"}]`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should suggest the closest feature for a near-miss typo", func() {
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/mount-volumes_0": `{}`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`spec.template.metadata.annotations[spoditor.io/mount-volumes_0]`))
+			Expect(err.Error()).To(ContainSubstring(`did you mean "mount-volume"?`))
+		})
+
+		It("Should not suggest anything for an unrelated feature", func() {
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/frobnicate": `{}`,
+			}
+			_, err := validator.ValidateUpdate(ctx, sts, sts)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`unknown spoditor feature "frobnicate"`))
+			Expect(err.Error()).NotTo(ContainSubstring("did you mean"))
+		})
+
+		It("Should report annotations that fail to parse", func() {
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/host-port": `{"containers":[`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`spec.template.metadata.annotations[spoditor.io/host-port]`))
+		})
+
+		It("Should admit annotations referencing qualifier sets", func() {
+			sts.Annotations = map[string]string{
+				"spoditor.io/qualifier-sets": `{"canary":"0,2"}`,
+			}
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/tolerations_canary": `[{"key":"pool","operator":"Exists"}]`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should report malformed qualifiers", func() {
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/tolerations_1-2-3": `[{"key":"pool","operator":"Exists"}]`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`invalid qualifier "1-2-3"`))
+		})
+
+		It("Should admit CEL qualifiers from qualifier sets", func() {
+			sts.Annotations = map[string]string{
+				"spoditor.io/qualifier-sets": `{"thirds":"cel:ordinal % 3 == 0"}`,
+			}
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/tolerations_thirds": `[{"key":"pool","operator":"Exists"}]`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should report CEL qualifiers that don't compile", func() {
+			sts.Annotations = map[string]string{
+				"spoditor.io/qualifier-sets": `{"thirds":"cel:ordinal %"}`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`invalid CEL qualifier "ordinal %"`))
+		})
+
+		It("Should report set names that are qualifiers themselves", func() {
+			sts.Annotations = map[string]string{
+				"spoditor.io/qualifier-sets": `{"3":"0-5"}`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`set name "3" is a qualifier`))
+		})
+
+		It("Should report annotations resolving to the same qualifier", func() {
+			sts.Annotations = map[string]string{
+				"spoditor.io/qualifier-sets": `{"first":"0"}`,
+			}
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/tolerations_0":     `[{"key":"pool","operator":"Exists"}]`,
+				"spoditor.io/tolerations_first": `[{"key":"pool","operator":"Exists"}]`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`spec.template.metadata.annotations[spoditor.io/tolerations_first]`))
+			Expect(err.Error()).To(ContainSubstring(`resolves to qualifier "0" like "spoditor.io/tolerations_0"`))
+		})
+
+		It("Should report invalid qualifier sets", func() {
+			sts.Annotations = map[string]string{
+				"spoditor.io/qualifier-sets": `{"canary":""}`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`metadata.annotations[spoditor.io/qualifier-sets]`))
+		})
+
+		It("Should admit annotations covering every ordinal when full coverage is required", func() {
+			sts.Spec.Replicas = ptr.To[int32](5)
+			sts.Annotations = map[string]string{
+				"spoditor.io/require-full-coverage": "true",
+				"spoditor.io/qualifier-sets":        `{"leader":"0"}`,
+			}
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/tolerations_leader": `[{"key":"pool","operator":"Exists"}]`,
+				"spoditor.io/tolerations_1-":     `[{"key":"pool","operator":"Exists"}]`,
+				"spoditor.io/priority-class":     `{"priorityClassName":"high"}`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should report ordinals left uncovered when full coverage is required", func() {
+			sts.Spec.Replicas = ptr.To[int32](5)
+			sts.Annotations = map[string]string{
+				"spoditor.io/require-full-coverage": "tolerations",
+			}
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/tolerations_0-1": `[{"key":"pool","operator":"Exists"}]`,
+				"spoditor.io/tolerations_4":   `[{"key":"pool","operator":"Exists"}]`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`metadata.annotations[spoditor.io/require-full-coverage]`))
+			Expect(err.Error()).To(ContainSubstring(`no "tolerations" annotation covers ordinals 2,3`))
+		})
+
+		It("Should read the full coverage switch as a boolean", func() {
+			sts.Spec.Replicas = ptr.To[int32](2)
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/tolerations_0": `[{"key":"pool","operator":"Exists"}]`,
+			}
+
+			for _, on := range []string{"True", "1"} {
+				sts.Annotations = map[string]string{"spoditor.io/require-full-coverage": on}
+				_, err := validator.ValidateCreate(ctx, sts)
+				Expect(err).To(HaveOccurred(), "for %q", on)
+				Expect(err.Error()).To(ContainSubstring(`no "tolerations" annotation covers ordinals 1`))
+			}
+			for _, off := range []string{"FALSE", "0"} {
+				sts.Annotations = map[string]string{"spoditor.io/require-full-coverage": off}
+				_, err := validator.ValidateCreate(ctx, sts)
+				Expect(err).NotTo(HaveOccurred(), "for %q", off)
+			}
+		})
+
+		It("Should count ordinals from the start ordinal", func() {
+			sts.Spec.Replicas = ptr.To[int32](2)
+			sts.Spec.Ordinals = &appsv1.StatefulSetOrdinals{Start: 3}
+			sts.Annotations = map[string]string{
+				"spoditor.io/require-full-coverage": "tolerations",
+			}
+			sts.Spec.Template.Annotations = map[string]string{
+				"spoditor.io/tolerations_3-": `[{"key":"pool","operator":"Exists"}]`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should validate annotations under a custom prefix", func() {
+			validator.collector = &annotation.PrefixedCollector{Prefix: "acme.example.com/"}
+			sts.Spec.Template.Annotations = map[string]string{
+				"acme.example.com/mount-volumes_0": `{}`,
+				"spoditor.io/not-a-feature":        `{}`,
+			}
+			_, err := validator.ValidateCreate(ctx, sts)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`spec.template.metadata.annotations[acme.example.com/mount-volumes_0]`))
+			Expect(err.Error()).NotTo(ContainSubstring("not-a-feature"))
+		})
+	})
+})
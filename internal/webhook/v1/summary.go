@@ -0,0 +1,73 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// podMutatePath is the path the pod webhook is served on, as generated by the
+// webhook builder for core/v1 Pods
+const podMutatePath = "/mutate--v1-pod"
+
+// summaryKey is the context key under which Default reports its summary
+type summaryKey struct{}
+
+// withSummary returns a context Default can report its summary to
+func withSummary(ctx context.Context) (context.Context, *string) {
+	s := new(string)
+	return context.WithValue(ctx, summaryKey{}, s), s
+}
+
+// setSummary reports the summary of a mutation, if the context accepts one
+func setSummary(ctx context.Context, summary string) {
+	if s, ok := ctx.Value(summaryKey{}).(*string); ok {
+		*s = summary
+	}
+}
+
+// summarize describes the applied handlers for the admission response,
+// e.g. "applied host-port, mount-volume for ordinal 2"
+func summarize(applied []string, ordinal int, dryRun bool) string {
+	verb := "applied"
+	if dryRun {
+		verb = "dry run, would have applied"
+	}
+	if len(applied) == 0 {
+		return fmt.Sprintf("%s no mutations for ordinal %d", verb, ordinal)
+	}
+	return fmt.Sprintf("%s %s for ordinal %d", verb, strings.Join(applied, ", "), ordinal)
+}
+
+// summarizingHandler sets the summary reported by the mutator as the message
+// of allowed admission responses, so it shows up in the API server audit log
+type summarizingHandler struct {
+	admission.Handler
+}
+
+// Handle implements admission.Handler
+func (h *summarizingHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	ctx, summary := withSummary(ctx)
+	resp := h.Handler.Handle(ctx, req)
+	if !resp.Allowed || *summary == "" {
+		return resp
+	}
+	if resp.Result == nil {
+		resp.Result = &metav1.Status{}
+	}
+	resp.Result.Message = *summary
+	return resp
+}
+
+// newPodWebhook wraps the mutator in an admission webhook whose responses
+// summarize the applied mutations
+func newPodWebhook(scheme *runtime.Scheme, mutator *PodMutator) *admission.Webhook {
+	w := admission.WithCustomDefaulter(scheme, &corev1.Pod{}, mutator)
+	w.Handler = &summarizingHandler{Handler: w.Handler}
+	return w
+}
@@ -94,7 +94,10 @@ var _ = BeforeSuite(func() {
 
 	// Change from SetupPodWebhookWithManager to SetupWebhookWithManager if you renamed it,
 	// otherwise just keep using SetupPodWebhookWithManager
-	err = SetupPodWebhookWithManager(mgr)
+	err = SetupPodWebhookWithManager(mgr, PodMutatorOptions{})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = SetupStatefulSetWebhookWithManager(mgr, PodMutatorOptions{})
 	Expect(err).NotTo(HaveOccurred())
 
 	// +kubebuilder:scaffold:webhook